@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/vgeshiktor/bhops/internal/attendanceops"
+	"github.com/vgeshiktor/bhops/internal/graph"
+	"github.com/vgeshiktor/bhops/internal/mail/smtp"
+	"github.com/vgeshiktor/bhops/internal/salaryops/publish/email"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ENV_PREFIX          = "ATTENDANCEOPS_"
+	DEFAULT_CONFIG_PATH = "attendanceops.yaml"
+	PERIOD_LAYOUT       = "2006-01"
+)
+
+// Config holds the attendanceops settings. Values are taken from, in order
+// of precedence: command line flags, ATTENDANCEOPS_* environment variables,
+// the YAML config file and the defaults.
+type Config struct {
+	AttendanceReportPath    string `yaml:"attendance_report"`
+	NonAttendanceReportPath string `yaml:"non_attendance_report"`
+	WorkerDetailsPath       string `yaml:"worker_details"`
+	OutputPath              string `yaml:"output"`
+	Period                  string `yaml:"period"`
+	LogLevel                string `yaml:"log_level"`
+	Language                string `yaml:"language"`
+	Dashboard               bool   `yaml:"dashboard"`
+	AnomalyRulesPath        string `yaml:"anomaly_rules"`
+	WorkspacePath           string `yaml:"workspace"`
+	QueuePath               string `yaml:"queue"`
+	DeadLettersPath         string `yaml:"dead_letters"`
+	ContactsPath            string `yaml:"contacts"`
+	TemplatesPath           string `yaml:"templates"`
+	ResultsPath             string `yaml:"results"`
+	SchedulePath            string `yaml:"schedule"`
+	ScheduleStatePath       string `yaml:"schedule_state"`
+	CalendarPath            string `yaml:"calendar"`
+	MailBackend             string `yaml:"mail_backend"`
+	GraphAuthFlow           string `yaml:"graph_auth_flow"`
+	GraphTenantID           string `yaml:"graph_tenant_id"`
+	GraphClientID           string `yaml:"graph_client_id"`
+	GraphMailbox            string `yaml:"graph_mailbox"`
+	GraphTokenCachePath     string `yaml:"graph_token_cache"`
+	SMTPAddr                string `yaml:"smtp_addr"`
+	SMTPSecurity            string `yaml:"smtp_security"`
+	SMTPUsername            string `yaml:"smtp_username"`
+	SMTPFrom                string `yaml:"smtp_from"`
+	InboxSenders            string `yaml:"inbox_senders"`
+	InboxMailbox            string `yaml:"inbox_mailbox"`
+	ChromeDriverPath        string `yaml:"chromedriver"`
+}
+
+// configOption binds a config value to its flag and environment variable
+type configOption struct {
+	name  string
+	usage string
+	str   *string
+	bool  *bool
+}
+
+func (o configOption) env() string {
+	return ENV_PREFIX + strings.ToUpper(strings.ReplaceAll(o.name, "-", "_"))
+}
+
+func (o configOption) set(value string) error {
+	if o.bool != nil {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value: %s for: %s, error: %w", value, o.name, err)
+		}
+		*o.bool = b
+		return nil
+	}
+	*o.str = value
+	return nil
+}
+
+func defaultConfig() Config {
+	return Config{
+		AttendanceReportPath:    "input/attendance.xlsx",
+		NonAttendanceReportPath: "input/workershours.json",
+		WorkerDetailsPath:       "config/id2worker.json",
+		OutputPath:              "output/salary_details.xlsx",
+		Period:                  time.Now().AddDate(0, -1, 0).Format(PERIOD_LAYOUT),
+		LogLevel:                zerolog.LevelInfoValue,
+		Language:                "he",
+		WorkspacePath:           ".",
+		QueuePath:               "queue/queue.json",
+		DeadLettersPath:         "queue/dead_letters",
+		ContactsPath:            "config/contacts.json",
+		ResultsPath:             "queue/results.json",
+		ScheduleStatePath:       "queue/schedule_state.json",
+		MailBackend:             email.DEFAULT_BACKEND,
+		GraphAuthFlow:           email.DEFAULT_AUTH_FLOW,
+		GraphTokenCachePath:     email.DEFAULT_TOKEN_CACHE,
+		SMTPSecurity:            email.DEFAULT_SMTP_SECURITY,
+	}
+}
+
+// loadConfig builds the config of a command from its flag set arguments
+func loadConfig(fs *flag.FlagSet, args []string) (Config, error) {
+	cfg := defaultConfig()
+
+	options := []configOption{
+		{name: "attendance-report", usage: "monthly attendance report workbook", str: &cfg.AttendanceReportPath},
+		{name: "non-attendance-report", usage: "non-attendance workers JSON file", str: &cfg.NonAttendanceReportPath},
+		{name: "worker-details", usage: "worker details JSON file", str: &cfg.WorkerDetailsPath},
+		{name: "output", usage: "salary details output workbook", str: &cfg.OutputPath},
+		{name: "period", usage: "report period, YYYY-MM", str: &cfg.Period},
+		{name: "log-level", usage: "log level: debug, info, warn, error", str: &cfg.LogLevel},
+		{name: "language", usage: "report language: he, en, ru, ar", str: &cfg.Language},
+		{name: "dashboard", usage: "add dashboard sheet to the report", bool: &cfg.Dashboard},
+		{name: "anomaly-rules", usage: "anomaly rules JSON file", str: &cfg.AnomalyRulesPath},
+		{name: "workspace", usage: "workspace root with a YYYY-MM directory per period", str: &cfg.WorkspacePath},
+		{name: "queue", usage: "jobs queue file", str: &cfg.QueuePath},
+		{name: "dead-letters", usage: "dead letter jobs directory", str: &cfg.DeadLettersPath},
+		{name: "contacts", usage: "worker contacts JSON file", str: &cfg.ContactsPath},
+		{name: "templates", usage: "payslip message templates dir, built-in templates by default", str: &cfg.TemplatesPath},
+		{name: "results", usage: "completed jobs results file", str: &cfg.ResultsPath},
+		{name: "schedule", usage: "scheduled jobs rules JSON file", str: &cfg.SchedulePath},
+		{name: "schedule-state", usage: "scheduled jobs state file", str: &cfg.ScheduleStatePath},
+		{name: "calendar", usage: "business days calendar JSON file", str: &cfg.CalendarPath},
+		{name: "mail-backend", usage: "payslip email backend: graph, smtp", str: &cfg.MailBackend},
+		{name: "graph-auth-flow", usage: "graph authentication flow: client_credentials, device_code", str: &cfg.GraphAuthFlow},
+		{name: "graph-tenant-id", usage: "graph tenant id", str: &cfg.GraphTenantID},
+		{name: "graph-client-id", usage: "graph application client id", str: &cfg.GraphClientID},
+		{name: "graph-mailbox", usage: "mailbox sending the payslip emails", str: &cfg.GraphMailbox},
+		{name: "graph-token-cache", usage: "encrypted graph token cache file", str: &cfg.GraphTokenCachePath},
+		{name: "smtp-addr", usage: "smtp server host:port", str: &cfg.SMTPAddr},
+		{name: "smtp-security", usage: "smtp connection security: starttls, tls, none", str: &cfg.SMTPSecurity},
+		{name: "smtp-username", usage: "smtp username, the password is read from " + email.ENV_SMTP_PASSWORD, str: &cfg.SMTPUsername},
+		{name: "smtp-from", usage: "sender address of the smtp emails, the username by default", str: &cfg.SMTPFrom},
+		{name: "inbox-senders", usage: "comma separated senders of emailed attendance exports, addresses or @domains", str: &cfg.InboxSenders},
+		{name: "inbox-mailbox", usage: "mailbox receiving the attendance exports, the graph mailbox by default", str: &cfg.InboxMailbox},
+		{name: "chromedriver", usage: "ChromeDriver path of the whatsapp payslips channel", str: &cfg.ChromeDriverPath},
+	}
+
+	// register flags, values are applied after the config file
+	configPath := fs.String("config", "",
+		"YAML config file (env: "+ENV_PREFIX+"CONFIG, default: "+DEFAULT_CONFIG_PATH+")")
+	flagValues := map[string]*string{}
+	for _, o := range options {
+		usage := fmt.Sprintf("%s (env: %s)", o.usage, o.env())
+		if o.bool != nil {
+			flagValues[o.name] = new(string)
+			fs.BoolFunc(o.name, usage, func(value string) error {
+				*flagValues[o.name] = value
+				return nil
+			})
+			continue
+		}
+		flagValues[o.name] = fs.String(o.name, "", usage)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	// load config file
+	path := *configPath
+	if path == "" {
+		path = os.Getenv(ENV_PREFIX + "CONFIG")
+	}
+	if err := cfg.loadFile(path); err != nil {
+		return cfg, err
+	}
+
+	// apply environment variables
+	for _, o := range options {
+		if value, ok := os.LookupEnv(o.env()); ok {
+			if err := o.set(value); err != nil {
+				return cfg, err
+			}
+		}
+	}
+
+	// apply flags set on the command line
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, o := range options {
+			if o.name == f.Name && err == nil {
+				err = o.set(*flagValues[o.name])
+			}
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.validate()
+}
+
+// loadFile reads the config file, a missing default config file is ignored
+func (c *Config) loadFile(path string) error {
+	explicit := path != ""
+	if !explicit {
+		path = DEFAULT_CONFIG_PATH
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config file: %s, error: %w", path, err)
+	}
+
+	if err := yaml.Unmarshal(content, c); err != nil {
+		return fmt.Errorf("failed to unmarshal config file: %s, error: %w", path, err)
+	}
+
+	return nil
+}
+
+func (c *Config) validate() error {
+	if _, err := time.Parse(PERIOD_LAYOUT, c.Period); err != nil {
+		return fmt.Errorf("invalid period: %s, expected YYYY-MM", c.Period)
+	}
+
+	if _, err := zerolog.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("invalid log level: %s, error: %w", c.LogLevel, err)
+	}
+
+	if _, ok := attendanceops.ParseLanguage(c.Language); !ok {
+		return fmt.Errorf("unsupported language: %s", c.Language)
+	}
+
+	if c.MailBackend != email.BackendGraph && c.MailBackend != email.BackendSMTP {
+		return fmt.Errorf("invalid mail backend: %s, expected: %s or %s",
+			c.MailBackend, email.BackendGraph, email.BackendSMTP)
+	}
+
+	if c.GraphAuthFlow != graph.FlowClientCredentials && c.GraphAuthFlow != graph.FlowDeviceCode {
+		return fmt.Errorf("invalid graph auth flow: %s, expected: %s or %s",
+			c.GraphAuthFlow, graph.FlowClientCredentials, graph.FlowDeviceCode)
+	}
+
+	if c.SMTPSecurity != smtp.SecurityStartTLS && c.SMTPSecurity != smtp.SecurityTLS && c.SMTPSecurity != smtp.SecurityNone {
+		return fmt.Errorf("invalid smtp security: %s, expected: %s, %s or %s",
+			c.SMTPSecurity, smtp.SecurityStartTLS, smtp.SecurityTLS, smtp.SecurityNone)
+	}
+
+	return nil
+}
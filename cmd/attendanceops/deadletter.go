@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/vgeshiktor/bhops/internal/queue"
+)
+
+// deadLetterCommand lists, inspects and replays jobs that failed for good:
+//
+//	attendanceops deadletter list
+//	attendanceops deadletter inspect <id>
+//	attendanceops deadletter replay [--all] [<id>...]
+func deadLetterCommand(args []string) error {
+	usage := fmt.Errorf("usage: attendanceops deadletter <list|inspect|replay> [flags] [<id>...]")
+	if len(args) == 0 {
+		return usage
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("deadletter "+action, flag.ContinueOnError)
+	all := fs.Bool("all", false, "replay all dead letters")
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	setLogLevel(cfg.LogLevel)
+
+	store, err := queue.NewDeadLetterStore(cfg.DeadLettersPath)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "list":
+		return listDeadLetters(store)
+	case "inspect":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: attendanceops deadletter inspect [flags] <id>")
+		}
+		return inspectDeadLetter(store, fs.Arg(0))
+	case "replay":
+		ids := fs.Args()
+		if *all {
+			letters, err := store.List()
+			if err != nil {
+				return err
+			}
+			ids = ids[:0]
+			for _, letter := range letters {
+				ids = append(ids, letter.ID)
+			}
+		}
+		if len(ids) == 0 {
+			return fmt.Errorf("usage: attendanceops deadletter replay [flags] [--all] [<id>...]")
+		}
+		return replayDeadLetters(store, cfg.QueuePath, ids)
+	default:
+		return usage
+	}
+}
+
+func listDeadLetters(store *queue.DeadLetterStore) error {
+	letters, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\tDELIVERIES\tDEAD AT\tERROR\n")
+	for _, letter := range letters {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n",
+			letter.ID, letter.Deliveries, letter.DeadAt.Format("2006-01-02 15:04:05"), letter.Error)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to print dead letters, error: %w", err)
+	}
+
+	return nil
+}
+
+// inspectDeadLetter prints the dead letter, with a JSON body printed as is
+func inspectDeadLetter(store *queue.DeadLetterStore, id string) error {
+	letter, err := store.Get(id)
+	if err != nil {
+		return err
+	}
+
+	output := struct {
+		queue.DeadLetter
+		Body any `json:"body"`
+	}{DeadLetter: letter, Body: string(letter.Body)}
+	if json.Valid(letter.Body) {
+		output.Body = json.RawMessage(letter.Body)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "   ")
+	if err := enc.Encode(output); err != nil {
+		return fmt.Errorf("failed to encode dead letter: %s, error: %w", id, err)
+	}
+
+	return nil
+}
+
+func replayDeadLetters(store *queue.DeadLetterStore, queuePath string, ids []string) error {
+	q, err := queue.OpenFileQueue(queuePath)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	for _, id := range ids {
+		newID, err := store.Replay(context.Background(), id, q)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("replayed: %s as: %s\n", id, newID)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/graph"
+	"github.com/vgeshiktor/bhops/internal/inbox"
+	"github.com/vgeshiktor/bhops/internal/queue"
+	"github.com/vgeshiktor/bhops/internal/salaryops/publish/email"
+	"github.com/vgeshiktor/bhops/internal/workspace"
+)
+
+// inboxCommand polls the inbox mailbox for attendance exports emailed by
+// the configured senders, saves them into the workspace period inputs and
+// enqueues the report builds, run by the worker:
+//
+//	attendanceops inbox [--once] [--interval 5m]
+func inboxCommand(args []string) error {
+	fs := flag.NewFlagSet("inbox", flag.ContinueOnError)
+	once := fs.Bool("once", false, "poll the mailbox once and exit")
+	interval := fs.Duration("interval", inbox.DefaultPollInterval, "mailbox polling interval")
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if cfg.InboxSenders == "" {
+		return fmt.Errorf("no inbox senders configured, set --inbox-senders")
+	}
+
+	setLogLevel(cfg.LogLevel)
+
+	q, err := queue.OpenFileQueue(cfg.QueuePath)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	poller, err := newInboxPoller(cfg, q)
+	if err != nil {
+		return err
+	}
+	poller.Interval = *interval
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if !*once {
+		log.Info().Msgf("Polling inbox every: %s for attendance exports from: %s", poller.Interval, cfg.InboxSenders)
+		return poller.Run(ctx)
+	}
+
+	ingested, err := poller.Poll(ctx, time.Now())
+	for _, export := range ingested {
+		fmt.Printf("%s\t%s\t%s\n", export.Period, export.File, export.JobID)
+	}
+	return err
+}
+
+// newInboxPoller creates the poller of the inbox Graph mailbox, the inbox
+// mailbox defaults to the mailbox sending the payslips
+func newInboxPoller(cfg Config, q queue.Queue) (*inbox.Poller, error) {
+	mailbox := cfg.InboxMailbox
+	if mailbox == "" {
+		mailbox = cfg.GraphMailbox
+	}
+
+	client, err := email.NewGraphClient(email.Options{
+		AuthFlow:       cfg.GraphAuthFlow,
+		TenantID:       cfg.GraphTenantID,
+		ClientID:       cfg.GraphClientID,
+		Mailbox:        mailbox,
+		TokenCachePath: cfg.GraphTokenCachePath,
+		Scopes:         inboxScopes(cfg.GraphAuthFlow),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create inbox mailbox client, error: %w", err)
+	}
+
+	return inbox.NewPoller(inbox.NewGraphMailbox(client), workspace.New(cfg.WorkspacePath), q,
+		strings.Split(cfg.InboxSenders, ","))
+}
+
+// inboxScopes returns the scopes of the flow, app-only tokens have the
+// application permissions
+func inboxScopes(flow string) []string {
+	if flow == graph.FlowDeviceCode {
+		return []string{graph.MailReadWriteScope}
+	}
+	return nil
+}
@@ -1,36 +1,142 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"os"
 
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"github.com/vgeshiktor/bhops/internal/attendanceops"
+	"github.com/vgeshiktor/bhops/internal/redact"
 )
 
+// commands of attendanceops, without a command the report is built from
+// the configured files
+var commands = map[string]func(args []string) error{
+	"run":        runCommand,
+	"preview":    previewCommand,
+	"watch":      watchCommand,
+	"serve":      serveCommand,
+	"tui":        tuiCommand,
+	"deadletter": deadLetterCommand,
+	"worker":     workerCommand,
+	"enqueue":    enqueueCommand,
+	"schedule":   scheduleCommand,
+	"inbox":      inboxCommand,
+}
+
+// exit codes
 const (
-    MONTHLY_ATTENDANCE_REPORT_XLS_PATH = "/Users/vadimgeshiktor/repos/github.com/vgeshiktor/bhops/internal/attendanceops/input/02-2025.xlsx"
-    WORKER_DETAILS_JSON_PATH = "/Users/vadimgeshiktor/repos/github.com/vgeshiktor/bhops/internal/attendanceops/config/id2worker.json"
-    
-    WORKER_HOURS_JSON_PATH = "/Users/vadimgeshiktor/repos/github.com/vgeshiktor/bhops/internal/attendanceops/input/workershours.json"
-    SALARY_DETAILS_OUTPUT_PATH = "/Users/vadimgeshiktor/repos/github.com/vgeshiktor/bhops/internal/attendanceops/output/salary_details.xlsx"
+	EXIT_ERROR      = 1
+	EXIT_VALIDATION = 3
 )
 
+// exitCodeError is returned by commands failing with a specific exit code
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
 func main() {
-    // create workers attendance report sheet
-    AttendanceReport, err := attendanceops.CreateAttendanceReport(
-        MONTHLY_ATTENDANCE_REPORT_XLS_PATH,
-        WORKER_HOURS_JSON_PATH,
-        WORKER_DETAILS_JSON_PATH,
-    )
-    if err != nil {
-        fmt.Println("Failed to create attendance report: ", err)
-        return
-    }
-
-    // save workers attendance report sheet
-    err = attendanceops.SaveAttendanceReport(
-        AttendanceReport,    SALARY_DETAILS_OUTPUT_PATH)
-    if err != nil {
-        fmt.Printf(
-            "Failed to save attendance report: %s, error: %v", SALARY_DETAILS_OUTPUT_PATH, err)
-        }
+	// keep access tokens and secrets out of the logs
+	log.Logger = log.Output(redact.NewWriter(os.Stderr))
+
+	command, args := buildCommand, os.Args[1:]
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			command, args = cmd, args[1:]
+		}
+	}
+
+	if err := command(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Error().Msgf("%v", err)
+
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
+		os.Exit(EXIT_ERROR)
+	}
+}
+
+func buildCommand(args []string) error {
+	fs := flag.NewFlagSet("attendanceops", flag.ContinueOnError)
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	setLogLevel(cfg.LogLevel)
+
+	return buildReport(cfg)
+}
+
+func setLogLevel(level string) {
+	logLevel, err := zerolog.ParseLevel(level)
+	if err != nil {
+		logLevel = zerolog.InfoLevel
+	}
+	zerolog.SetGlobalLevel(logLevel)
+}
+
+// createReport creates the workers attendance report of the config
+func createReport(cfg Config) (*attendanceops.AttendanceReport, error) {
+	// create workers attendance report sheet
+	attendanceReport, err := attendanceops.CreateAttendanceReport(
+		cfg.AttendanceReportPath,
+		cfg.NonAttendanceReportPath,
+		cfg.WorkerDetailsPath,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create attendance report, error: %w", err)
+	}
+
+	// apply report options
+	lang, _ := attendanceops.ParseLanguage(cfg.Language)
+	attendanceReport.SetLanguage(lang)
+	attendanceReport.SetDashboard(cfg.Dashboard)
+
+	if cfg.AnomalyRulesPath != "" {
+		rules, err := attendanceops.LoadAnomalyRules(cfg.AnomalyRulesPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load anomaly rules, error: %w", err)
+		}
+		attendanceReport.SetAnomalyRules(rules)
+	}
+
+	return attendanceReport, nil
+}
+
+func buildReport(cfg Config) error {
+	log.Info().Msgf("Creating attendance report for period: %s", cfg.Period)
+
+	attendanceReport, err := createReport(cfg)
+	if err != nil {
+		return err
+	}
+
+	// save workers attendance report sheet
+	err = attendanceops.SaveAttendanceReport(
+		attendanceReport, cfg.OutputPath)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to save attendance report: %s, error: %w", cfg.OutputPath, err)
+	}
+
+	log.Info().Msgf("Attendance report saved: %s", cfg.OutputPath)
+
+	return nil
 }
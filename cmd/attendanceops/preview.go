@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/vgeshiktor/bhops/internal/attendanceops"
+	"github.com/vgeshiktor/bhops/internal/workspace"
+)
+
+const (
+	// unicode first strong isolate and pop directional isolate, keep
+	// right-to-left names from reordering the columns around them
+	FSI = "\u2068"
+	PDI = "\u2069"
+)
+
+// previewResult is the --json output of the preview command
+type previewResult struct {
+	Period    string                  `json:"period"`
+	Workers   []attendanceops.Worker  `json:"workers"`
+	Anomalies []attendanceops.Anomaly `json:"anomalies"`
+}
+
+// previewCommand prints the report of every worker without writing any
+// file, and fails when the report has validation problems:
+//
+//	attendanceops preview [--json] [--month 2025-02]
+func previewCommand(args []string) error {
+	fs := flag.NewFlagSet("preview", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	month := fs.String("month", "", "preview a workspace period, YYYY-MM")
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	setLogLevel(cfg.LogLevel)
+
+	// use the period inputs of the workspace
+	var period *workspace.Period
+	if *month != "" {
+		if period, _, err = periodInputs(&cfg, *month); err != nil {
+			return err
+		}
+	}
+
+	attendanceReport, err := createReport(cfg)
+	if err != nil {
+		return err
+	}
+
+	// compare with earlier periods, as the period run does
+	if period != nil {
+		setPeriodHistory(period, attendanceReport)
+	}
+
+	result := previewResult{
+		Period:    cfg.Period,
+		Workers:   attendanceReport.Workers(),
+		Anomalies: attendanceReport.Anomalies(),
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "   ")
+		if err := enc.Encode(result); err != nil {
+			return fmt.Errorf("failed to encode preview, error: %w", err)
+		}
+	} else if err := printPreview(os.Stdout, result); err != nil {
+		return err
+	}
+
+	if len(result.Anomalies) > 0 {
+		return &exitCodeError{
+			code: EXIT_VALIDATION,
+			err:  fmt.Errorf("attendance report has %d validation problems", len(result.Anomalies)),
+		}
+	}
+
+	return nil
+}
+
+// printPreview prints the workers table followed by the validation problems.
+// Names are printed last and isolated, so their direction does not affect
+// the alignment of the numeric columns.
+func printPreview(out io.Writer, result previewResult) error {
+	number := func(value float64, prec int) string {
+		return strconv.FormatFloat(value, 'f', prec, 64)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "ID\tTYPE\tHOURS\t125%%\tTOTAL HOURS\tWORK DAYS\tHOLIDAYS\tSICK\tVACATION\tREGULAR ₪\t125%% ₪\tTRAVEL ₪\tTOTAL ₪\t  NAME\n")
+	var total float64
+	for _, worker := range result.Workers {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t  %s\n",
+			worker.WorkerID,
+			worker.WorkerType,
+			number(worker.Hours, 1),
+			number(worker.Hours125, 1),
+			number(worker.TotalHours, 0),
+			number(worker.WorkDays, 0),
+			number(worker.Holidays, 1),
+			number(worker.SickDays, 1),
+			number(worker.VacDays, 1),
+			number(worker.RegularHoursSal, 0),
+			number(worker.ExtraHoursSal, 0),
+			number(worker.TransExpanses, 0),
+			number(worker.TotalSal, 0),
+			FSI+worker.Name+PDI,
+		)
+		total += worker.TotalSal
+	}
+	fmt.Fprintf(w, "\t\t\t\t\t\t\t\t\t\t\t\t%s\t  %s\n", number(total, 0), "TOTAL")
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to print preview, error: %w", err)
+	}
+
+	if len(result.Anomalies) == 0 {
+		fmt.Fprintln(out, "\nNo validation problems")
+		return nil
+	}
+
+	fmt.Fprintf(out, "\n%d validation problems:\n", len(result.Anomalies))
+	for _, anomaly := range result.Anomalies {
+		fmt.Fprintf(out, "  %s  %s: %s  %s\n",
+			anomaly.WorkerID, anomaly.Kind, anomaly.Message, FSI+anomaly.Name+PDI)
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/attendanceops"
+	"github.com/vgeshiktor/bhops/internal/workspace"
+)
+
+const (
+	SALARY_DETAILS_XLSX = "salary_details.xlsx"
+	SALARY_DETAILS_JSON = "salary_details.json"
+)
+
+// runCommand builds the report of a workspace period:
+//
+//	attendanceops run --month 2025-02
+func runCommand(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	month := fs.String("month", "", "period to run, YYYY-MM (default: -period)")
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if *month != "" {
+		if _, err := workspace.ParsePeriod(*month); err != nil {
+			return err
+		}
+		cfg.Period = *month
+	}
+
+	setLogLevel(cfg.LogLevel)
+
+	_, err = runPeriod(context.Background(), cfg)
+	return err
+}
+
+// runPeriod builds the period report from the period inputs and writes a
+// new version of the outputs, recorded in the period manifest. The context
+// is checked before every step.
+func runPeriod(ctx context.Context, cfg Config) (*workspace.Run, error) {
+	period, inputs, err := periodInputs(&cfg, cfg.Period)
+	if err != nil {
+		return nil, err
+	}
+	if err := period.Init(); err != nil {
+		return nil, err
+	}
+
+	log.Info().Msgf("Running period: %s, attendance report: %s", period.Name, inputs.AttendanceReport)
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	attendanceReport, err := createReport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return savePeriodRun(ctx, period, inputs, attendanceReport)
+}
+
+// periodInputs finds the inputs of a workspace period and points the
+// config input paths at them
+func periodInputs(cfg *Config, name string) (*workspace.Period, workspace.Inputs, error) {
+	period, err := workspace.New(cfg.WorkspacePath).Period(name)
+	if err != nil {
+		return nil, workspace.Inputs{}, err
+	}
+
+	inputs, err := period.FindInputs()
+	if err != nil {
+		return nil, inputs, fmt.Errorf("failed to find inputs of period: %s, error: %w", period.Name, err)
+	}
+	cfg.Period = period.Name
+	cfg.AttendanceReportPath = inputs.AttendanceReport
+	cfg.NonAttendanceReportPath = inputs.NonAttendanceReport
+	cfg.WorkerDetailsPath = inputs.WorkerDetails
+
+	return period, inputs, nil
+}
+
+// savePeriodRun saves the report outputs of a period under a new version
+// and records the run in the period manifest
+func savePeriodRun(
+	ctx context.Context,
+	period *workspace.Period,
+	inputs workspace.Inputs,
+	attendanceReport *attendanceops.AttendanceReport,
+) (*workspace.Run, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// compare with earlier periods
+	setPeriodHistory(period, attendanceReport)
+
+	// save outputs under a new version
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	version, err := period.ReserveVersion(SALARY_DETAILS_XLSX, SALARY_DETAILS_JSON)
+	if err != nil {
+		return nil, err
+	}
+
+	xlsxPath := period.OutputPath(SALARY_DETAILS_XLSX, version)
+	if err := attendanceops.SaveAttendanceReport(attendanceReport, xlsxPath); err != nil {
+		return nil, fmt.Errorf("failed to save attendance report: %s, error: %w", xlsxPath, err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	jsonPath := period.OutputPath(SALARY_DETAILS_JSON, version)
+	if err := attendanceops.SaveAttendanceReportJSON(attendanceReport, jsonPath); err != nil {
+		return nil, fmt.Errorf("failed to save attendance report: %s, error: %w", jsonPath, err)
+	}
+
+	// record inputs and outputs of the run
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	run, err := period.RecordRun(
+		version,
+		[]string{inputs.AttendanceReport, inputs.NonAttendanceReport, inputs.WorkerDetails},
+		[]string{xlsxPath, jsonPath},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record run of period: %s, error: %w", period.Name, err)
+	}
+
+	log.Info().Msgf("Period: %s run version: %d saved: %s", period.Name, version, xlsxPath)
+
+	return run, nil
+}
+
+// setPeriodHistory sets the previous totals and trend history of the
+// period report from the outputs of the earlier periods
+func setPeriodHistory(period *workspace.Period, attendanceReport *attendanceops.AttendanceReport) {
+	previousTotals, history := loadHistory(period, attendanceops.TrendMonths-1)
+	attendanceReport.SetPreviousTotals(previousTotals)
+	attendanceReport.SetHistory(append(history, attendanceReport.Summary(period.Name)))
+}
+
+// loadHistory loads the worker totals of the previous period and the
+// summaries of up to months earlier periods, oldest first, from their
+// latest outputs
+func loadHistory(period *workspace.Period, months int) (map[string]float64, []attendanceops.MonthlySummary) {
+	var previousTotals map[string]float64
+	var history []attendanceops.MonthlySummary
+
+	previous := period
+	for i := 0; i < months; i++ {
+		previous = previous.Previous()
+
+		path, err := previous.LatestOutput(SALARY_DETAILS_JSON)
+		if err != nil {
+			log.Debug().Msgf("no output for period: %s, error: %v", previous.Name, err)
+			continue
+		}
+		workers, err := attendanceops.LoadWorkers(path)
+		if err != nil {
+			log.Error().Msgf("failed to load output of period: %s, error: %v", previous.Name, err)
+			continue
+		}
+
+		summary := attendanceops.MonthlySummary{Period: previous.Name}
+		totals := map[string]float64{}
+		for _, worker := range workers {
+			summary.TotalSal += worker.TotalSal
+			summary.TotalHours += worker.TotalHours
+			totals[worker.WorkerID] = worker.TotalSal
+		}
+		if i == 0 {
+			previousTotals = totals
+		}
+		history = append([]attendanceops.MonthlySummary{summary}, history...)
+	}
+
+	return previousTotals, history
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vgeshiktor/bhops/internal/queue"
+	"github.com/vgeshiktor/bhops/internal/schedule"
+)
+
+// scheduleCommand lists the scheduled jobs rules with their last and next
+// runs, the jobs are enqueued by the worker:
+//
+//	attendanceops schedule list
+func scheduleCommand(args []string) error {
+	usage := fmt.Errorf("usage: attendanceops schedule list [flags]")
+	if len(args) == 0 || args[0] != "list" {
+		return usage
+	}
+
+	fs := flag.NewFlagSet("schedule list", flag.ContinueOnError)
+	cfg, err := loadConfig(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	if cfg.SchedulePath == "" {
+		return fmt.Errorf("no schedule configured, set --schedule")
+	}
+
+	setLogLevel(cfg.LogLevel)
+
+	q, err := queue.OpenFileQueue(cfg.QueuePath)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	scheduler, err := newScheduler(cfg, q)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "NAME\tWHEN\tTYPE\tLAST RUN\tNEXT RUN\tLAST JOB\n")
+	for _, status := range scheduler.Status(time.Now()) {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			status.Name, status.When, status.Type,
+			formatRunTime(status.LastRunAt), formatRunTime(status.NextRunAt), status.LastJobID)
+	}
+
+	return w.Flush()
+}
+
+// newScheduler creates the scheduler of the configured rules and calendar
+func newScheduler(cfg Config, q queue.Queue) (*schedule.Scheduler, error) {
+	rules, err := schedule.LoadRules(cfg.SchedulePath)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := schedule.DefaultCalendar()
+	if cfg.CalendarPath != "" {
+		if calendar, err = schedule.LoadCalendar(cfg.CalendarPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return schedule.NewScheduler(rules, calendar, q, cfg.ScheduleStatePath)
+}
+
+func formatRunTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04 MST")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/attendanceops"
+	"github.com/vgeshiktor/bhops/internal/attendanceops/server"
+)
+
+const (
+	DEFAULT_ADDR     = "127.0.0.1:8080"
+	SHUTDOWN_TIMEOUT = 10 * time.Second
+)
+
+// serveCommand serves the attendance report HTTP API and the upload page:
+//
+//	attendanceops serve [--addr 127.0.0.1:8080] [--data-dir dir]
+//	                   [--max-upload bytes] [--job-ttl 24h]
+//
+// The server has no authentication, it listens on localhost unless another
+// address is given.
+func serveCommand(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", DEFAULT_ADDR, "address to listen on, the server has no authentication")
+	dataDir := fs.String("data-dir", filepath.Join(os.TempDir(), "attendanceops"), "directory of job files")
+	maxUpload := fs.Int64("max-upload", server.DefaultMaxUploadBytes, "maximum upload request size in bytes")
+	jobTTL := fs.Duration("job-ttl", server.DefaultJobTTL, "time finished jobs and their files are kept")
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	setLogLevel(cfg.LogLevel)
+
+	rules := attendanceops.DefaultAnomalyRules()
+	if cfg.AnomalyRulesPath != "" {
+		if rules, err = attendanceops.LoadAnomalyRules(cfg.AnomalyRulesPath); err != nil {
+			return fmt.Errorf("failed to load anomaly rules, error: %w", err)
+		}
+	}
+	lang, _ := attendanceops.ParseLanguage(cfg.Language)
+
+	handler, err := server.New(server.Options{
+		WorkerDetailsPath: cfg.WorkerDetailsPath,
+		DataDir:           *dataDir,
+		MaxUploadBytes:    *maxUpload,
+		JobTTL:            *jobTTL,
+		AnomalyRules:      rules,
+		Language:          lang,
+		Dashboard:         cfg.Dashboard,
+	})
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		log.Info().Msgf("Serving attendance reports on: %s", *addr)
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("failed to serve on: %s, error: %w", *addr, err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to shut down server, error: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/vgeshiktor/bhops/internal/attendanceops"
+	"github.com/vgeshiktor/bhops/internal/workspace"
+)
+
+// tui layout
+const (
+	TUI_DEFAULT_WIDTH  = 120
+	TUI_DEFAULT_HEIGHT = 30
+	TUI_LIST_WIDTH     = 80
+	TUI_NAME_WIDTH     = 24
+)
+
+var (
+	tuiTitleStyle    = lipgloss.NewStyle().Bold(true)
+	tuiSelectedStyle = lipgloss.NewStyle().Reverse(true)
+	tuiAnomalyStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	tuiHelpStyle     = lipgloss.NewStyle().Faint(true)
+	tuiPaneStyle     = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1)
+	tuiFocusedStyle  = tuiPaneStyle.BorderForeground(lipgloss.Color("4"))
+)
+
+// adjustmentFields are the editable adjustments of the worker pane
+var adjustmentFields = []struct {
+	label string
+	value func(a *attendanceops.Adjustment) *float64
+}{
+	{"hours adjustment", func(a *attendanceops.Adjustment) *float64 { return &a.HoursAdjustment }},
+	{"125% hours adjustment", func(a *attendanceops.Adjustment) *float64 { return &a.Hours125Adjustment }},
+	{"vacation days adjustment", func(a *attendanceops.Adjustment) *float64 { return &a.VacDaysAdjustment }},
+	{"holidays", func(a *attendanceops.Adjustment) *float64 { return &a.Holidays }},
+	{"holiday present", func(a *attendanceops.Adjustment) *float64 { return &a.HolidayPresent }},
+}
+
+// tuiSession is the report reviewed by the tui and where it is saved
+type tuiSession struct {
+	cfg    Config
+	report *attendanceops.AttendanceReport
+
+	// period inputs, when the session edits a workspace period
+	period *workspace.Period
+	inputs workspace.Inputs
+}
+
+// tuiCommand shows the workers of the report with a detail pane of the
+// selected worker, whose adjustments are edited with live recalculation of
+// the totals. Saving writes the adjustments to the worker details file and
+// the workbook to the output, or, with --month, the adjustments to the
+// period worker details and the workbook to a new version of the period:
+//
+//	attendanceops tui [--month 2025-02]
+func tuiCommand(args []string) error {
+	fs := flag.NewFlagSet("tui", flag.ContinueOnError)
+	month := fs.String("month", "", "edit a workspace period, YYYY-MM")
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	setLogLevel(cfg.LogLevel)
+
+	session := &tuiSession{}
+
+	// use the period inputs of the workspace
+	if *month != "" {
+		if session.period, session.inputs, err = periodInputs(&cfg, *month); err != nil {
+			return err
+		}
+		if err := session.period.Init(); err != nil {
+			return err
+		}
+	}
+
+	session.cfg = cfg
+	if session.report, err = createReport(cfg); err != nil {
+		return err
+	}
+
+	_, err = tea.NewProgram(newTUIModel(session), tea.WithAltScreen()).Run()
+	return err
+}
+
+// save writes the worker details with the adjustments and the workbook,
+// adjustments of a period are kept in the period inputs so they do not
+// change the shared worker details of other periods
+func (s *tuiSession) save() (string, error) {
+	if s.period != nil {
+		s.cfg.WorkerDetailsPath = s.period.WorkerDetailsPath()
+		s.inputs.WorkerDetails = s.cfg.WorkerDetailsPath
+	}
+	if err := s.report.SaveWorkerDetails(s.cfg.WorkerDetailsPath); err != nil {
+		return "", fmt.Errorf("failed to save worker details, error: %w", err)
+	}
+
+	if s.period != nil {
+		run, err := savePeriodRun(context.Background(), s.period, s.inputs, s.report)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Saved: %s, period: %s version: %d",
+			s.cfg.WorkerDetailsPath, s.period.Name, run.Version), nil
+	}
+
+	if err := attendanceops.SaveAttendanceReport(s.report, s.cfg.OutputPath); err != nil {
+		return "", fmt.Errorf("failed to save attendance report: %s, error: %w", s.cfg.OutputPath, err)
+	}
+	return fmt.Sprintf("Saved: %s, %s", s.cfg.WorkerDetailsPath, s.cfg.OutputPath), nil
+}
+
+// tuiFocus is the pane receiving the keys
+type tuiFocus int
+
+const (
+	focusWorkers tuiFocus = iota
+	focusDetail
+	focusEdit
+	focusQuit
+)
+
+// tuiModel is the bubbletea model of the tui: a workers list and the
+// detail pane of the selected worker
+type tuiModel struct {
+	session *tuiSession
+	workers []attendanceops.Worker
+
+	// anomalies of the report by worker id, detected again on every change
+	anomalies map[string][]attendanceops.Anomaly
+
+	focus  tuiFocus
+	cursor int
+	offset int
+	field  int
+
+	// input is the edited adjustment value, original is the adjustment
+	// restored when the edit is canceled
+	input           string
+	original        attendanceops.Adjustment
+	originalChanged bool
+
+	changed bool
+	status  string
+	width   int
+	height  int
+}
+
+func newTUIModel(session *tuiSession) *tuiModel {
+	m := &tuiModel{
+		session: session,
+		width:   TUI_DEFAULT_WIDTH,
+		height:  TUI_DEFAULT_HEIGHT,
+	}
+	m.refresh()
+	return m
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.scroll()
+		return m, nil
+
+	case tea.KeyMsg:
+		key := msg.String()
+		if key == "ctrl+c" {
+			return m, tea.Quit
+		}
+
+		switch m.focus {
+		case focusWorkers:
+			return m, m.updateWorkers(key)
+		case focusDetail:
+			return m, m.updateDetail(key)
+		case focusEdit:
+			m.updateEdit(msg)
+		case focusQuit:
+			if key == "y" {
+				return m, tea.Quit
+			}
+			m.focus = focusWorkers
+			m.status = ""
+		}
+	}
+
+	return m, nil
+}
+
+// updateWorkers moves in the workers list
+func (m *tuiModel) updateWorkers(key string) tea.Cmd {
+	switch key {
+	case "up", "k":
+		m.cursor--
+	case "down", "j":
+		m.cursor++
+	case "pgup":
+		m.cursor -= m.listRows()
+	case "pgdown":
+		m.cursor += m.listRows()
+	case "home", "g":
+		m.cursor = 0
+	case "end", "G":
+		m.cursor = len(m.workers) - 1
+	case "enter", "right", "l", "tab":
+		if len(m.workers) > 0 {
+			m.focus = focusDetail
+		}
+	case "s":
+		m.save()
+	case "q", "esc":
+		return m.quit()
+	}
+
+	m.cursor = max(min(m.cursor, len(m.workers)-1), 0)
+	m.scroll()
+	return nil
+}
+
+// updateDetail selects the adjustment to edit
+func (m *tuiModel) updateDetail(key string) tea.Cmd {
+	switch key {
+	case "up", "k":
+		m.field = max(m.field-1, 0)
+	case "down", "j":
+		m.field = min(m.field+1, len(adjustmentFields)-1)
+	case "enter", "e":
+		worker := m.workers[m.cursor]
+		m.original, _ = m.session.report.Adjustment(worker.WorkerID)
+		m.input = strconv.FormatFloat(*adjustmentFields[m.field].value(&m.original), 'f', -1, 64)
+		m.originalChanged = m.changed
+		m.focus = focusEdit
+		m.status = ""
+	case "esc", "left", "h", "tab":
+		m.focus = focusWorkers
+	case "s":
+		m.save()
+	case "q":
+		return m.quit()
+	}
+	return nil
+}
+
+// updateEdit edits the adjustment value, the worker totals are
+// recalculated whenever the value is a number
+func (m *tuiModel) updateEdit(msg tea.KeyMsg) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		if _, err := strconv.ParseFloat(m.input, 64); err != nil {
+			m.status = fmt.Sprintf("invalid %s: %s", adjustmentFields[m.field].label, m.input)
+			return
+		}
+		if m.status == "" {
+			m.focus = focusDetail
+		}
+		return
+	case tea.KeyEsc:
+		m.apply(m.original)
+		m.changed = m.originalChanged
+		m.focus = focusDetail
+		m.status = ""
+		return
+	case tea.KeyBackspace:
+		if m.input != "" {
+			m.input = m.input[:len(m.input)-1]
+		}
+	case tea.KeyRunes:
+		for _, r := range msg.Runes {
+			if strings.ContainsRune("0123456789.-", r) {
+				m.input += string(r)
+			}
+		}
+	default:
+		return
+	}
+
+	value, err := strconv.ParseFloat(m.input, 64)
+	if err != nil {
+		return
+	}
+	adjustment := m.original
+	*adjustmentFields[m.field].value(&adjustment) = value
+	m.apply(adjustment)
+}
+
+// apply applies the adjustment of the selected worker and refreshes the
+// totals
+func (m *tuiModel) apply(adjustment attendanceops.Adjustment) {
+	workerID := m.workers[m.cursor].WorkerID
+	if err := m.session.report.ApplyAdjustment(workerID, adjustment); err != nil {
+		m.status = err.Error()
+		return
+	}
+
+	m.status = ""
+	m.changed = m.changed || adjustment != m.original
+	m.refresh()
+}
+
+func (m *tuiModel) save() {
+	status, err := m.session.save()
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	m.status = status
+	m.changed = false
+}
+
+// quit asks before dropping unsaved adjustments
+func (m *tuiModel) quit() tea.Cmd {
+	if !m.changed {
+		return tea.Quit
+	}
+	m.focus = focusQuit
+	m.status = "discard unsaved adjustments? y/n"
+	return nil
+}
+
+// refresh reloads the workers and anomalies of the report
+func (m *tuiModel) refresh() {
+	m.workers = m.session.report.Workers()
+	m.anomalies = map[string][]attendanceops.Anomaly{}
+	for _, anomaly := range m.session.report.Anomalies() {
+		m.anomalies[anomaly.WorkerID] = append(m.anomalies[anomaly.WorkerID], anomaly)
+	}
+}
+
+// listRows is the number of workers shown in the list pane
+func (m *tuiModel) listRows() int {
+	// title, header, total, help and pane borders
+	return max(m.height-7, 1)
+}
+
+// scroll keeps the selected worker in the list pane
+func (m *tuiModel) scroll() {
+	rows := m.listRows()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+rows {
+		m.offset = m.cursor - rows + 1
+	}
+}
+
+func (m *tuiModel) View() string {
+	title := "Workers, period: " + m.session.cfg.Period
+	if m.changed {
+		title += " (unsaved)"
+	}
+
+	listStyle, detailStyle := tuiFocusedStyle, tuiPaneStyle
+	if m.focus != focusWorkers {
+		listStyle, detailStyle = tuiPaneStyle, tuiFocusedStyle
+	}
+	detailWidth := max(m.width-TUI_LIST_WIDTH-4, 30)
+	panes := lipgloss.JoinHorizontal(lipgloss.Top,
+		listStyle.Width(TUI_LIST_WIDTH).Render(m.viewWorkers()),
+		detailStyle.Width(detailWidth).Render(m.viewDetail()),
+	)
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		tuiTitleStyle.Render(title),
+		panes,
+		m.status,
+		tuiHelpStyle.Render(m.help()),
+	)
+}
+
+// viewWorkers renders the workers list, marking workers with anomalies
+func (m *tuiModel) viewWorkers() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-10s %-8s %7s %6s %9s %2s  %s\n", "ID", "TYPE", "HOURS", "125%", "TOTAL ₪", "!", "NAME")
+
+	end := min(m.offset+m.listRows(), len(m.workers))
+	for i := m.offset; i < end; i++ {
+		worker := m.workers[i]
+		mark := ""
+		if count := len(m.anomalies[worker.WorkerID]); count > 0 {
+			mark = strconv.Itoa(count)
+		}
+		row := fmt.Sprintf("%-10s %-8s %7.1f %6.1f %9.0f %2s  %s",
+			worker.WorkerID, worker.WorkerType, worker.Hours, worker.Hours125, worker.TotalSal,
+			mark, FSI+truncate(worker.Name, TUI_NAME_WIDTH)+PDI)
+		if i == m.cursor {
+			row = tuiSelectedStyle.Render(row)
+		} else if mark != "" {
+			row = tuiAnomalyStyle.Render(row)
+		}
+		fmt.Fprintln(&b, row)
+	}
+
+	var total float64
+	for _, worker := range m.workers {
+		total += worker.TotalSal
+	}
+	fmt.Fprintf(&b, "%-10s %-8s %7s %6s %9.0f", "TOTAL", "", "", "", total)
+
+	return b.String()
+}
+
+// viewDetail renders the values, adjustments and anomalies of the selected
+// worker
+func (m *tuiModel) viewDetail() string {
+	if len(m.workers) == 0 {
+		return "no workers"
+	}
+	worker := m.workers[m.cursor]
+	adjustment, _ := m.session.report.Adjustment(worker.WorkerID)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s  %s  %s\n\n", worker.WorkerID, FSI+worker.Name+PDI, worker.WorkerType)
+	fmt.Fprintf(&b, "%-14s %7.1f   %-10s %8.0f\n", "hours", worker.Hours, "regular ₪", worker.RegularHoursSal)
+	fmt.Fprintf(&b, "%-14s %7.1f   %-10s %8.0f\n", "125% hours", worker.Hours125, "125% ₪", worker.ExtraHoursSal)
+	fmt.Fprintf(&b, "%-14s %7.0f   %-10s %8.0f\n", "work days", worker.WorkDays, "travel ₪", worker.TransExpanses)
+	fmt.Fprintf(&b, "%-14s %7.1f   %-10s %8.0f\n", "sick days", worker.SickDays, "monthly ₪", worker.MonthlySal)
+	fmt.Fprintf(&b, "%-14s %7.1f   %-10s %8.0f\n", "vacation days", worker.VacDays, "total ₪", worker.TotalSal)
+
+	fmt.Fprintf(&b, "\nAdjustments\n")
+	for i, field := range adjustmentFields {
+		value := strconv.FormatFloat(*field.value(&adjustment), 'f', -1, 64)
+		if i == m.field && m.focus == focusEdit {
+			value = m.input + "_"
+		}
+		row := fmt.Sprintf("%-26s %10s", field.label, value)
+		if i == m.field && m.focus != focusWorkers {
+			row = tuiSelectedStyle.Render(row)
+		}
+		fmt.Fprintln(&b, row)
+	}
+
+	for _, anomaly := range m.anomalies[worker.WorkerID] {
+		fmt.Fprintf(&b, "\n%s", tuiAnomalyStyle.Render("! "+anomaly.Kind+": "+anomaly.Message))
+	}
+
+	return b.String()
+}
+
+// truncate shortens a name to the width of its column
+func truncate(name string, width int) string {
+	runes := []rune(name)
+	if len(runes) <= width {
+		return name
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+func (m *tuiModel) help() string {
+	switch m.focus {
+	case focusDetail:
+		return "↑/↓ select adjustment  enter edit  esc back  s save  q quit"
+	case focusEdit:
+		return "type a value, totals are recalculated  enter keep  esc cancel"
+	case focusQuit:
+		return "y quit without saving, any other key to go back"
+	default:
+		return "↑/↓ select worker  enter details  s save  q quit"
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/workspace"
+)
+
+const (
+	STATUS_FILE        = "status.json"
+	STATUS_MAX_ENTRIES = 100
+	DEFAULT_DEBOUNCE   = 5 * time.Second
+)
+
+// watchStatus is an entry of the watch status file
+type watchStatus struct {
+	File      string    `json:"file"`
+	Period    string    `json:"period,omitempty"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Version   int       `json:"version,omitempty"`
+	Archived  string    `json:"archived,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// watcher processes attendance exports dropped into a directory
+type watcher struct {
+	cfg      Config
+	dir      string
+	debounce time.Duration
+	done     <-chan struct{}
+
+	mu      sync.Mutex
+	pending map[string]*time.Timer
+	queue   chan string
+}
+
+// watchCommand watches a directory for attendance exports named
+// MM-YYYY.xlsx and runs the report of their period:
+//
+//	attendanceops watch [--debounce 5s] <dir>
+func watchCommand(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	debounce := fs.Duration("debounce", DEFAULT_DEBOUNCE, "time without changes before a file is processed")
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: attendanceops watch [flags] <dir>")
+	}
+
+	setLogLevel(cfg.LogLevel)
+
+	w := &watcher{
+		cfg:      cfg,
+		dir:      fs.Arg(0),
+		debounce: *debounce,
+		pending:  map[string]*time.Timer{},
+		queue:    make(chan string, 16),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return w.run(ctx)
+}
+
+func (w *watcher) run(ctx context.Context) error {
+	w.done = ctx.Done()
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher, error: %w", err)
+	}
+	defer func() {
+		if err := fsw.Close(); err != nil {
+			log.Error().Msgf("failed to close file watcher, error: %v", err)
+		}
+	}()
+
+	if err := fsw.Add(w.dir); err != nil {
+		return fmt.Errorf("failed to watch directory: %s, error: %w", w.dir, err)
+	}
+
+	log.Info().Msgf("Watching directory: %s for attendance exports", w.dir)
+
+	// process files in order, one at a time
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case path := <-w.queue:
+				w.process(path)
+			}
+		}
+	}()
+
+	// pick up exports dropped before the watch started
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %s, error: %w", w.dir, err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			w.schedule(filepath.Join(w.dir, entry.Name()))
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.stopPending()
+			wg.Wait()
+			log.Info().Msgf("Stopped watching directory: %s", w.dir)
+			return nil
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) {
+				w.schedule(event.Name)
+			}
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			log.Error().Msgf("file watcher error, error: %v", err)
+		}
+	}
+}
+
+// schedule queues a file once it has not changed for the debounce period
+func (w *watcher) schedule(path string) {
+	if !isAttendanceExport(path) {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if timer, ok := w.pending[path]; ok {
+		timer.Reset(w.debounce)
+		return
+	}
+
+	w.pending[path] = time.AfterFunc(w.debounce, func() {
+		w.mu.Lock()
+		delete(w.pending, path)
+		w.mu.Unlock()
+
+		if !w.stable(path) {
+			w.schedule(path)
+			return
+		}
+		select {
+		case w.queue <- path:
+		case <-w.done:
+		}
+	})
+}
+
+func (w *watcher) stopPending() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for path, timer := range w.pending {
+		timer.Stop()
+		delete(w.pending, path)
+	}
+}
+
+// stable reports whether the file size is unchanged over a short interval,
+// to skip files still being written without write events
+func (w *watcher) stable(path string) bool {
+	before, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	time.Sleep(w.debounce / 10)
+	after, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return before.Size() == after.Size() && before.ModTime().Equal(after.ModTime())
+}
+
+// process copies the export into its period inputs, runs the period report
+// and archives the processed export
+func (w *watcher) process(path string) {
+	status := watchStatus{File: filepath.Base(path), Status: "failed"}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Msgf("processing of file: %s panicked: %v\n%s", path, r, debug.Stack())
+			status.Status = "failed"
+			status.Error = fmt.Sprintf("processing panicked: %v", r)
+		}
+		status.UpdatedAt = time.Now()
+		if err := w.writeStatus(status); err != nil {
+			log.Error().Msgf("failed to write watch status, error: %v", err)
+		}
+	}()
+
+	if _, err := os.Stat(path); err != nil {
+		status.Error = fmt.Sprintf("file not found: %v", err)
+		return
+	}
+
+	name, ok := workspace.PeriodOfExport(path)
+	if !ok {
+		status.Error = "file name is not a MM-YYYY.xlsx attendance export"
+		log.Warn().Msgf("skipping file: %s, %s", path, status.Error)
+		return
+	}
+	status.Period = name
+
+	log.Info().Msgf("Processing attendance export: %s for period: %s", path, name)
+
+	period, err := workspace.New(w.cfg.WorkspacePath).Period(name)
+	if err == nil {
+		err = period.Init()
+	}
+	if err != nil {
+		status.Error = err.Error()
+		return
+	}
+
+	// replace the period export, keeping the replaced one in the archive,
+	// unless the export was dropped into the period inputs
+	input := filepath.Join(period.InputDir(), filepath.Base(path))
+	inPlace := sameFile(path, input)
+	if !inPlace {
+		if _, err := os.Stat(input); err == nil {
+			if _, err := archiveFile(input, period.ArchiveDir()); err != nil {
+				status.Error = err.Error()
+				return
+			}
+		}
+		if err := copyFile(path, input); err != nil {
+			status.Error = err.Error()
+			return
+		}
+	}
+
+	// run period report
+	cfg := w.cfg
+	cfg.Period = name
+	run, err := runPeriod(context.Background(), cfg)
+	if err != nil {
+		status.Error = err.Error()
+		log.Error().Msgf("failed to run period: %s, error: %v", name, err)
+		return
+	}
+	status.Version = run.Version
+	status.Status = "processed"
+	if inPlace {
+		return
+	}
+
+	// archive processed export
+	archived, err := archiveFile(path, period.ArchiveDir())
+	if err != nil {
+		status.Error = err.Error()
+		return
+	}
+	status.Archived = archived
+}
+
+// writeStatus adds the status entry to the status file of the watched
+// directory, keeping the latest entries
+func (w *watcher) writeStatus(status watchStatus) error {
+	path := filepath.Join(w.dir, STATUS_FILE)
+
+	var entries []watchStatus
+	content, err := os.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(content, &entries); err != nil {
+			log.Warn().Msgf("replacing unreadable status file: %s, error: %v", path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read status file: %s, error: %w", path, err)
+	}
+
+	entries = append(entries, status)
+	if len(entries) > STATUS_MAX_ENTRIES {
+		entries = entries[len(entries)-STATUS_MAX_ENTRIES:]
+	}
+
+	content, err = json.MarshalIndent(entries, "", "   ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal status, error: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write status file: %s, error: %w", tmpPath, err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// isAttendanceExport reports whether the path looks like a finished
+// workbook, skipping office lock files and hidden files
+func isAttendanceExport(path string) bool {
+	name := filepath.Base(path)
+	if strings.HasPrefix(name, "~$") || strings.HasPrefix(name, ".") {
+		return false
+	}
+	return strings.EqualFold(filepath.Ext(name), ".xlsx")
+}
+
+func sameFile(a, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(aInfo, bInfo)
+}
+
+// archiveFile moves a file into the archive directory under a timestamped
+// name and returns the archived path
+func archiveFile(path, archiveDir string) (string, error) {
+	timestamp := time.Now().Format("2006-01-02T15-04-05")
+	archivePath := filepath.Join(archiveDir, timestamp+"-"+filepath.Base(path))
+
+	if err := os.Rename(path, archivePath); err != nil {
+		// fall back to copy for archives on another file system
+		if err := copyFile(path, archivePath); err != nil {
+			return "", fmt.Errorf("failed to archive file: %s, error: %w", path, err)
+		}
+		if err := os.Remove(path); err != nil {
+			return "", fmt.Errorf("failed to remove archived file: %s, error: %w", path, err)
+		}
+	}
+
+	return archivePath, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %s, error: %w", src, err)
+	}
+	defer func() {
+		if err := in.Close(); err != nil {
+			log.Error().Msgf("failed to close file: %s, error: %v", src, err)
+		}
+	}()
+
+	// write to a temporary file, so readers never see a partial copy
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %s, error: %w", tmp, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy file: %s to %s, error: %w", src, tmp, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %s, error: %w", tmp, err)
+	}
+
+	return os.Rename(tmp, dst)
+}
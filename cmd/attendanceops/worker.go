@@ -0,0 +1,426 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/attendanceops"
+	"github.com/vgeshiktor/bhops/internal/jobs"
+	"github.com/vgeshiktor/bhops/internal/mail/smtp"
+	"github.com/vgeshiktor/bhops/internal/queue"
+	"github.com/vgeshiktor/bhops/internal/salaryops"
+	"github.com/vgeshiktor/bhops/internal/salaryops/publish"
+	"github.com/vgeshiktor/bhops/internal/salaryops/publish/email"
+	"github.com/vgeshiktor/bhops/internal/salaryops/publish/whatsapp"
+	"github.com/vgeshiktor/bhops/internal/workspace"
+)
+
+// Timeouts of the job handlers
+const (
+	BUILD_REPORT_TIMEOUT      = 5 * time.Minute
+	GENERATE_PAYSLIPS_TIMEOUT = 5 * time.Minute
+	SEND_PAYSLIP_TIMEOUT      = 1 * time.Minute
+)
+
+// jobHandlers runs the jobs of the queue against the configured workspace
+type jobHandlers struct {
+	cfg   Config
+	queue queue.Queue
+
+	mu         sync.Mutex
+	publishers map[string]publish.Publisher
+}
+
+// workerCommand runs the jobs of the queue until interrupted, along with
+// the scheduled jobs rules when a schedule is configured and the inbox
+// poller when inbox senders are configured:
+//
+//	attendanceops worker [--concurrency 4]
+func workerCommand(args []string) error {
+	fs := flag.NewFlagSet("worker", flag.ContinueOnError)
+	concurrency := fs.Int("concurrency", attendanceops.DefaultConcurrency, "number of jobs run at a time")
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	setLogLevel(cfg.LogLevel)
+
+	q, err := queue.OpenFileQueue(cfg.QueuePath)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	deadLetters, err := queue.NewDeadLetterStore(cfg.DeadLettersPath)
+	if err != nil {
+		return err
+	}
+
+	results, err := attendanceops.OpenFileResultStore(cfg.ResultsPath, attendanceops.DefaultResultRetention)
+	if err != nil {
+		return err
+	}
+
+	h := &jobHandlers{cfg: cfg, queue: q, publishers: map[string]publish.Publisher{}}
+	defer h.close()
+
+	registry := jobs.NewRegistry()
+	h.register(registry)
+
+	processor := attendanceops.NewMsgProcessor(q, registry.MsgHandler())
+	processor.Concurrency = *concurrency
+	processor.DeadLetters = deadLetters
+	processor.Idempotency = registry.Idempotency
+	processor.Results = results
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// enqueue scheduled jobs until interrupted
+	if cfg.SchedulePath != "" {
+		scheduler, err := newScheduler(cfg, q)
+		if err != nil {
+			return err
+		}
+		go scheduler.Run(ctx)
+	}
+
+	// ingest emailed attendance exports until interrupted
+	if cfg.InboxSenders != "" {
+		poller, err := newInboxPoller(cfg, q)
+		if err != nil {
+			return err
+		}
+		go poller.Run(ctx)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- processor.Run(context.Background())
+	}()
+
+	log.Info().Msgf("Running jobs of queue: %s, job types: %v", cfg.QueuePath, registry.Types())
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Info().Msg("Stopping worker, waiting for running jobs")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := processor.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to stop worker, error: %w", err)
+	}
+
+	log.Info().Msgf("Worker stopped, stats: %+v", processor.Stats())
+
+	return <-done
+}
+
+// enqueueCommand adds a typed job to the queue:
+//
+//	attendanceops enqueue [--correlation-id id] [--idempotency-key key] <type> [<payload json>]
+func enqueueCommand(args []string) error {
+	fs := flag.NewFlagSet("enqueue", flag.ContinueOnError)
+	correlationID := fs.String("correlation-id", "", "correlation id of the job")
+	idempotencyKey := fs.String("idempotency-key", "", "idempotency key of the job")
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return fmt.Errorf("usage: attendanceops enqueue [flags] <type> [<payload json>]")
+	}
+
+	setLogLevel(cfg.LogLevel)
+
+	// reject jobs the worker would fail for good
+	var payload json.RawMessage
+	if fs.NArg() == 2 {
+		payload = json.RawMessage(fs.Arg(1))
+	}
+	if err := jobs.ValidatePayload(fs.Arg(0), payload); err != nil {
+		return err
+	}
+
+	env, err := jobs.NewEnvelope(fs.Arg(0), payload, *correlationID)
+	if err != nil {
+		return err
+	}
+	env.IdempotencyKey = *idempotencyKey
+
+	q, err := queue.OpenFileQueue(cfg.QueuePath)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	if _, err := jobs.Enqueue(context.Background(), q, env); err != nil {
+		return err
+	}
+
+	fmt.Println(env.ID)
+
+	return nil
+}
+
+func (h *jobHandlers) register(registry *jobs.Registry) {
+	// reports and payslips are saved again on a redelivery, payslips are
+	// sent once
+	registry.Register(jobs.TypeBuildReport, h.buildReport, jobs.HandlerOptions{
+		Timeout:  BUILD_REPORT_TIMEOUT,
+		Delivery: jobs.AtLeastOnce,
+	})
+	registry.Register(jobs.TypeGeneratePayslips, h.generatePayslips, jobs.HandlerOptions{
+		Timeout:  GENERATE_PAYSLIPS_TIMEOUT,
+		Delivery: jobs.AtLeastOnce,
+	})
+	registry.Register(jobs.TypeSendPayslip, h.sendPayslip, jobs.HandlerOptions{
+		Timeout:  SEND_PAYSLIP_TIMEOUT,
+		Delivery: jobs.EffectivelyOnce,
+	})
+	registry.Register(jobs.TypeSendPayslips, h.sendPayslips, jobs.HandlerOptions{
+		Timeout:  SEND_PAYSLIP_TIMEOUT,
+		Delivery: jobs.AtLeastOnce,
+	})
+}
+
+// buildReport builds the attendance report of a workspace period
+func (h *jobHandlers) buildReport(ctx context.Context, env jobs.Envelope) error {
+	var payload jobs.BuildReportPayload
+	if err := env.DecodePayload(&payload); err != nil {
+		return attendanceops.Permanent(err)
+	}
+
+	cfg := h.cfg
+	cfg.Period = payload.Period
+	_, err := runPeriod(ctx, cfg)
+	return err
+}
+
+// generatePayslips computes the payroll of a workspace period from its
+// latest attendance report and saves the payslips next to it
+func (h *jobHandlers) generatePayslips(ctx context.Context, env jobs.Envelope) error {
+	var payload jobs.GeneratePayslipsPayload
+	if err := env.DecodePayload(&payload); err != nil {
+		return attendanceops.Permanent(err)
+	}
+
+	period, err := h.period(payload.Period)
+	if err != nil {
+		return err
+	}
+
+	workersPath, err := period.LatestOutput(SALARY_DETAILS_JSON)
+	if err != nil {
+		return err
+	}
+	workers, err := attendanceops.LoadWorkers(workersPath)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	payroll := salaryops.Compute(period.Name, workers)
+	if err := salaryops.SavePayroll(payroll, filepath.Join(period.OutputDir(), salaryops.PayrollFile)); err != nil {
+		return err
+	}
+
+	_, err = salaryops.SavePayslips(ctx, payroll, filepath.Join(period.OutputDir(), salaryops.PayslipsDir))
+	return err
+}
+
+// sendPayslip publishes the saved payslip of a worker on a channel
+func (h *jobHandlers) sendPayslip(ctx context.Context, env jobs.Envelope) error {
+	var payload jobs.SendPayslipPayload
+	if err := env.DecodePayload(&payload); err != nil {
+		return attendanceops.Permanent(err)
+	}
+	if payload.Channel == "" {
+		payload.Channel = publish.ChannelEmail
+	}
+
+	period, err := h.period(payload.Period)
+	if err != nil {
+		return err
+	}
+
+	payroll, err := salaryops.LoadPayroll(filepath.Join(period.OutputDir(), salaryops.PayrollFile))
+	if err != nil {
+		return err
+	}
+
+	// publish the payroll of the single worker
+	workerPayroll := &salaryops.Payroll{Period: payroll.Period}
+	for _, payslip := range payroll.Payslips {
+		if payslip.WorkerID == payload.WorkerID {
+			workerPayroll.Payslips = append(workerPayroll.Payslips, payslip)
+		}
+	}
+	if len(workerPayroll.Payslips) == 0 {
+		return attendanceops.Permanent(fmt.Errorf("worker: %s not found in payroll of period: %s",
+			payload.WorkerID, payroll.Period))
+	}
+
+	contacts, err := salaryops.LoadContacts(h.cfg.ContactsPath)
+	if err != nil {
+		return err
+	}
+
+	templates, err := salaryops.LoadTemplates(h.cfg.TemplatesPath)
+	if err != nil {
+		return attendanceops.Permanent(err)
+	}
+
+	publisher, err := h.publisher(payload.Channel)
+	if err != nil {
+		return err
+	}
+
+	paths := map[string]string{
+		payload.WorkerID: salaryops.PayslipPath(
+			filepath.Join(period.OutputDir(), salaryops.PayslipsDir), payroll.Period, payload.WorkerID),
+	}
+	results := salaryops.PublishPayslips(ctx, workerPayroll, paths, contacts, []publish.Publisher{publisher}, templates)
+	for _, result := range results {
+		switch result.Status {
+		case salaryops.StatusSkipped:
+			return attendanceops.Permanent(fmt.Errorf("no %s contact for worker: %s", payload.Channel, payload.WorkerID))
+		case salaryops.StatusFailed:
+			// retry transient failures, such as throttling, after the
+			// delay requested by the mail backend
+			if !publish.IsTemporary(result.Err) {
+				return attendanceops.Permanent(result.Err)
+			}
+			return attendanceops.RetryAfter(result.Err, publish.RetryDelay(result.Err))
+		}
+	}
+
+	return nil
+}
+
+// sendPayslips enqueues a send payslip job for every worker in the payroll
+// of a period, each worker payslip is sent once even when the jobs are
+// enqueued again
+func (h *jobHandlers) sendPayslips(ctx context.Context, env jobs.Envelope) error {
+	var payload jobs.SendPayslipsPayload
+	if err := env.DecodePayload(&payload); err != nil {
+		return attendanceops.Permanent(err)
+	}
+	if payload.Channel == "" {
+		payload.Channel = publish.ChannelEmail
+	}
+
+	period, err := h.period(payload.Period)
+	if err != nil {
+		return err
+	}
+
+	payroll, err := salaryops.LoadPayroll(filepath.Join(period.OutputDir(), salaryops.PayrollFile))
+	if err != nil {
+		return err
+	}
+
+	for _, payslip := range payroll.Payslips {
+		job, err := jobs.NewEnvelope(jobs.TypeSendPayslip, jobs.SendPayslipPayload{
+			Period:   payload.Period,
+			WorkerID: payslip.WorkerID,
+			Channel:  payload.Channel,
+		}, env.CorrelationID)
+		if err != nil {
+			return err
+		}
+		job.IdempotencyKey = fmt.Sprintf("%s:%s:%s:%s",
+			jobs.TypeSendPayslip, payroll.Period, payslip.WorkerID, payload.Channel)
+
+		if _, err := jobs.Enqueue(ctx, h.queue, job); err != nil {
+			return err
+		}
+	}
+
+	log.Ctx(ctx).Info().Msgf("Enqueued %d %s payslips of period: %s",
+		len(payroll.Payslips), payload.Channel, payroll.Period)
+
+	return nil
+}
+
+func (h *jobHandlers) period(name string) (*workspace.Period, error) {
+	period, err := workspace.New(h.cfg.WorkspacePath).Period(name)
+	if err != nil {
+		return nil, attendanceops.Permanent(err)
+	}
+	return period, nil
+}
+
+// publisher returns the publisher of a channel, created on first use and
+// shared by the jobs of the worker
+func (h *jobHandlers) publisher(channel string) (publish.Publisher, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if publisher, ok := h.publishers[channel]; ok {
+		return publisher, nil
+	}
+
+	var publisher publish.Publisher
+	var err error
+	switch channel {
+	case publish.ChannelEmail:
+		publisher, err = email.NewPublisher(email.Options{
+			Backend:        h.cfg.MailBackend,
+			AuthFlow:       h.cfg.GraphAuthFlow,
+			TenantID:       h.cfg.GraphTenantID,
+			ClientID:       h.cfg.GraphClientID,
+			Mailbox:        h.cfg.GraphMailbox,
+			TokenCachePath: h.cfg.GraphTokenCachePath,
+			SMTP: smtp.Config{
+				Addr:     h.cfg.SMTPAddr,
+				Security: h.cfg.SMTPSecurity,
+				Username: h.cfg.SMTPUsername,
+				From:     h.cfg.SMTPFrom,
+			},
+		})
+	case publish.ChannelWhatsApp:
+		publisher, err = whatsapp.NewPublisher(
+			h.cfg.ChromeDriverPath, whatsapp.DefaultPort, whatsapp.DefaultLoginWait)
+		if errors.Is(err, whatsapp.ErrNoChromeDriver) {
+			err = attendanceops.Permanent(fmt.Errorf("%w, set --chromedriver or %sCHROMEDRIVER",
+				whatsapp.ErrNoChromeDriver, ENV_PREFIX))
+		}
+	default:
+		return nil, attendanceops.Permanent(fmt.Errorf("unknown publish channel: %s", channel))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s publisher, error: %w", channel, err)
+	}
+	h.publishers[channel] = publisher
+
+	return publisher, nil
+}
+
+func (h *jobHandlers) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for channel, publisher := range h.publishers {
+		if err := publisher.Close(); err != nil {
+			log.Error().Msgf("failed to close %s publisher, error: %v", channel, err)
+		}
+	}
+}
@@ -1,9 +1,344 @@
 package main
 
 import (
-    "fmt"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/attendanceops"
+	"github.com/vgeshiktor/bhops/internal/redact"
+	"github.com/vgeshiktor/bhops/internal/salaryops"
+	"github.com/vgeshiktor/bhops/internal/salaryops/publish"
+	"github.com/vgeshiktor/bhops/internal/salaryops/publish/email"
+	"github.com/vgeshiktor/bhops/internal/salaryops/publish/whatsapp"
+	"github.com/vgeshiktor/bhops/internal/workspace"
+)
+
+const (
+	SALARY_DETAILS_JSON = "salary_details.json"
 )
 
+// commands of salaryops
+var commands = map[string]func(args []string) error{
+	"compute":  computeCommand,
+	"payslips": payslipsCommand,
+	"publish":  publishCommand,
+	"run":      runCommand,
+}
+
+// options holds the flags shared by the salaryops commands
+type options struct {
+	workers      string
+	period       string
+	payroll      string
+	payslips     string
+	contacts     string
+	channels     string
+	chromeDriver string
+	loginWait    time.Duration
+	email        email.Options
+	templates    string
+	preview      string
+	report       string
+	month        string
+	workspace    string
+	logLevel     string
+}
+
 func main() {
-    fmt.Println("SalaryOPS Service is starting...")
+	// keep access tokens and secrets out of the logs
+	log.Logger = log.Output(redact.NewWriter(os.Stderr))
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := command(os.Args[2:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Error().Msgf("%v", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: salaryops <compute|payslips|publish|run> [flags]")
+}
+
+// parseOptions registers the shared flags and resolves the paths of a
+// workspace period, when --month is set
+func parseOptions(name string, args []string) (*options, error) {
+	o := &options{}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&o.workers, "workers", SALARY_DETAILS_JSON, "attendance report workers JSON file")
+	fs.StringVar(&o.period, "period", time.Now().AddDate(0, -1, 0).Format(workspace.PeriodLayout), "payroll period, YYYY-MM")
+	fs.StringVar(&o.payroll, "payroll", salaryops.PayrollFile, "payroll JSON file")
+	fs.StringVar(&o.payslips, "payslips", salaryops.PayslipsDir, "payslips directory")
+	fs.StringVar(&o.contacts, "contacts", "config/contacts.json", "worker contacts JSON file")
+	fs.StringVar(&o.channels, "channels", publish.ChannelEmail, "comma separated publish channels: email, whatsapp")
+	fs.StringVar(&o.chromeDriver, "chromedriver", "", "ChromeDriver path for the whatsapp channel (env: "+whatsapp.ENV_CHROMEDRIVER+")")
+	fs.DurationVar(&o.loginWait, "whatsapp-login-wait", whatsapp.DefaultLoginWait, "time to scan the WhatsApp QR code")
+	fs.StringVar(&o.email.Backend, "mail-backend", email.DEFAULT_BACKEND, "email backend: graph, smtp")
+	fs.StringVar(&o.email.AuthFlow, "graph-auth-flow", email.DEFAULT_AUTH_FLOW, "graph authentication flow: client_credentials, device_code")
+	fs.StringVar(&o.email.TenantID, "graph-tenant-id", "", "graph tenant id (env: "+email.ENV_TENANT_ID+")")
+	fs.StringVar(&o.email.ClientID, "graph-client-id", "", "graph application client id (env: "+email.ENV_CLIENT_ID+")")
+	fs.StringVar(&o.email.Mailbox, "graph-mailbox", "", "mailbox sending the emails (env: "+email.ENV_MAILBOX+")")
+	fs.StringVar(&o.email.TokenCachePath, "graph-token-cache", email.DEFAULT_TOKEN_CACHE, "encrypted graph token cache file")
+	fs.StringVar(&o.email.SMTP.Addr, "smtp-addr", "", "smtp server host:port (env: "+email.ENV_SMTP_ADDR+")")
+	fs.StringVar(&o.email.SMTP.Security, "smtp-security", email.DEFAULT_SMTP_SECURITY, "smtp connection security: starttls, tls, none")
+	fs.StringVar(&o.email.SMTP.Username, "smtp-username", "", "smtp username (env: "+email.ENV_SMTP_USERNAME+"), the password is read from "+email.ENV_SMTP_PASSWORD)
+	fs.StringVar(&o.email.SMTP.From, "smtp-from", "", "sender address of the smtp emails (env: "+email.ENV_SMTP_FROM+"), the username by default")
+	fs.StringVar(&o.templates, "templates", "", "payslip message templates dir, built-in templates by default")
+	fs.StringVar(&o.preview, "preview", "", "render the payslip messages to this dir instead of sending them")
+	fs.StringVar(&o.report, "report", "", "send report JSON file, default: "+salaryops.SendReportFile+" in the payslips or preview dir")
+	fs.StringVar(&o.month, "month", "", "use the latest run of a workspace period, YYYY-MM")
+	fs.StringVar(&o.workspace, "workspace", ".", "workspace root with a YYYY-MM directory per period")
+	fs.StringVar(&o.logLevel, "log-level", zerolog.LevelInfoValue, "log level: debug, info, warn, error")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	logLevel, err := zerolog.ParseLevel(o.logLevel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level: %s, error: %w", o.logLevel, err)
+	}
+	zerolog.SetGlobalLevel(logLevel)
+
+	if o.month == "" {
+		return o, nil
+	}
+
+	// payroll files are kept next to the period outputs
+	period, err := workspace.New(o.workspace).Period(o.month)
+	if err != nil {
+		return nil, err
+	}
+	if o.workers, err = period.LatestOutput(SALARY_DETAILS_JSON); err != nil {
+		return nil, err
+	}
+	o.period = period.Name
+	o.payroll = filepath.Join(period.OutputDir(), salaryops.PayrollFile)
+	o.payslips = filepath.Join(period.OutputDir(), salaryops.PayslipsDir)
+
+	return o, nil
+}
+
+// computeCommand computes the gross pay lines of the attendance report
+// workers:
+//
+//	salaryops compute [--workers salary_details.json] [--payroll payroll.json]
+func computeCommand(args []string) error {
+	o, err := parseOptions("compute", args)
+	if err != nil {
+		return err
+	}
+
+	_, err = compute(o)
+	return err
+}
+
+// payslipsCommand saves a payslip workbook per worker of the payroll:
+//
+//	salaryops payslips [--payroll payroll.json] [--payslips payslips]
+func payslipsCommand(args []string) error {
+	o, err := parseOptions("payslips", args)
+	if err != nil {
+		return err
+	}
+
+	payroll, err := salaryops.LoadPayroll(o.payroll)
+	if err != nil {
+		return err
+	}
+
+	_, err = savePayslips(o, payroll)
+	return err
+}
+
+// publishCommand publishes the payslips to the workers on the configured
+// channels, or renders the messages to a dir with --preview:
+//
+//	salaryops publish [--channels email,whatsapp] [--contacts contacts.json] [--preview dir]
+func publishCommand(args []string) error {
+	o, err := parseOptions("publish", args)
+	if err != nil {
+		return err
+	}
+
+	payroll, err := salaryops.LoadPayroll(o.payroll)
+	if err != nil {
+		return err
+	}
+
+	// payslips saved by the payslips command
+	paths := map[string]string{}
+	for _, payslip := range payroll.Payslips {
+		path := salaryops.PayslipPath(o.payslips, payroll.Period, payslip.WorkerID)
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("payslip of worker: %s not found, run payslips first, error: %w", payslip.WorkerID, err)
+		}
+		paths[payslip.WorkerID] = path
+	}
+
+	return publishPayslips(o, payroll, paths)
+}
+
+// runCommand computes the payroll, saves the payslips and publishes them:
+//
+//	salaryops run [--month 2025-02] [--channels email]
+func runCommand(args []string) error {
+	o, err := parseOptions("run", args)
+	if err != nil {
+		return err
+	}
+
+	payroll, err := compute(o)
+	if err != nil {
+		return err
+	}
+
+	paths, err := savePayslips(o, payroll)
+	if err != nil {
+		return err
+	}
+
+	return publishPayslips(o, payroll, paths)
+}
+
+func compute(o *options) (*salaryops.Payroll, error) {
+	workers, err := attendanceops.LoadWorkers(o.workers)
+	if err != nil {
+		return nil, err
+	}
+
+	payroll := salaryops.Compute(o.period, workers)
+	if err := salaryops.SavePayroll(payroll, o.payroll); err != nil {
+		return nil, err
+	}
+
+	log.Info().Msgf("Payroll of period: %s for %d workers, gross: %.0f, saved: %s",
+		payroll.Period, len(payroll.Payslips), payroll.Gross, o.payroll)
+
+	return payroll, nil
+}
+
+func savePayslips(o *options, payroll *salaryops.Payroll) (map[string]string, error) {
+	paths, err := salaryops.SavePayslips(context.Background(), payroll, o.payslips)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Msgf("Saved %d payslips: %s", len(paths), o.payslips)
+
+	return paths, nil
+}
+
+func publishPayslips(o *options, payroll *salaryops.Payroll, paths map[string]string) error {
+	contacts, err := salaryops.LoadContacts(o.contacts)
+	if err != nil {
+		return err
+	}
+
+	templates, err := salaryops.LoadTemplates(o.templates)
+	if err != nil {
+		return err
+	}
+
+	// create publishers of the configured channels
+	var publishers []publish.Publisher
+	defer func() {
+		for _, publisher := range publishers {
+			if err := publisher.Close(); err != nil {
+				log.Error().Msgf("failed to close %s publisher, error: %v", publisher.Channel(), err)
+			}
+		}
+	}()
+	for _, channel := range strings.Split(o.channels, ",") {
+		channel = strings.TrimSpace(channel)
+		if channel != publish.ChannelEmail && channel != publish.ChannelWhatsApp {
+			return fmt.Errorf("unknown publish channel: %s", channel)
+		}
+
+		// previews are rendered to files
+		var publisher publish.Publisher
+		if o.preview != "" {
+			publisher, err = publish.NewPreviewPublisher(channel, o.preview)
+		} else {
+			publisher, err = newPublisher(o, channel)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create %s publisher, error: %w", channel, err)
+		}
+		publishers = append(publishers, publisher)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	results := salaryops.PublishPayslips(ctx, payroll, paths, contacts, publishers, templates)
+
+	// save and print the send report
+	report := salaryops.NewSendReport(payroll.Period, o.preview != "", results)
+	reportPath := o.report
+	if reportPath == "" {
+		reportPath = filepath.Join(o.payslips, salaryops.SendReportFile)
+		if o.preview != "" {
+			reportPath = filepath.Join(o.preview, salaryops.SendReportFile)
+		}
+	}
+	if err := salaryops.SaveSendReport(report, reportPath); err != nil {
+		return err
+	}
+	printSendReport(report)
+
+	log.Info().Msgf("Published %d payslips, %d failed, %d skipped, report: %s",
+		report.Sent, report.Failed, report.Skipped, reportPath)
+
+	if report.Failed > 0 {
+		return fmt.Errorf("failed to publish %d payslips", report.Failed)
+	}
+
+	return nil
+}
+
+// newPublisher creates the publisher of a channel
+func newPublisher(o *options, channel string) (publish.Publisher, error) {
+	switch channel {
+	case publish.ChannelEmail:
+		return email.NewPublisher(o.email)
+	case publish.ChannelWhatsApp:
+		return whatsapp.NewPublisher(o.chromeDriver, whatsapp.DefaultPort, o.loginWait)
+	default:
+		return nil, fmt.Errorf("unknown publish channel: %s", channel)
+	}
+}
+
+// printSendReport prints the publish results of the report
+func printSendReport(report *salaryops.SendReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\tCHANNEL\tSTATUS\tTO\tERROR\t  NAME\n")
+	for _, result := range report.Results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t  %s\n",
+			result.WorkerID, result.Channel, result.Status, result.To, result.Error, result.Name)
+	}
+	fmt.Fprintf(w, "\nsent: %d, failed: %d, skipped: %d\n", report.Sent, report.Failed, report.Skipped)
+	w.Flush()
 }
@@ -0,0 +1,152 @@
+package attendanceops
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Adjustment holds the monthly manual adjustments of a worker, kept in the
+// worker details file
+type Adjustment struct {
+	Holidays           float64 `json:"holidays"`
+	HolidayPresent     float64 `json:"holiday_present"`
+	HoursAdjustment    float64 `json:"hours_adjustment"`
+	Hours125Adjustment float64 `json:"hours_125_adjustment"`
+	VacDaysAdjustment  float64 `json:"vac_days_adjustment"`
+}
+
+// Adjustment returns the adjustments applied to a worker of the report
+func (a *AttendanceReport) Adjustment(workerID string) (Adjustment, bool) {
+	for _, worker := range a.workers {
+		if worker.WorkerID != workerID {
+			continue
+		}
+
+		adjustment := worker.adjustment
+		adjustment.Holidays = worker.Holidays
+		adjustment.HolidayPresent = worker.HolidayPresent
+		return adjustment, true
+	}
+
+	return Adjustment{}, false
+}
+
+// ApplyAdjustment replaces the adjustments of a worker, recalculates the
+// worker totals and updates the worker details saved by SaveWorkerDetails.
+// Holidays of non-attendance workers can not be adjusted.
+func (a *AttendanceReport) ApplyAdjustment(workerID string, adjustment Adjustment) error {
+	return a.ApplyAdjustments(map[string]Adjustment{workerID: adjustment})
+}
+
+// ApplyAdjustments applies the adjustments by worker id in worker id order,
+// see ApplyAdjustment. Every adjustment is checked first, so none is
+// applied when one of them fails.
+func (a *AttendanceReport) ApplyAdjustments(adjustments map[string]Adjustment) error {
+	workerIDs := make([]string, 0, len(adjustments))
+	for workerID := range adjustments {
+		workerIDs = append(workerIDs, workerID)
+	}
+	sort.Strings(workerIDs)
+
+	indexes := make([]int, len(workerIDs))
+	for i, workerID := range workerIDs {
+		index, err := a.adjustable(workerID, adjustments[workerID])
+		if err != nil {
+			return err
+		}
+		indexes[i] = index
+	}
+
+	for i, workerID := range workerIDs {
+		adjustment := adjustments[workerID]
+		worker := &a.workers[indexes[i]]
+
+		// replace the previously applied adjustments
+		worker.Hours += adjustment.HoursAdjustment - worker.adjustment.HoursAdjustment
+		worker.Hours125 += adjustment.Hours125Adjustment - worker.adjustment.Hours125Adjustment
+		worker.VacDays += adjustment.VacDaysAdjustment - worker.adjustment.VacDaysAdjustment
+		worker.Holidays = adjustment.Holidays
+		worker.HolidayPresent = adjustment.HolidayPresent
+		worker.adjustment = adjustment
+		worker.calculateTotals()
+
+		// keep adjustments in worker details
+		details := a.workerDetails[workerID]
+		if !worker.reportedHolidays {
+			details.Holidays = adjustment.Holidays
+			details.HolidayPresent = adjustment.HolidayPresent
+		}
+		details.HoursAdjustment = adjustment.HoursAdjustment
+		details.Hours125Adjustment = adjustment.Hours125Adjustment
+		details.VacDaysAdjustment = adjustment.VacDaysAdjustment
+		a.workerDetails[workerID] = details
+	}
+
+	return nil
+}
+
+// adjustable returns the index of the worker the adjustment can be
+// applied to
+func (a *AttendanceReport) adjustable(workerID string, adjustment Adjustment) (int, error) {
+	if _, ok := a.workerDetails[workerID]; !ok {
+		return -1, fmt.Errorf("worker details not found for workerID: %s", workerID)
+	}
+
+	for i, worker := range a.workers {
+		if worker.WorkerID != workerID {
+			continue
+		}
+
+		// holidays of non-attendance workers are set by their report and
+		// would be lost when the report is created again
+		if worker.reportedHolidays && (adjustment.Holidays != worker.Holidays ||
+			adjustment.HolidayPresent != worker.HolidayPresent) {
+			return -1, fmt.Errorf("holidays of worker: %s are set by the non-attendance report", workerID)
+		}
+
+		return i, nil
+	}
+
+	return -1, fmt.Errorf("worker: %s not found in attendance report", workerID)
+}
+
+// SaveWorkerDetails saves the worker details, including applied
+// adjustments, in the worker details file format
+func (a *AttendanceReport) SaveWorkerDetails(workerDetailsPath string) error {
+	// worker ids are the keys of the file
+	details := make(map[string]json.RawMessage, len(a.workerDetails))
+	for workerID, workerDetails := range a.workerDetails {
+		content, err := json.Marshal(workerDetails)
+		if err != nil {
+			return fmt.Errorf("failed to marshal worker details: %s, error: %w", workerID, err)
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(content, &fields); err != nil {
+			return fmt.Errorf("failed to unmarshal worker details: %s, error: %w", workerID, err)
+		}
+		delete(fields, "worker_id")
+
+		if details[workerID], err = json.Marshal(fields); err != nil {
+			return fmt.Errorf("failed to marshal worker details: %s, error: %w", workerID, err)
+		}
+	}
+
+	content, err := json.MarshalIndent(details, "", "   ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal worker details, error: %w", err)
+	}
+
+	// replace worker details file atomically
+	tmpPath := workerDetailsPath + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write worker details file: %s, error: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, workerDetailsPath); err != nil {
+		return fmt.Errorf("failed to rename worker details file: %s, error: %w", tmpPath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package attendanceops
+
+import "testing"
+
+func newAdjustmentsReport() *AttendanceReport {
+	report := &AttendanceReport{workerDetails: map[string]WorkerDetails{}}
+	for _, worker := range []Worker{
+		{WorkerID: "1", WorkerType: "hourly", Hours: 100, PerHour: 50},
+		{WorkerID: "2", WorkerType: "hourly", Hours: 80, PerHour: 50},
+		{WorkerID: "3", WorkerType: "monthly", Holidays: 2, reportedHolidays: true},
+	} {
+		worker.calculateTotals()
+		report.workers = append(report.workers, worker)
+		report.workerDetails[worker.WorkerID] = WorkerDetails{WorkerID: worker.WorkerID}
+	}
+	return report
+}
+
+func TestApplyAdjustments(t *testing.T) {
+	tests := []struct {
+		name        string
+		adjustments map[string]Adjustment
+		wantErr     bool
+		wantHours   map[string]float64
+	}{
+		{
+			name: "applied",
+			adjustments: map[string]Adjustment{
+				"1": {HoursAdjustment: 5},
+				"2": {HoursAdjustment: -10, Holidays: 1},
+				"3": {Holidays: 2, VacDaysAdjustment: 1},
+			},
+			wantHours: map[string]float64{"1": 105, "2": 70},
+		},
+		{
+			name: "holidays of non-attendance worker",
+			adjustments: map[string]Adjustment{
+				"1": {HoursAdjustment: 5},
+				"3": {Holidays: 4},
+			},
+			wantErr:   true,
+			wantHours: map[string]float64{"1": 100, "2": 80},
+		},
+		{
+			name: "unknown worker",
+			adjustments: map[string]Adjustment{
+				"1": {HoursAdjustment: 5},
+				"9": {HoursAdjustment: 5},
+			},
+			wantErr:   true,
+			wantHours: map[string]float64{"1": 100, "2": 80},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := newAdjustmentsReport()
+
+			err := report.ApplyAdjustments(tt.adjustments)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyAdjustments() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// no adjustment is applied when one of them fails
+			for _, worker := range report.Workers() {
+				want, ok := tt.wantHours[worker.WorkerID]
+				if !ok {
+					continue
+				}
+				if worker.Hours != want || worker.TotalSal != want*worker.PerHour {
+					t.Errorf("worker: %s hours: %v total: %v, want %v and %v",
+						worker.WorkerID, worker.Hours, worker.TotalSal, want, want*worker.PerHour)
+				}
+				adjustment, _ := report.Adjustment(worker.WorkerID)
+				details := report.workerDetails[worker.WorkerID]
+				if details.HoursAdjustment != adjustment.HoursAdjustment {
+					t.Errorf("worker: %s details adjustment: %v, want %v",
+						worker.WorkerID, details.HoursAdjustment, adjustment.HoursAdjustment)
+				}
+				if tt.wantErr && adjustment != (Adjustment{}) {
+					t.Errorf("worker: %s adjustment = %+v, want none", worker.WorkerID, adjustment)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package attendanceops
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/xuri/excelize/v2"
+)
+
+// Row offsets of the worker table cells checked for anomalies, relative to
+// the worker start row written by writeWorkerToSheet
+const (
+	regularHoursRowOffset = 2
+	hours125RowOffset     = 4
+	totalRowOffset        = 7
+	sickDaysRowOffset     = 12
+	vacDaysRowOffset      = 13
+)
+
+// Anomaly kinds
+const (
+	AnomalyZeroHours       = "zero_hours"
+	AnomalyHours125OverCap = "hours_125_over_cap"
+	AnomalySickDaysBalance = "sick_days_over_balance"
+	AnomalyVacDaysBalance  = "vac_days_over_balance"
+	AnomalyBelowMinWage    = "below_minimum_wage"
+	AnomalyTotalChange     = "total_change"
+)
+
+const anomalyCommentAuthor = "bhops"
+
+// AnomalyRules configures which worker values are highlighted in the
+// generated sheet. A zero limit disables the matching rule.
+type AnomalyRules struct {
+	ZeroHours            bool    `json:"zero_hours"`
+	MaxHours125          float64 `json:"max_hours_125"`
+	SickDaysBalance      bool    `json:"sick_days_balance"`
+	VacDaysBalance       bool    `json:"vac_days_balance"`
+	MinHourlyWage        float64 `json:"min_hourly_wage"`
+	MinMonthlyWage       float64 `json:"min_monthly_wage"`
+	TotalChangeThreshold float64 `json:"total_change_threshold"`
+}
+
+// Anomaly is a single problem found in a worker's monthly values
+type Anomaly struct {
+	WorkerID string `json:"worker_id"`
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Message  string `json:"message"`
+	Cell     string `json:"cell,omitempty"`
+	rangeRef string
+	format   excelize.ConditionalFormatOptions
+}
+
+func DefaultAnomalyRules() AnomalyRules {
+	return AnomalyRules{
+		ZeroHours:            true,
+		MaxHours125:          60,
+		SickDaysBalance:      true,
+		VacDaysBalance:       true,
+		MinHourlyWage:        32.3,
+		MinMonthlyWage:       6247.67,
+		TotalChangeThreshold: 0.2,
+	}
+}
+
+// LoadAnomalyRules reads anomaly rules from a JSON file, rules missing from
+// the file keep their default values
+func LoadAnomalyRules(anomalyRulesPath string) (AnomalyRules, error) {
+	rules := DefaultAnomalyRules()
+
+	// open anomaly rules file
+	file, err := os.Open(anomalyRulesPath)
+	if err != nil {
+		return rules, fmt.Errorf("failed to open anomaly rules file: %s, error: %w",
+			anomalyRulesPath, err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Error().Msgf("failed to close anomaly rules file: %s, error: %v",
+				anomalyRulesPath, err)
+		}
+	}()
+
+	// read anomaly rules file
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return rules, fmt.Errorf("failed to read anomaly rules file: %s, error: %w",
+			anomalyRulesPath, err)
+	}
+
+	// unmarshal anomaly rules
+	if err := json.Unmarshal(content, &rules); err != nil {
+		return rules, fmt.Errorf("failed to unmarshal anomaly rules, error: %w", err)
+	}
+
+	return rules, nil
+}
+
+func (a *AttendanceReport) SetAnomalyRules(rules AnomalyRules) {
+	a.anomalyRules = rules
+}
+
+// SetPreviousTotals sets last month's total salary per worker id, used to
+// highlight totals that changed more than the configured threshold
+func (a *AttendanceReport) SetPreviousTotals(totals map[string]float64) {
+	a.previousTotals = totals
+}
+
+// Anomalies returns the anomalies found for all workers in the report
+func (a *AttendanceReport) Anomalies() []Anomaly {
+	var anomalies []Anomaly
+
+	startRow := 1
+	for _, worker := range a.workers {
+		anomalies = append(anomalies, a.detectAnomalies(worker, startRow)...)
+		startRow += WorkerRowSpacing
+	}
+
+	return anomalies
+}
+
+func (a *AttendanceReport) detectAnomalies(worker Worker, startRow int) []Anomaly {
+	rules := a.anomalyRules
+	labels := a.language.Labels()
+	var anomalies []Anomaly
+
+	// cell values are written as text, so rules compare VALUE() of the cell
+	add := func(kind string, row, col int, condition string, msg string, args ...any) {
+		cell := cellName(startRow+row, col)
+		anomalies = append(anomalies, Anomaly{
+			WorkerID: worker.WorkerID,
+			Name:     worker.Name,
+			Kind:     kind,
+			Message:  fmt.Sprintf(msg, args...),
+			Cell:     cell,
+			rangeRef: cell + ":" + cell,
+			format: excelize.ConditionalFormatOptions{
+				Type:     "formula",
+				Criteria: strings.ReplaceAll(condition, "{}", "VALUE("+cell+")"),
+			},
+		})
+	}
+
+	// zero hours for an active worker
+	if rules.ZeroHours && worker.Hours+worker.Hours125 == 0 {
+		add(AnomalyZeroHours, totalRowOffset, 2,
+			"{}=0",
+			labels.ZeroHoursAnomaly)
+	}
+
+	// 125% hours above the legal cap
+	if rules.MaxHours125 > 0 && worker.Hours125 > rules.MaxHours125 {
+		add(AnomalyHours125OverCap, hours125RowOffset, 2,
+			"{}>"+formatFloat(rules.MaxHours125),
+			labels.Hours125Anomaly, worker.Hours125, rules.MaxHours125)
+	}
+
+	// sick days above balance
+	if rules.SickDaysBalance && worker.SickDaysBalance != nil &&
+		worker.SickDays > *worker.SickDaysBalance {
+		add(AnomalySickDaysBalance, sickDaysRowOffset, 2,
+			"{}>"+formatFloat(*worker.SickDaysBalance),
+			labels.SickDaysAnomaly, worker.SickDays, *worker.SickDaysBalance)
+	}
+
+	// vacation days above balance
+	if rules.VacDaysBalance && worker.VacDaysBalance != nil &&
+		worker.VacDays > *worker.VacDaysBalance {
+		add(AnomalyVacDaysBalance, vacDaysRowOffset, 2,
+			"{}>"+formatFloat(*worker.VacDaysBalance),
+			labels.VacDaysAnomaly, worker.VacDays, *worker.VacDaysBalance)
+	}
+
+	// rates below minimum wage
+	switch worker.WorkerType {
+	case "hourly", "daily":
+		if rules.MinHourlyWage > 0 && worker.PerHour < rules.MinHourlyWage {
+			add(AnomalyBelowMinWage, regularHoursRowOffset, 3,
+				"{}<"+formatFloat(rules.MinHourlyWage),
+				labels.HourlyWageAnomaly, worker.PerHour, rules.MinHourlyWage)
+		}
+	default:
+		if rules.MinMonthlyWage > 0 && worker.MonthlySal < rules.MinMonthlyWage {
+			add(AnomalyBelowMinWage, regularHoursRowOffset, 4,
+				"{}<"+formatFloat(rules.MinMonthlyWage),
+				labels.MonthlyWageAnomaly, worker.MonthlySal, rules.MinMonthlyWage)
+		}
+	}
+
+	// total differs from last month by more than the threshold
+	if previous, ok := a.previousTotals[worker.WorkerID]; ok &&
+		rules.TotalChangeThreshold > 0 && previous > 0 &&
+		math.Abs(worker.TotalSal-previous)/previous > rules.TotalChangeThreshold {
+		add(AnomalyTotalChange, totalRowOffset, 4,
+			"OR({}<"+formatFloat(previous*(1-rules.TotalChangeThreshold))+
+				",{}>"+formatFloat(previous*(1+rules.TotalChangeThreshold))+")",
+			labels.TotalChangeAnomaly, worker.TotalSal, previous, rules.TotalChangeThreshold*100)
+	}
+
+	return anomalies
+}
+
+// highlightAnomalies adds a conditional format with the anomaly style and a
+// comment to every cell of the worker table that breaks one of the anomaly
+// rules
+func (a *AttendanceReport) highlightAnomalies(f *excelize.File, styleID int, worker Worker, startRow int) {
+	for _, anomaly := range a.detectAnomalies(worker, startRow) {
+		format := anomaly.format
+		format.Format = &styleID
+
+		if err := f.SetConditionalFormat(
+			DefaultSheetName,
+			anomaly.rangeRef,
+			[]excelize.ConditionalFormatOptions{format}); err != nil {
+			log.Error().Msgf(
+				"failed to set conditional format for cell: %s, error: %v", anomaly.Cell, err)
+		}
+
+		if err := f.AddComment(DefaultSheetName, excelize.Comment{
+			Cell:   anomaly.Cell,
+			Author: anomalyCommentAuthor,
+			Text:   anomaly.Message,
+		}); err != nil {
+			log.Error().Msgf(
+				"failed to add comment for cell: %s, error: %v", anomaly.Cell, err)
+		}
+	}
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,283 @@
+package attendanceops
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func float(value float64) *float64 {
+	return &value
+}
+
+func TestDetectAnomalies(t *testing.T) {
+	hourly := Worker{WorkerID: "1", Name: "hourly", WorkerType: "hourly", Hours: 100, PerHour: 40, TotalSal: 4000}
+	monthly := Worker{WorkerID: "2", Name: "monthly", WorkerType: "monthly", Hours: 160, MonthlySal: 8000, TotalSal: 8000}
+
+	tests := []struct {
+		name           string
+		rules          *AnomalyRules
+		previousTotals map[string]float64
+		worker         func(w *Worker)
+		base           Worker
+
+		// want are the anomalies found, by kind, with their cell and
+		// message
+		want []Anomaly
+	}{
+		{
+			name: "no anomalies",
+			base: hourly,
+		},
+		{
+			name: "zero hours",
+			base: hourly,
+			worker: func(w *Worker) {
+				w.Hours = 0
+			},
+			want: []Anomaly{{Kind: AnomalyZeroHours, Cell: "B8", Message: "no working hours reported"}},
+		},
+		{
+			name: "125% hours over cap",
+			base: hourly,
+			worker: func(w *Worker) {
+				w.Hours125 = 70
+			},
+			want: []Anomaly{{Kind: AnomalyHours125OverCap, Cell: "B5", Message: "125% hours 70.0 above cap 60.0"}},
+		},
+		{
+			name: "125% hours at cap",
+			base: hourly,
+			worker: func(w *Worker) {
+				w.Hours125 = 60
+			},
+		},
+		{
+			name: "sick days over balance",
+			base: hourly,
+			worker: func(w *Worker) {
+				w.SickDays, w.SickDaysBalance = 5, float(3)
+			},
+			want: []Anomaly{{Kind: AnomalySickDaysBalance, Cell: "B13", Message: "sick days 5.0 above balance 3.0"}},
+		},
+		{
+			name: "sick days without balance",
+			base: hourly,
+			worker: func(w *Worker) {
+				w.SickDays = 5
+			},
+		},
+		{
+			name: "vacation days over balance",
+			base: hourly,
+			worker: func(w *Worker) {
+				w.VacDays, w.VacDaysBalance = 4, float(2.5)
+			},
+			want: []Anomaly{{Kind: AnomalyVacDaysBalance, Cell: "B14", Message: "vacation days 4.0 above balance 2.5"}},
+		},
+		{
+			name: "hourly rate below minimum wage",
+			base: hourly,
+			worker: func(w *Worker) {
+				w.PerHour = 30
+			},
+			want: []Anomaly{{Kind: AnomalyBelowMinWage, Cell: "C3", Message: "hourly rate 30.00 below minimum wage 32.30"}},
+		},
+		{
+			name: "monthly salary below minimum wage",
+			base: monthly,
+			worker: func(w *Worker) {
+				w.MonthlySal = 5000
+			},
+			want: []Anomaly{{Kind: AnomalyBelowMinWage, Cell: "D3", Message: "monthly salary 5000.00 below minimum wage 6247.67"}},
+		},
+		{
+			name:           "total changed from last month",
+			base:           monthly,
+			previousTotals: map[string]float64{"2": 6000},
+			want: []Anomaly{{
+				Kind:    AnomalyTotalChange,
+				Cell:    "D8",
+				Message: "total 8000 differs from last month 6000 by more than 20%",
+			}},
+		},
+		{
+			name:           "total within threshold",
+			base:           monthly,
+			previousTotals: map[string]float64{"2": 7000},
+		},
+		{
+			name:  "rules file",
+			rules: loadTestAnomalyRules(t, `{"max_hours_125": 40, "zero_hours": false}`),
+			base:  hourly,
+			worker: func(w *Worker) {
+				w.Hours, w.Hours125 = 0, 45
+			},
+			want: []Anomaly{{Kind: AnomalyHours125OverCap, Cell: "B5", Message: "125% hours 45.0 above cap 40.0"}},
+		},
+		{
+			name:           "disabled rules",
+			rules:          &AnomalyRules{},
+			previousTotals: map[string]float64{"1": 1000},
+			base:           hourly,
+			worker: func(w *Worker) {
+				w.Hours, w.Hours125, w.PerHour = 0, 70, 20
+				w.SickDays, w.SickDaysBalance = 5, float(0)
+			},
+		},
+		{
+			name: "several anomalies",
+			base: hourly,
+			worker: func(w *Worker) {
+				w.Hours125, w.PerHour = 70, 30
+			},
+			want: []Anomaly{
+				{Kind: AnomalyHours125OverCap, Cell: "B5", Message: "125% hours 70.0 above cap 60.0"},
+				{Kind: AnomalyBelowMinWage, Cell: "C3", Message: "hourly rate 30.00 below minimum wage 32.30"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &AttendanceReport{
+				anomalyRules:   DefaultAnomalyRules(),
+				previousTotals: tt.previousTotals,
+				language:       English,
+			}
+			if tt.rules != nil {
+				report.anomalyRules = *tt.rules
+			}
+			worker := tt.base
+			if tt.worker != nil {
+				tt.worker(&worker)
+			}
+
+			var got []Anomaly
+			for _, anomaly := range report.detectAnomalies(worker, 1) {
+				if anomaly.WorkerID != worker.WorkerID || anomaly.Name != worker.Name ||
+					anomaly.rangeRef != anomaly.Cell+":"+anomaly.Cell {
+					t.Errorf("anomaly of worker: %s name: %s range: %s, want worker: %s name: %s range of %s",
+						anomaly.WorkerID, anomaly.Name, anomaly.rangeRef, worker.WorkerID, worker.Name, anomaly.Cell)
+				}
+				got = append(got, Anomaly{Kind: anomaly.Kind, Cell: anomaly.Cell, Message: anomaly.Message})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("detectAnomalies() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func loadTestAnomalyRules(t *testing.T, content string) *AnomalyRules {
+	t.Helper()
+
+	rulesPath := filepath.Join(t.TempDir(), "anomaly_rules.json")
+	if err := os.WriteFile(rulesPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	rules, err := LoadAnomalyRules(rulesPath)
+	if err != nil {
+		t.Fatalf("LoadAnomalyRules() error = %v", err)
+	}
+	return &rules
+}
+
+func TestLoadAnomalyRules(t *testing.T) {
+	// rules missing from the file keep their defaults
+	want := DefaultAnomalyRules()
+	want.MaxHours125, want.ZeroHours = 40, false
+	if got := loadTestAnomalyRules(t, `{"max_hours_125": 40, "zero_hours": false}`); *got != want {
+		t.Errorf("LoadAnomalyRules() = %+v, want %+v", got, want)
+	}
+
+	rules, err := LoadAnomalyRules(filepath.Join("config", "anomaly_rules.json"))
+	if err != nil || rules != DefaultAnomalyRules() {
+		t.Errorf("LoadAnomalyRules() of config = %+v error = %v, want defaults", rules, err)
+	}
+
+	if _, err := LoadAnomalyRules(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("LoadAnomalyRules() of missing file error = nil, want error")
+	}
+
+	invalidPath := filepath.Join(t.TempDir(), "anomaly_rules.json")
+	if err := os.WriteFile(invalidPath, []byte(`{"max_hours_125": "sixty"}`), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err := LoadAnomalyRules(invalidPath); err == nil {
+		t.Errorf("LoadAnomalyRules() of invalid file error = nil, want error")
+	}
+}
+
+func TestAnomalies(t *testing.T) {
+	report := &AttendanceReport{anomalyRules: DefaultAnomalyRules(), language: English}
+	if anomalies := report.Anomalies(); len(anomalies) != 0 {
+		t.Errorf("Anomalies() of empty report = %+v, want none", anomalies)
+	}
+
+	// workers tables follow each other, anomaly cells are in the table of
+	// their worker
+	report.workers = []Worker{
+		{WorkerID: "1", WorkerType: "hourly", Hours: 100, PerHour: 40},
+		{WorkerID: "2", WorkerType: "hourly", PerHour: 40},
+	}
+	anomalies := report.Anomalies()
+	wantCell := cellName(1+WorkerRowSpacing+totalRowOffset, 2)
+	if len(anomalies) != 1 || anomalies[0].WorkerID != "2" || anomalies[0].Cell != wantCell {
+		t.Errorf("Anomalies() = %+v, want zero hours of worker: 2 at: %s", anomalies, wantCell)
+	}
+}
+
+func TestHighlightAnomalies(t *testing.T) {
+	report := &AttendanceReport{anomalyRules: DefaultAnomalyRules(), language: English}
+	worker := Worker{WorkerID: "1", WorkerType: "hourly", Hours125: 70, PerHour: 30}
+
+	f := excelize.NewFile()
+	defer f.Close()
+	styleID, err := f.NewConditionalStyle(AnomalyCellStyle())
+	if err != nil {
+		t.Fatalf("NewConditionalStyle() error = %v", err)
+	}
+
+	report.highlightAnomalies(f, styleID, worker, 1)
+
+	formats, err := f.GetConditionalFormats(DefaultSheetName)
+	if err != nil {
+		t.Fatalf("GetConditionalFormats() error = %v", err)
+	}
+	wantCriteria := map[string]string{
+		"B5:B5": "VALUE(B5)>60",
+		"C3:C3": "VALUE(C3)<32.3",
+	}
+	if len(formats) != len(wantCriteria) {
+		t.Errorf("conditional formats = %+v, want %v", formats, wantCriteria)
+	}
+	for rangeRef, criteria := range wantCriteria {
+		format := formats[rangeRef]
+		if len(format) != 1 || format[0].Type != "formula" || format[0].Criteria != criteria ||
+			format[0].Format == nil || *format[0].Format != styleID {
+			t.Errorf("conditional format of: %s = %+v, want formula %s with the anomaly style", rangeRef, format, criteria)
+		}
+	}
+
+	comments, err := f.GetComments(DefaultSheetName)
+	if err != nil {
+		t.Fatalf("GetComments() error = %v", err)
+	}
+	wantComments := map[string]string{
+		"B5": "125% hours 70.0 above cap 60.0",
+		"C3": "hourly rate 30.00 below minimum wage 32.30",
+	}
+	if len(comments) != len(wantComments) {
+		t.Errorf("comments = %+v, want %v", comments, wantComments)
+	}
+	for _, comment := range comments {
+		if comment.Author != anomalyCommentAuthor || comment.Text != wantComments[comment.Cell] {
+			t.Errorf("comment of: %s = %s by %s, want %s by %s",
+				comment.Cell, comment.Text, comment.Author, wantComments[comment.Cell], anomalyCommentAuthor)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"strconv"
 	"strings"
@@ -12,50 +13,63 @@ import (
 	"github.com/xuri/excelize/v2"
 )
 
+// attendanceRowLastCol is the last column read from a worker row of the
+// attendance export, the vacation days
+const attendanceRowLastCol = 21
+
 // Constants for sheet configuration
 const (
-    DefaultSheetName = "Sheet1"
-    DefaultColumnWidth = 20
-    WorkerRowSpacing = 18
+	DefaultSheetName   = "Sheet1"
+	DefaultColumnWidth = 20
+	WorkerRowSpacing   = 18
 )
 
 type WorkerDetails struct {
-	WorkerID           string  `json:"worker_id"`
-	Name               string  `json:"name"`
-	Type               string  `json:"worker_type"`
-	DailyHours         float64 `json:"daily_hours"`
-	PerHour            float64 `json:"per_hour"`
-	PerHour125         float64 `json:"per_hour_125"`
-	MonthlySal         float64 `json:"monthly_sal"`
-	TransExpanses      float64 `json:"trans_expanses"`
-	Holidays           float64 `json:"holidays"`
-	HolidayPresent     float64 `json:"holiday_present"`
-	HoursAdjustment    float64 `json:"hours_adjustment"`
-	Hours125Adjustment float64 `json:"hours_125_adjustment"`
-	VacDaysAdjustment float64 `json:"vac_days_adjustment"`
+	WorkerID           string   `json:"worker_id"`
+	Name               string   `json:"name"`
+	Type               string   `json:"worker_type"`
+	DailyHours         float64  `json:"daily_hours"`
+	PerHour            float64  `json:"per_hour"`
+	PerHour125         float64  `json:"per_hour_125"`
+	MonthlySal         float64  `json:"monthly_sal"`
+	TransExpanses      float64  `json:"trans_expanses"`
+	Holidays           float64  `json:"holidays"`
+	HolidayPresent     float64  `json:"holiday_present"`
+	HoursAdjustment    float64  `json:"hours_adjustment"`
+	Hours125Adjustment float64  `json:"hours_125_adjustment"`
+	VacDaysAdjustment  float64  `json:"vac_days_adjustment"`
+	SickDaysBalance    *float64 `json:"sick_days_balance,omitempty"`
+	VacDaysBalance     *float64 `json:"vac_days_balance,omitempty"`
+	Language           string   `json:"language,omitempty"`
 }
 
 type Worker struct {
-	WorkerID        string  `json:"id"`
-	Name            string  `json:"name"`
-	WorkerType      string  `json:"worker_type"`
-	DailyHours      float64 `json:"daily_hours"`
-	Hours           float64 `json:"hours"`
-	PerHour         float64 `json:"per_hour"`
-	RegularHoursSal float64 `json:"reg_hours_sal"`
-	Hours125        float64 `json:"hours_125"`
-	PerHour125      float64 `json:"per_hour_125"`
-	ExtraHoursSal   float64 `json:"extra_hours_sal"`
-	MonthlySal      float64 `json:"monthly_sal"`
-	TransExpanses   float64 `json:"trans_expanses"`
-	TotalHours      float64 `json:"total_hours"`
-	WorkDays        float64 `json:"work_days"`
-	Holidays        float64 `json:"holidays"`
-	HolidayPresent  float64 `json:"holiday_present"`
-	SickDays        float64 `json:"sick_days"`
-	VacDays         float64 `json:"vac_days"`
-	AbsenseHours    float64 `json:"absense_hours"`
-	TotalSal        float64 `json:"total_sal"`
+	WorkerID        string   `json:"id"`
+	Name            string   `json:"name"`
+	WorkerType      string   `json:"worker_type"`
+	DailyHours      float64  `json:"daily_hours"`
+	Hours           float64  `json:"hours"`
+	PerHour         float64  `json:"per_hour"`
+	RegularHoursSal float64  `json:"reg_hours_sal"`
+	Hours125        float64  `json:"hours_125"`
+	PerHour125      float64  `json:"per_hour_125"`
+	ExtraHoursSal   float64  `json:"extra_hours_sal"`
+	MonthlySal      float64  `json:"monthly_sal"`
+	TransExpanses   float64  `json:"trans_expanses"`
+	TotalHours      float64  `json:"total_hours"`
+	WorkDays        float64  `json:"work_days"`
+	Holidays        float64  `json:"holidays"`
+	HolidayPresent  float64  `json:"holiday_present"`
+	SickDays        float64  `json:"sick_days"`
+	VacDays         float64  `json:"vac_days"`
+	AbsenseHours    float64  `json:"absense_hours"`
+	TotalSal        float64  `json:"total_sal"`
+	SickDaysBalance *float64 `json:"sick_days_balance,omitempty"`
+	VacDaysBalance  *float64 `json:"vac_days_balance,omitempty"`
+	Language        string   `json:"language,omitempty"`
+	adjustment      Adjustment
+	// holidays come from the non-attendance report, not worker details
+	reportedHolidays bool
 }
 
 type AttendanceReport struct {
@@ -64,6 +78,11 @@ type AttendanceReport struct {
 	WorkerDetailsPath       string
 	workerDetails           map[string]WorkerDetails
 	workers                 []Worker
+	anomalyRules            AnomalyRules
+	previousTotals          map[string]float64
+	dashboard               bool
+	history                 []MonthlySummary
+	language                Language
 	// file                    *excelize.File
 }
 
@@ -75,6 +94,8 @@ func NewAttendanceReport(
 		AttendanceReportPath:    attendanceReportPath,
 		NonAttendanceReportPath: nonAttendanceReportPath,
 		WorkerDetailsPath:       workerDetailsPath,
+		anomalyRules:            DefaultAnomalyRules(),
+		language:                DefaultLanguage,
 	}
 }
 
@@ -107,6 +128,11 @@ func CreateAttendanceReport(
 		return nil, fmt.Errorf("failed to add non-attendance workers from: %s to monthly report, error: %w", nonAttendanceReportPath, err)
 	}
 
+	// calculate worker totals
+	for i := range attendanceReport.workers {
+		attendanceReport.workers[i].calculateTotals()
+	}
+
 	return attendanceReport, nil
 }
 
@@ -133,6 +159,49 @@ func SaveAttendanceReport(
 	return nil
 }
 
+// SaveAttendanceReportJSON saves the report workers as a JSON array, in
+// the same form as the non-attendance workers file
+func SaveAttendanceReportJSON(
+	attendanceReport *AttendanceReport,
+	attendanceReportPath string,
+) error {
+	content, err := json.MarshalIndent(attendanceReport.workers, "", "   ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal attendance report workers, error: %w", err)
+	}
+
+	if err := os.WriteFile(attendanceReportPath, content, 0o644); err != nil {
+		return fmt.Errorf("failed to save json file: %s, error: %w", attendanceReportPath, err)
+	}
+
+	return nil
+}
+
+// LoadWorkers loads workers saved by SaveAttendanceReportJSON
+func LoadWorkers(workersPath string) ([]Worker, error) {
+	content, err := os.ReadFile(workersPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workers file: %s, error: %w", workersPath, err)
+	}
+
+	var workers []Worker
+	if err := json.Unmarshal(content, &workers); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal workers file: %s, error: %w", workersPath, err)
+	}
+
+	return workers, nil
+}
+
+// Workers returns a copy of the report workers
+func (a *AttendanceReport) Workers() []Worker {
+	return append([]Worker(nil), a.workers...)
+}
+
+// SetLanguage sets the language of the report sheet labels
+func (a *AttendanceReport) SetLanguage(lang Language) {
+	a.language = lang
+}
+
 func (a *AttendanceReport) loadWorkerDetails(workerDetailsPath string) error {
 	// open worker details file
 	file, err := os.Open(workerDetailsPath)
@@ -196,13 +265,16 @@ func (a *AttendanceReport) addAttendanceWorkers(attendanceReportPath string,
 	// get start and end row for workers
 	startRow := 2
 	endRow := startRow + 5
+	if len(rows) < endRow {
+		return fmt.Errorf("sheet: %s has %d rows, expected at least: %d", sheet, len(rows), endRow)
+	}
 
 	// add workers to attendance report
 	for i := startRow; i < endRow; i++ {
 		// create worker report
 		worker, err := a.createWorkerReport(rows[i])
 		if err != nil {
-			return fmt.Errorf("failed to create worker report for worker id: %s, error: %w", rows[i][4], err)
+			return fmt.Errorf("failed to create worker report for row: %d, error: %w", i+1, err)
 		}
 
 		// add worker report to attendance report
@@ -213,38 +285,62 @@ func (a *AttendanceReport) addAttendanceWorkers(attendanceReportPath string,
 }
 
 func (a *AttendanceReport) createWorkerReport(row []string) (Worker, error) {
+	if len(row) <= attendanceRowLastCol {
+		return Worker{}, fmt.Errorf("row has %d cells, expected at least: %d", len(row), attendanceRowLastCol+1)
+	}
 	workerID := row[3]
 	if _, ok := a.workerDetails[workerID]; !ok {
 		return Worker{}, fmt.Errorf("worker details not found for workerID: %s", workerID)
 	}
+
+	// parse the row cells, keeping the first invalid cell
+	var parseErr error
+	parse := func(parseCell func(string) (float64, error), col int) float64 {
+		value, err := parseCell(row[col])
+		if err != nil && parseErr == nil {
+			parseErr = fmt.Errorf("invalid cell in column: %d of worker id: %s, error: %w", col+1, workerID, err)
+		}
+		return value
+	}
 	worker := Worker{
 		WorkerID:        workerID,
 		Name:            a.workerDetails[workerID].Name,
 		WorkerType:      a.workerDetails[workerID].Type,
 		DailyHours:      a.workerDetails[workerID].DailyHours,
-		Hours:           TimeStrToFloat64(row[10]) + a.workerDetails[workerID].HoursAdjustment,
+		Hours:           parse(TimeStrToFloat64, 10) + a.workerDetails[workerID].HoursAdjustment,
 		PerHour:         a.workerDetails[workerID].PerHour,
 		RegularHoursSal: 0,
-		Hours125:        TimeStrToFloat64(row[12]) + a.workerDetails[workerID].Hours125Adjustment,
+		Hours125:        parse(TimeStrToFloat64, 12) + a.workerDetails[workerID].Hours125Adjustment,
 		PerHour125:      a.workerDetails[workerID].PerHour125,
 		ExtraHoursSal:   0,
 		MonthlySal:      a.workerDetails[workerID].MonthlySal,
 		TransExpanses:   a.workerDetails[workerID].TransExpanses,
 		TotalHours:      0,
-		WorkDays:        StrToFloat64(row[7]),
+		WorkDays:        parse(StrToFloat64, 7),
 		Holidays:        a.workerDetails[workerID].Holidays,
 		HolidayPresent:  a.workerDetails[workerID].HolidayPresent,
-		SickDays:        StrToFloat64(row[20]),
-		VacDays:         StrToFloat64(row[21]) + 
+		SickDays:        parse(StrToFloat64, 20),
+		VacDays: parse(StrToFloat64, 21) +
 			a.workerDetails[workerID].VacDaysAdjustment,
 		AbsenseHours: func() float64 {
 			if a.workerDetails[workerID].Type == "monthly" {
-				return TimeStrToFloat64(row[15])
+				return parse(TimeStrToFloat64, 15)
 			} else {
 				return 0
 			}
 		}(),
-		TotalSal: 0,
+		TotalSal:        0,
+		SickDaysBalance: a.workerDetails[workerID].SickDaysBalance,
+		VacDaysBalance:  a.workerDetails[workerID].VacDaysBalance,
+		Language:        a.workerDetails[workerID].Language,
+		adjustment: Adjustment{
+			HoursAdjustment:    a.workerDetails[workerID].HoursAdjustment,
+			Hours125Adjustment: a.workerDetails[workerID].Hours125Adjustment,
+			VacDaysAdjustment:  a.workerDetails[workerID].VacDaysAdjustment,
+		},
+	}
+	if parseErr != nil {
+		return Worker{}, parseErr
 	}
 
 	return worker, nil
@@ -281,9 +377,27 @@ func (a *AttendanceReport) addNonAttendanceWorkers(
 			a.workerDetails[nonAttendanceWorkers[i].WorkerID].MonthlySal
 		nonAttendanceWorkers[i].TransExpanses =
 			a.workerDetails[nonAttendanceWorkers[i].WorkerID].TransExpanses
-		nonAttendanceWorkers[i].Hours = a.workerDetails[
-			nonAttendanceWorkers[i].WorkerID].DailyHours * 
+		nonAttendanceWorkers[i].Hours = a.workerDetails[nonAttendanceWorkers[i].WorkerID].DailyHours *
 			nonAttendanceWorkers[i].WorkDays
+		nonAttendanceWorkers[i].SickDaysBalance =
+			a.workerDetails[nonAttendanceWorkers[i].WorkerID].SickDaysBalance
+		nonAttendanceWorkers[i].VacDaysBalance =
+			a.workerDetails[nonAttendanceWorkers[i].WorkerID].VacDaysBalance
+		nonAttendanceWorkers[i].Language =
+			a.workerDetails[nonAttendanceWorkers[i].WorkerID].Language
+
+		// apply the worker details adjustments as for attendance workers,
+		// holidays are reported in the non-attendance report
+		details := a.workerDetails[nonAttendanceWorkers[i].WorkerID]
+		nonAttendanceWorkers[i].Hours += details.HoursAdjustment
+		nonAttendanceWorkers[i].Hours125 += details.Hours125Adjustment
+		nonAttendanceWorkers[i].VacDays += details.VacDaysAdjustment
+		nonAttendanceWorkers[i].adjustment = Adjustment{
+			HoursAdjustment:    details.HoursAdjustment,
+			Hours125Adjustment: details.Hours125Adjustment,
+			VacDaysAdjustment:  details.VacDaysAdjustment,
+		}
+		nonAttendanceWorkers[i].reportedHolidays = true
 	}
 
 	a.workers = append(a.workers, nonAttendanceWorkers...)
@@ -293,6 +407,23 @@ func (a *AttendanceReport) addNonAttendanceWorkers(
 }
 
 func (a *AttendanceReport) createExcelSheet() (*excelize.File, error) {
+	// create report sheet in the report language
+	f, err := a.newReportFile(a.workers, a.language)
+	if err != nil {
+		return nil, err
+	}
+
+	// add dashboard sheet
+	if a.dashboard {
+		if err := a.addDashboardSheet(f); err != nil {
+			return nil, fmt.Errorf("failed to add dashboard sheet, error: %w", err)
+		}
+	}
+
+	return f, nil
+}
+
+func (a *AttendanceReport) newReportFile(workers []Worker, lang Language) (*excelize.File, error) {
 	f := excelize.NewFile()
 
 	// Create a new sheet.
@@ -302,315 +433,270 @@ func (a *AttendanceReport) createExcelSheet() (*excelize.File, error) {
 		return nil, fmt.Errorf("failed to create new sheet, error: %w", err)
 	}
 
-	// Set the active sheet to the RTL sheet
+	// Set the active sheet to the report sheet
 	f.SetActiveSheet(index)
 
-	// Set the sheet view to RTL
-	RightToLeft := true
+	// Set the sheet view direction of the language, before the stream
+	// writer takes over the sheet
+	RightToLeft := lang.RightToLeft()
 	err = f.SetSheetView(sheetName, 0, &excelize.ViewOptions{
-		RightToLeft: &RightToLeft, // Set RightToLeft to true
+		RightToLeft: &RightToLeft,
 	})
 	if err != nil {
-		log.Error().Msgf("failed to set sheet view to RTL, error: %v", err)
+		log.Error().Msgf("failed to set sheet view direction, error: %v", err)
+	}
+
+	// register cell styles once for all workers
+	styles, err := newSheetStyles(f, RightToLeft)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cell styles, error: %w", err)
+	}
+
+	// rows are streamed to the sheet in order
+	sw, err := f.NewStreamWriter(sheetName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream writer, error: %w", err)
 	}
 
-	// Set the width of column A to DefaultColumnWidth
-	if err := f.SetColWidth(
-		sheetName,
-		"A",
-		"D",
-		DefaultColumnWidth); err != nil {
+	// Set the width of columns A to D to DefaultColumnWidth
+	if err := sw.SetColWidth(1, 4, DefaultColumnWidth); err != nil {
 		log.Error().Err(err)
 	}
 
 	// iterate over workers and add them to the sheet
+	labels := lang.Labels()
 	start_row := 1
-	for _, worker := range a.workers {
-		start_row = writeWorkerToSheet(f, worker, start_row)
+	for _, worker := range workers {
+		next_row, err := writeWorkerToSheet(sw, styles, labels, worker, start_row)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write worker: %s to sheet, error: %w", worker.WorkerID, err)
+		}
+
+		// highlight worker anomalies
+		a.highlightAnomalies(f, styles.anomaly, worker, start_row)
+
+		start_row = next_row
 	}
 
-	// set column width
+	// flush streamed rows to the sheet
+	if err := sw.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to flush stream writer, error: %w", err)
+	}
 
 	return f, nil
 }
 
-func writeWorkerToSheet(f *excelize.File, worker Worker, startRow int) int {
-	writeCell :=
-		func(row, col int, value string, style *excelize.Style) {
-			styleID, err := f.NewStyle(style)
-			if err != nil {
-				log.Error().Msgf("failed to create cell style for cell: %s, error: %v", cellName(row, col), err)
-			}
-
-			cell := cellName(row, col)
+// sheetStyles holds the style ids registered for the worker tables
+type sheetStyles struct {
+	title   int
+	header  int
+	numeric int
+	anomaly int
+}
 
-			if err := f.SetCellStyle(
-				"Sheet1",
-				cell,
-				cell,
-				styleID); err != nil {
-				log.Error().Msgf(
-					"failed to set cell style for cell: %s, error: %v", cell, err)
-			}
+func newSheetStyles(f *excelize.File, rightToLeft bool) (sheetStyles, error) {
+	var styles sheetStyles
+	var err error
 
-			if err := f.SetCellValue(
-				"Sheet1", cell, value); err != nil {
-				log.Error().Msgf(
-					"failed to set cell value for cell: %s, error: %v", cell, err)
-			}
-		}
+	// labels are aligned to the start of the reading direction
+	titleStyle, headerStyle := TitleCellStyle(), HeaderCellStyle()
+	if !rightToLeft {
+		titleStyle.Alignment = &ALIGN_LEFT
+		headerStyle.Alignment = &ALIGN_LEFT
+	}
 
-	writeCellFormula :=
-		func(row, col int, value string, style *excelize.Style) {
-			styleID, err := f.NewStyle(style)
-			if err != nil {
-				log.Error().Msgf("failed to create cell style for cell: %s, error: %v", cellName(row, col), err)
-			}
+	if styles.title, err = f.NewStyle(titleStyle); err != nil {
+		return styles, fmt.Errorf("failed to create title cell style, error: %w", err)
+	}
+	if styles.header, err = f.NewStyle(headerStyle); err != nil {
+		return styles, fmt.Errorf("failed to create header cell style, error: %w", err)
+	}
+	if styles.numeric, err = f.NewStyle(NumericCellStyle()); err != nil {
+		return styles, fmt.Errorf("failed to create numeric cell style, error: %w", err)
+	}
+	if styles.anomaly, err = f.NewConditionalStyle(AnomalyCellStyle()); err != nil {
+		return styles, fmt.Errorf("failed to create anomaly cell style, error: %w", err)
+	}
 
-			cell := cellName(row, col)
+	return styles, nil
+}
 
-			if err := f.SetCellStyle(
-				"Sheet1",
-				cell,
-				cell,
-				styleID); err != nil {
-				log.Error().Msgf(
-					"failed to set cell style for cell: %s, error: %v", cell, err)
-			}
+func writeWorkerToSheet(
+	sw *excelize.StreamWriter,
+	styles sheetStyles,
+	labels Labels,
+	worker Worker,
+	startRow int,
+) (int, error) {
+	value := func(value string, styleID int) excelize.Cell {
+		return excelize.Cell{StyleID: styleID, Value: value}
+	}
 
-			if err := f.SetCellFormula(
-				"Sheet1", cell, value); err != nil {
-				log.Error().Msgf(
-					"failed to set cell value for cell: %s, error: %v", cell, err)
-			}
-		}
+	formula := func(formula string, styleID int) excelize.Cell {
+		return excelize.Cell{StyleID: styleID, Formula: formula}
+	}
 
-	// worker name
-	writeCell(
-		startRow, 1,
-		worker.Name,
-		TitleCellStyle())
-
-	// writing title in the second row of the table
-	writeCell(
-		startRow+1, 1,
-		"",
-		HeaderCellStyle())
-
-	writeCell(
-		startRow+1, 2,
-		"שעות",
-		HeaderCellStyle())
-
-	writeCell(
-		startRow+1, 3,
-		"לשעה ₪",
-		HeaderCellStyle())
-
-	writeCell(
-		startRow+1, 4,
-		"סה״כ ₪",
-		HeaderCellStyle())
-
-	// Writing regular hours row in the 3rd row of the table
-	writeCell(
-		startRow+2, 1,
-		"ש.רגילות",
-		HeaderCellStyle())
-
-	writeCell(
-		startRow+2, 2,
-		strconv.FormatFloat(
-			worker.Hours, 'f', 0, 64),
-		NumericCellStyle())
-
-	writeCell(
-		startRow+2, 3,
-		strconv.FormatFloat(
-			worker.PerHour, 'f', 0, 64),
-		NumericCellStyle())
+	number := func(value float64, prec int) string {
+		return strconv.FormatFloat(value, 'f', prec, 64)
+	}
 
+	// regular hours salary
+	regularSal := value(number(worker.MonthlySal, 0), styles.numeric)
 	if worker.WorkerType == "hourly" || worker.WorkerType == "daily" {
-		writeCellFormula(
-			startRow+2, 4,
+		regularSal = formula(
 			fmt.Sprintf(
 				"=%s*%s",
 				cellName(startRow+2, 2),
 				cellName(startRow+2, 3)),
-			NumericCellStyle())
-	} else {
-		writeCell(
-			startRow+2, 4,
-			strconv.FormatFloat(
-				worker.MonthlySal, 'f', 0, 64),
-			NumericCellStyle())
+			styles.numeric)
 	}
 
-	// Empty row (start_row + 3)
-
-	// Writing extra hours row in the 4th row of the table
-	writeCell(
-		startRow+4, 1,
-		"ש.נ. 125%",
-		HeaderCellStyle())
-
-	writeCell(
-		startRow+4, 2,
-		strconv.FormatFloat(
-			worker.Hours125, 'f', 0, 64),
-		NumericCellStyle())
-
-	writeCell(
-		startRow+4, 3,
-		strconv.FormatFloat(
-			worker.PerHour125, 'f', 0, 64),
-		NumericCellStyle())
-
+	// extra hours salary
+	var extraSal interface{}
 	if worker.WorkerType == "hourly" {
-		writeCellFormula(
-			startRow+4, 4,
+		extraSal = formula(
 			fmt.Sprintf(
 				"=%s*%s",
 				cellName(startRow+4, 2),
 				cellName(startRow+4, 3)),
-			NumericCellStyle())
-	}
-
-	// Empty row (start_row + 5)
-
-	// Writing transportation expenses row in the 6th row of the table
-	writeCell(
-		startRow+6, 1,
-		"נסיעות",
-		HeaderCellStyle())
-
-	writeCell(
-		startRow+6, 4,
-		strconv.FormatFloat(
-			worker.TransExpanses, 'f', 0, 64),
-		NumericCellStyle())
-
-	// Writing total salary row in the 7th row of the table
-	writeCell(
-		startRow+7, 1,
-		"סה״כ ₪",
-		HeaderCellStyle())
-
-	writeCellFormula(
-		startRow+7, 2,
-		fmt.Sprintf(
-			"=%s+%s",
-			cellName(startRow+2, 2),
-			cellName(startRow+4, 2)),
-		NumericCellStyle())
-
-	writeCellFormula(
-		startRow+7, 4,
-		fmt.Sprintf(
-			"=%s+%s+%s",
-			cellName(startRow+2, 4),
-			cellName(startRow+4, 4),
-			cellName(startRow+6, 4)),
-		NumericCellStyle())
-
-	// Empty row (start_row + 8)
-
-	// Writing work days row in the 9th row of the table
-	writeCell(
-		startRow+9, 1,
-		"ימי עבודה",
-		HeaderCellStyle())
-
-	writeCell(
-		startRow+9, 2,
-		strconv.FormatFloat(
-			worker.WorkDays, 'f', 0, 64),
-		NumericCellStyle())
-
-	// Writing holiday row in the 10th row of the table
-	writeCell(
-		startRow+10, 1,
-		"חג",
-		HeaderCellStyle())
-
-	writeCell(
-		startRow+10, 2,
-		strconv.FormatFloat(
-			worker.Holidays, 'f', 1, 64),
-		NumericCellStyle())
-
-	// Writing gift row in the 11th row of the table
-	writeCell(
-		startRow+11, 1,
-		"מתנה",
-		HeaderCellStyle())
-
-	writeCell(
-		startRow+11, 2, 
-		strconv.FormatFloat(
-			worker.HolidayPresent, 'f', 1, 64),
-		NumericCellStyle())
-
-	// Writing sick days row in the 12th row of the table
-    writeCell(
-		startRow + 12, 1, 
-		"ימי מחלה", 
-		HeaderCellStyle())
-
-    writeCell(
-		startRow + 12, 2, 
-		strconv.FormatFloat(
-			worker.SickDays, 'f', 1, 64),
-		HeaderCellStyle())
-
-
-    // Writing vacation days row in the 13th row of the table
-    writeCell(
-		startRow + 13, 1, 
-		"ימי חופש", 
-		HeaderCellStyle())
-
-    writeCell(
-		startRow + 13, 2, 
-		strconv.FormatFloat(
-			worker.VacDays, 'f', 1, 64),
-		NumericCellStyle())
-
-    // Write absense hours row in the 14th row of the table
-    writeCell(
-		startRow + 14, 1, 
-		"שעות להוריד", 
-		HeaderCellStyle())
-
-    writeCell(
-		startRow + 14, 2, 
-		strconv.FormatFloat(
-			worker.AbsenseHours, 'f', 1, 64),
-		NumericCellStyle())
-
-	// Adding some space between tablesCRturn startRow + WorkerRowSpacing
-	return startRow + WorkerRowSpacing
+			styles.numeric)
+	}
+
+	// table rows by offset from the start row, empty rows are skipped
+	rows := []struct {
+		offset int
+		cells  []interface{}
+	}{
+		// worker name
+		{0, []interface{}{
+			value(worker.Name, styles.title),
+		}},
+		// title in the second row of the table
+		{1, []interface{}{
+			value("", styles.header),
+			value(labels.Hours, styles.header),
+			value(labels.PerHour, styles.header),
+			value(labels.Total, styles.header),
+		}},
+		// regular hours row in the 3rd row of the table
+		{regularHoursRowOffset, []interface{}{
+			value(labels.RegularHours, styles.header),
+			value(number(worker.Hours, 0), styles.numeric),
+			value(number(worker.PerHour, 0), styles.numeric),
+			regularSal,
+		}},
+		// extra hours row in the 5th row of the table
+		{hours125RowOffset, []interface{}{
+			value(labels.Hours125, styles.header),
+			value(number(worker.Hours125, 0), styles.numeric),
+			value(number(worker.PerHour125, 0), styles.numeric),
+			extraSal,
+		}},
+		// transportation expenses row in the 7th row of the table
+		{6, []interface{}{
+			value(labels.Transport, styles.header),
+			nil,
+			nil,
+			value(number(worker.TransExpanses, 0), styles.numeric),
+		}},
+		// total salary row in the 8th row of the table
+		{totalRowOffset, []interface{}{
+			value(labels.Total, styles.header),
+			formula(
+				fmt.Sprintf(
+					"=%s+%s",
+					cellName(startRow+2, 2),
+					cellName(startRow+4, 2)),
+				styles.numeric),
+			nil,
+			formula(
+				fmt.Sprintf(
+					"=%s+%s+%s",
+					cellName(startRow+2, 4),
+					cellName(startRow+4, 4),
+					cellName(startRow+6, 4)),
+				styles.numeric),
+		}},
+		// work days row in the 10th row of the table
+		{9, []interface{}{
+			value(labels.WorkDays, styles.header),
+			value(number(worker.WorkDays, 0), styles.numeric),
+		}},
+		// holiday row in the 11th row of the table
+		{10, []interface{}{
+			value(labels.Holiday, styles.header),
+			value(number(worker.Holidays, 1), styles.numeric),
+		}},
+		// gift row in the 12th row of the table
+		{11, []interface{}{
+			value(labels.Gift, styles.header),
+			value(number(worker.HolidayPresent, 1), styles.numeric),
+		}},
+		// sick days row in the 13th row of the table
+		{sickDaysRowOffset, []interface{}{
+			value(labels.SickDays, styles.header),
+			value(number(worker.SickDays, 1), styles.header),
+		}},
+		// vacation days row in the 14th row of the table
+		{vacDaysRowOffset, []interface{}{
+			value(labels.VacDays, styles.header),
+			value(number(worker.VacDays, 1), styles.numeric),
+		}},
+		// absense hours row in the 15th row of the table
+		{14, []interface{}{
+			value(labels.AbsenseHours, styles.header),
+			value(number(worker.AbsenseHours, 1), styles.numeric),
+		}},
+	}
+
+	for _, row := range rows {
+		cell := cellName(startRow+row.offset, 1)
+		if err := sw.SetRow(cell, row.cells); err != nil {
+			return startRow, fmt.Errorf("failed to set row: %s, error: %w", cell, err)
+		}
+	}
+
+	// Adding some space between tables
+	return startRow + WorkerRowSpacing, nil
 }
 
-func TimeStrToFloat64(timeStr string) float64 {
+// calculateTotals fills the worker salary totals the same way the sheet
+// formulas do, using the hours and rates rounded as they are written.
+func (w *Worker) calculateTotals() {
+	hours := math.Round(w.Hours)
+	hours125 := math.Round(w.Hours125)
+
+	switch w.WorkerType {
+	case "hourly":
+		w.RegularHoursSal = hours * math.Round(w.PerHour)
+		w.ExtraHoursSal = hours125 * math.Round(w.PerHour125)
+	case "daily":
+		w.RegularHoursSal = hours * math.Round(w.PerHour)
+		w.ExtraHoursSal = 0
+	default:
+		w.RegularHoursSal = math.Round(w.MonthlySal)
+		w.ExtraHoursSal = 0
+	}
+
+	w.TotalHours = hours + hours125
+	w.TotalSal = w.RegularHoursSal + w.ExtraHoursSal + math.Round(w.TransExpanses)
+}
+
+func TimeStrToFloat64(timeStr string) (float64, error) {
 	var hours, minutes, seconds int
 	var err error
 
 	parts := strings.Split(timeStr, ":")
 
-	if len(parts) > 0 {
-		hours, err = strconv.Atoi(parts[0])
-		if err != nil {
-			panic(fmt.Errorf("failed to convert hours to int, error: %w", err))
-		}
-	} else {
-		panic(fmt.Errorf("time string is empty"))
+	hours, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert hours to int, error: %w", err)
 	}
 
 	if len(parts) > 1 {
 		minutes, err = strconv.Atoi(parts[1])
 		if err != nil {
-			panic(fmt.Errorf("failed to convert minutes to int, error: %w", err))
+			return 0, fmt.Errorf("failed to convert minutes to int, error: %w", err)
 		}
 	} else {
 		minutes = 0
@@ -619,21 +705,21 @@ func TimeStrToFloat64(timeStr string) float64 {
 	if len(parts) > 2 {
 		seconds, err = strconv.Atoi(parts[2])
 		if err != nil {
-			panic(fmt.Errorf("failed to convert seconds to int, error: %w", err))
+			return 0, fmt.Errorf("failed to convert seconds to int, error: %w", err)
 		}
 	} else {
 		seconds = 0
 	}
 
-	return float64(hours) + float64(minutes)/60 + float64(seconds)/3600
+	return float64(hours) + float64(minutes)/60 + float64(seconds)/3600, nil
 }
 
-func StrToFloat64(str string) float64 {
+func StrToFloat64(str string) (float64, error) {
 	val, err := strconv.ParseFloat(str, 64)
 	if err != nil {
-		panic(fmt.Errorf("failed to convert string %s to float64, error: %w", str, err))
+		return 0, fmt.Errorf("failed to convert string %s to float64, error: %w", str, err)
 	}
-	return val
+	return val, nil
 }
 
 // Convert row and column to Excel cell name
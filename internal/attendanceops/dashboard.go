@@ -0,0 +1,218 @@
+package attendanceops
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	"github.com/xuri/excelize/v2"
+)
+
+// Constants for dashboard sheet configuration
+const (
+	DashboardSheetName = "Dashboard"
+	DashboardChartCol  = "M"
+	DashboardChartRows = 20
+	TrendMonths        = 12
+)
+
+// MonthlySummary is the salary summary of a past month, used for the
+// dashboard trend chart
+type MonthlySummary struct {
+	Period     string  `json:"period"`
+	TotalSal   float64 `json:"total_sal"`
+	TotalHours float64 `json:"total_hours"`
+}
+
+// SetDashboard enables the dashboard sheet created next to the report sheet
+func (a *AttendanceReport) SetDashboard(enabled bool) {
+	a.dashboard = enabled
+}
+
+// SetHistory sets the summaries of past months, ordered by period, shown in
+// the dashboard trend chart
+func (a *AttendanceReport) SetHistory(history []MonthlySummary) {
+	a.history = history
+}
+
+// Summary returns the salary summary of the report for the given period
+func (a *AttendanceReport) Summary(period string) MonthlySummary {
+	summary := MonthlySummary{Period: period}
+	for _, worker := range a.workers {
+		summary.TotalSal += worker.TotalSal
+		summary.TotalHours += worker.TotalHours
+	}
+
+	return summary
+}
+
+func (a *AttendanceReport) addDashboardSheet(f *excelize.File) error {
+	sheetName := DashboardSheetName
+	if _, err := f.NewSheet(sheetName); err != nil {
+		return fmt.Errorf("failed to create dashboard sheet, error: %w", err)
+	}
+
+	// Set the sheet view direction of the report language
+	labels := a.language.Labels()
+	RightToLeft := a.language.RightToLeft()
+	err := f.SetSheetView(sheetName, 0, &excelize.ViewOptions{
+		RightToLeft: &RightToLeft,
+	})
+	if err != nil {
+		log.Error().Msgf("failed to set dashboard sheet view direction, error: %v", err)
+	}
+
+	if err := f.SetColWidth(sheetName, "A", "K", DefaultColumnWidth); err != nil {
+		log.Error().Err(err)
+	}
+
+	headerStyleID, err := f.NewStyle(HeaderCellStyle())
+	if err != nil {
+		log.Error().Msgf("failed to create dashboard header style, error: %v", err)
+	}
+
+	setRow := func(cell string, values []interface{}, header bool) {
+		if err := f.SetSheetRow(sheetName, cell, &values); err != nil {
+			log.Error().Msgf("failed to set dashboard row: %s, error: %v", cell, err)
+			return
+		}
+		if !header {
+			return
+		}
+		col, row, _ := excelize.CellNameToCoordinates(cell)
+		if err := f.SetCellStyle(sheetName, cell,
+			cellName(row, col+len(values)-1), headerStyleID); err != nil {
+			log.Error().Msgf("failed to set dashboard header style: %s, error: %v", cell, err)
+		}
+	}
+
+	// hours and cost per worker table, hours are rounded as in the report
+	// sheet
+	setRow("A1", []interface{}{labels.Worker, labels.RegularHours, labels.Hours125, labels.Hours, labels.Total}, true)
+	costByType := map[string]float64{}
+	var totalCost float64
+	for i, worker := range a.workers {
+		hours := math.Round(worker.Hours)
+		hours125 := math.Round(worker.Hours125)
+		setRow(cellName(i+2, 1), []interface{}{
+			worker.Name,
+			hours,
+			hours125,
+			hours + hours125,
+			worker.TotalSal,
+		}, false)
+		costByType[worker.WorkerType] += worker.TotalSal
+		totalCost += worker.TotalSal
+	}
+	lastWorkerRow := len(a.workers) + 1
+
+	// total labour cost
+	setRow("G1", []interface{}{labels.TotalLaborCost}, true)
+	setRow("H1", []interface{}{totalCost}, false)
+
+	// cost by worker type table
+	workerTypes := make([]string, 0, len(costByType))
+	for workerType := range costByType {
+		workerTypes = append(workerTypes, workerType)
+	}
+	sort.Strings(workerTypes)
+
+	setRow("G3", []interface{}{labels.WorkerType, labels.Total}, true)
+	for i, workerType := range workerTypes {
+		setRow(cellName(i+4, 7), []interface{}{
+			labels.WorkerTypeName(workerType),
+			costByType[workerType],
+		}, false)
+	}
+	lastTypeRow := len(workerTypes) + 3
+
+	ref := func(col string, fromRow, toRow int) string {
+		return fmt.Sprintf("%s!$%s$%d:$%s$%d", sheetName, col, fromRow, col, toRow)
+	}
+
+	// worker charts, a report without workers has no rows to chart
+	var charts []*excelize.Chart
+	if len(a.workers) > 0 {
+		charts = []*excelize.Chart{
+			{
+				Type:  excelize.Col,
+				Title: []excelize.RichTextRun{{Text: labels.HoursPerWorker}},
+				Series: []excelize.ChartSeries{
+					{
+						Name:       sheetName + "!$D$1",
+						Categories: ref("A", 2, lastWorkerRow),
+						Values:     ref("D", 2, lastWorkerRow),
+					},
+				},
+				Legend: excelize.ChartLegend{Position: "none"},
+			},
+			{
+				Type:  excelize.ColStacked,
+				Title: []excelize.RichTextRun{{Text: labels.RegularVs125}},
+				Series: []excelize.ChartSeries{
+					{
+						Name:       sheetName + "!$B$1",
+						Categories: ref("A", 2, lastWorkerRow),
+						Values:     ref("B", 2, lastWorkerRow),
+					},
+					{
+						Name:       sheetName + "!$C$1",
+						Categories: ref("A", 2, lastWorkerRow),
+						Values:     ref("C", 2, lastWorkerRow),
+					},
+				},
+				Legend: excelize.ChartLegend{Position: "bottom"},
+			},
+			{
+				Type:  excelize.Pie,
+				Title: []excelize.RichTextRun{{Text: labels.CostByType}},
+				Series: []excelize.ChartSeries{
+					{
+						Name:       sheetName + "!$H$3",
+						Categories: ref("G", 4, lastTypeRow),
+						Values:     ref("H", 4, lastTypeRow),
+					},
+				},
+				Legend:   excelize.ChartLegend{Position: "right"},
+				PlotArea: excelize.ChartPlotArea{ShowPercent: true},
+			},
+		}
+	}
+
+	// monthly trend table, when history is available
+	history := a.history
+	if len(history) > TrendMonths {
+		history = history[len(history)-TrendMonths:]
+	}
+	if len(history) > 0 {
+		setRow("J1", []interface{}{labels.Month, labels.Total}, true)
+		for i, summary := range history {
+			setRow(cellName(i+2, 10), []interface{}{summary.Period, summary.TotalSal}, false)
+		}
+		lastHistoryRow := len(history) + 1
+
+		charts = append(charts, &excelize.Chart{
+			Type:  excelize.Line,
+			Title: []excelize.RichTextRun{{Text: labels.MonthlyTrend}},
+			Series: []excelize.ChartSeries{
+				{
+					Name:       sheetName + "!$K$1",
+					Categories: ref("J", 2, lastHistoryRow),
+					Values:     ref("K", 2, lastHistoryRow),
+				},
+			},
+			Legend: excelize.ChartLegend{Position: "none"},
+		})
+	}
+
+	// add charts one under the other
+	for i, chart := range charts {
+		cell := fmt.Sprintf("%s%d", DashboardChartCol, i*DashboardChartRows+1)
+		if err := f.AddChart(sheetName, cell, chart); err != nil {
+			return fmt.Errorf("failed to add dashboard chart at: %s, error: %w", cell, err)
+		}
+	}
+
+	return nil
+}
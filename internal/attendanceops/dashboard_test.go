@@ -0,0 +1,111 @@
+package attendanceops
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// chartCount returns the number of charts in the workbook
+func chartCount(f *excelize.File) int {
+	count := 0
+	for i := 1; ; i++ {
+		if _, ok := f.Pkg.Load(fmt.Sprintf("xl/charts/chart%d.xml", i)); !ok {
+			return count
+		}
+		count++
+	}
+}
+
+func TestDashboardSheet(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers []Worker
+		history []MonthlySummary
+
+		// wantRows are the hours of the workers table, wantCharts the
+		// number of dashboard charts
+		wantRows   [][]string
+		wantCharts int
+	}{
+		{
+			name: "workers",
+			workers: []Worker{
+				{WorkerID: "1", Name: "hourly", WorkerType: "hourly", Hours: 100.4, Hours125: 10.4, PerHour: 40, PerHour125: 50},
+				{WorkerID: "2", Name: "monthly", WorkerType: "monthly", Hours: 159.6, MonthlySal: 8000},
+			},
+			wantRows: [][]string{
+				{"hourly", "100", "10", "110", "4500"},
+				{"monthly", "160", "0", "160", "8000"},
+			},
+			wantCharts: 3,
+		},
+		{
+			name: "workers and history",
+			workers: []Worker{
+				{WorkerID: "1", Name: "hourly", WorkerType: "hourly", Hours: 100.5, PerHour: 40},
+			},
+			history: []MonthlySummary{{Period: "2025-01", TotalSal: 4000}},
+			wantRows: [][]string{
+				{"hourly", "101", "0", "101", "4040"},
+			},
+			wantCharts: 4,
+		},
+		{
+			name: "no workers",
+		},
+		{
+			name:       "history without workers",
+			history:    []MonthlySummary{{Period: "2025-01", TotalSal: 4000}},
+			wantCharts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &AttendanceReport{
+				anomalyRules: DefaultAnomalyRules(),
+				language:     English,
+				dashboard:    true,
+				history:      tt.history,
+			}
+			for _, worker := range tt.workers {
+				worker.calculateTotals()
+				report.workers = append(report.workers, worker)
+			}
+
+			reportPath := filepath.Join(t.TempDir(), "salary_details.xlsx")
+			if err := SaveAttendanceReport(report, reportPath); err != nil {
+				t.Fatalf("SaveAttendanceReport() error = %v", err)
+			}
+
+			f, err := excelize.OpenFile(reportPath)
+			if err != nil {
+				t.Fatalf("OpenFile() error = %v", err)
+			}
+			defer f.Close()
+
+			rows, err := f.GetRows(DashboardSheetName)
+			if err != nil {
+				t.Fatalf("GetRows() error = %v", err)
+			}
+			for i, want := range tt.wantRows {
+				if i+1 >= len(rows) || len(rows[i+1]) < len(want) {
+					t.Fatalf("dashboard rows = %v, want worker row: %v", rows, want)
+				}
+				for col, value := range want {
+					if rows[i+1][col] != value {
+						t.Errorf("dashboard row: %d = %v, want %v", i+2, rows[i+1][:len(want)], want)
+						break
+					}
+				}
+			}
+
+			if got := chartCount(f); got != tt.wantCharts {
+				t.Errorf("dashboard charts = %d, want %d", got, tt.wantCharts)
+			}
+		})
+	}
+}
@@ -11,17 +11,20 @@ var (
 	ALIGN_LEFT   = excelize.Alignment{Horizontal: "left", Vertical: "center"}
 	ALIGN_RIGHT  = excelize.Alignment{Horizontal: "right", Vertical: "center"}
 
-	LEFT_BORDER = excelize.Border{Type: "left", Style: 1, Color: "000000"}
-	TOP_BORDER = excelize.Border{Type: "top", Style: 1, Color: "000000"}
+	LEFT_BORDER   = excelize.Border{Type: "left", Style: 1, Color: "000000"}
+	TOP_BORDER    = excelize.Border{Type: "top", Style: 1, Color: "000000"}
 	BOTTOM_BORDER = excelize.Border{Type: "bottom", Style: 1, Color: "000000"}
-	RIGHT_BORDER = excelize.Border{Type: "right", Style: 1, Color: "000000"}
-	THICK_BORDER = []excelize.Border{
+	RIGHT_BORDER  = excelize.Border{Type: "right", Style: 1, Color: "000000"}
+	THICK_BORDER  = []excelize.Border{
 		LEFT_BORDER,
 		TOP_BORDER,
 		BOTTOM_BORDER,
 		RIGHT_BORDER,
 	}
 	NUMBER_FORMAT = "#"
+
+	ANOMALY_FONT = excelize.Font{Bold: true, Size: 14, Color: "9C0006"}
+	ANOMALY_FILL = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"FFC7CE"}}
 )
 
 func TitleCellStyle() *excelize.Style {
@@ -33,12 +36,11 @@ func TitleCellStyle() *excelize.Style {
 	return &style
 }
 
-
 func DefaultCellStyle() *excelize.Style {
 	style := excelize.Style{
 		Font:      &TEXT_FONT,
 		Alignment: &ALIGN_RIGHT,
-		Border:   THICK_BORDER,
+		Border:    THICK_BORDER,
 	}
 
 	return &style
@@ -48,7 +50,7 @@ func HeaderCellStyle() *excelize.Style {
 	style := excelize.Style{
 		Font:      &HEADER_FONT,
 		Alignment: &ALIGN_RIGHT,
-		Border:   THICK_BORDER,
+		Border:    THICK_BORDER,
 	}
 
 	return &style
@@ -56,12 +58,21 @@ func HeaderCellStyle() *excelize.Style {
 
 func NumericCellStyle() *excelize.Style {
 	style := excelize.Style{
-		Font:      &TEXT_FONT,
-		Alignment: &ALIGN_RIGHT,
+		Font:         &TEXT_FONT,
+		Alignment:    &ALIGN_RIGHT,
 		CustomNumFmt: &NUMBER_FORMAT,
-		Border:   THICK_BORDER,
+		Border:       THICK_BORDER,
 	}
 
 	return &style
 }
 
+// AnomalyCellStyle is the conditional style of cells breaking anomaly rules
+func AnomalyCellStyle() *excelize.Style {
+	style := excelize.Style{
+		Font: &ANOMALY_FONT,
+		Fill: ANOMALY_FILL,
+	}
+
+	return &style
+}
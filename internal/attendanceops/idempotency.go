@@ -0,0 +1,166 @@
+package attendanceops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/filelock"
+)
+
+// DefaultResultRetention is how long completed job results are kept to
+// detect duplicate deliveries
+const DefaultResultRetention = 90 * 24 * time.Hour
+
+// IdempotencyFunc returns the idempotency key of a message, and whether the
+// message must run effectively once. Messages running at least once are
+// processed on every delivery.
+type IdempotencyFunc func(m Msg) (key string, once bool)
+
+// JobResult records a completed effectively-once message
+type JobResult struct {
+	Key         string    `json:"key"`
+	MessageID   string    `json:"message_id"`
+	Deliveries  int       `json:"deliveries"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// ResultStore keeps the results of completed messages by idempotency key
+type ResultStore interface {
+	Get(key string) (JobResult, bool, error)
+	Put(result JobResult) error
+}
+
+// FileResultStore keeps job results in a JSON file shared by the worker
+// processes: every operation locks the file and loads the results, and
+// results older than the retention are removed when a result is added
+type FileResultStore struct {
+	mu        sync.Mutex
+	path      string
+	retention time.Duration
+}
+
+// OpenFileResultStore opens the results file, creating its dir when
+// missing
+func OpenFileResultStore(path string, retention time.Duration) (*FileResultStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create results dir: %s, error: %w", filepath.Dir(path), err)
+	}
+
+	s := &FileResultStore{path: path, retention: retention}
+
+	// check the results file can be read
+	if _, _, err := s.Get(""); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileResultStore) Get(key string) (JobResult, bool, error) {
+	var result JobResult
+	var ok bool
+	err := s.update(func(results map[string]JobResult) bool {
+		result, ok = results[key]
+		return false
+	})
+	return result, ok, err
+}
+
+func (s *FileResultStore) Put(result JobResult) error {
+	return s.update(func(results map[string]JobResult) bool {
+		// drop expired results
+		if s.retention > 0 {
+			expired := time.Now().Add(-s.retention)
+			for key, r := range results {
+				if r.CompletedAt.Before(expired) {
+					delete(results, key)
+				}
+			}
+		}
+		results[result.Key] = result
+		return true
+	})
+}
+
+// update locks the results file, loads the results and applies the
+// change, the results are saved when the change reports them changed. A
+// missing file has no results.
+func (s *FileResultStore) update(change func(results map[string]JobResult) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, err := filelock.Acquire(s.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("failed to lock results file: %s, error: %w", s.path, err)
+	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			log.Error().Msgf("failed to unlock results file: %s, error: %v", s.path, err)
+		}
+	}()
+
+	results := map[string]JobResult{}
+	content, err := os.ReadFile(s.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to read results file: %s, error: %w", s.path, err)
+	default:
+		if err := json.Unmarshal(content, &results); err != nil {
+			return fmt.Errorf("failed to unmarshal results file: %s, error: %w", s.path, err)
+		}
+	}
+
+	if !change(results) {
+		return nil
+	}
+
+	content, err = json.MarshalIndent(results, "", "   ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal results, error: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write results file: %s, error: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to rename results file: %s, error: %w", tmpPath, err)
+	}
+
+	return nil
+}
+
+// inFlightKeys tracks the idempotency keys of running messages, so two
+// deliveries of the same key never run at the same time
+type inFlightKeys struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+func (k *inFlightKeys) acquire(key string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.keys == nil {
+		k.keys = map[string]bool{}
+	}
+	if k.keys[key] {
+		return false
+	}
+	k.keys[key] = true
+	return true
+}
+
+func (k *inFlightKeys) release(key string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	delete(k.keys, key)
+}
@@ -0,0 +1,292 @@
+package attendanceops
+
+// Language is the language of the generated report labels
+type Language string
+
+// Supported report languages
+const (
+	Hebrew  Language = "he"
+	English Language = "en"
+	Russian Language = "ru"
+	Arabic  Language = "ar"
+
+	DefaultLanguage = Hebrew
+)
+
+// Labels holds the texts written to the report sheets in one language
+type Labels struct {
+	Hours          string
+	PerHour        string
+	Total          string
+	RegularHours   string
+	Hours125       string
+	Transport      string
+	WorkDays       string
+	Holiday        string
+	Gift           string
+	SickDays       string
+	VacDays        string
+	AbsenseHours   string
+	Worker         string
+	WorkerType     string
+	Month          string
+	HoursPerWorker string
+	RegularVs125   string
+	CostByType     string
+	MonthlyTrend   string
+	TotalLaborCost string
+	WorkerTypes    map[string]string
+
+	// payslip labels, the pay lines share the labels of the report rows
+	Payslip    string
+	Period     string
+	WorkerID   string
+	Item       string
+	Quantity   string
+	Rate       string
+	Amount     string
+	Gross      string
+	MonthlySal string
+
+	// payslip message formats, filled with the period, and the worker
+	// name, period and gross total
+	PayslipSubject string
+	PayslipBody    string
+
+	// anomaly messages, formatted with the values breaking the rules
+	ZeroHoursAnomaly   string
+	Hours125Anomaly    string
+	SickDaysAnomaly    string
+	VacDaysAnomaly     string
+	HourlyWageAnomaly  string
+	MonthlyWageAnomaly string
+	TotalChangeAnomaly string
+}
+
+var labelCatalog = map[Language]Labels{
+	Hebrew: {
+		Hours:          "שעות",
+		PerHour:        "לשעה ₪",
+		Total:          "סה״כ ₪",
+		RegularHours:   "ש.רגילות",
+		Hours125:       "ש.נ. 125%",
+		Transport:      "נסיעות",
+		WorkDays:       "ימי עבודה",
+		Holiday:        "חג",
+		Gift:           "מתנה",
+		SickDays:       "ימי מחלה",
+		VacDays:        "ימי חופש",
+		AbsenseHours:   "שעות להוריד",
+		Worker:         "עובד",
+		WorkerType:     "סוג עובד",
+		Month:          "חודש",
+		HoursPerWorker: "שעות לעובד",
+		RegularVs125:   "שעות רגילות מול ש.נ. 125%",
+		CostByType:     "עלות לפי סוג עובד",
+		MonthlyTrend:   "מגמת עלות חודשית",
+		TotalLaborCost: "סה״כ עלות שכר",
+		WorkerTypes: map[string]string{
+			"hourly":  "שעתי",
+			"daily":   "יומי",
+			"monthly": "חודשי",
+		},
+		Payslip:            "תלוש שכר",
+		Period:             "תקופה",
+		WorkerID:           "ת.ז.",
+		Item:               "רכיב",
+		Quantity:           "כמות",
+		Rate:               "תעריף ₪",
+		Amount:             "סכום ₪",
+		Gross:              "סה״כ ברוטו ₪",
+		MonthlySal:         "משכורת חודשית",
+		PayslipSubject:     "תלוש שכר לתקופה %s",
+		PayslipBody:        "שלום %s,\nמצורף תלוש השכר לתקופה %s.\nסה״כ ברוטו: %s ₪",
+		ZeroHoursAnomaly:   "לא דווחו שעות עבודה",
+		Hours125Anomaly:    "ש.נ. 125%% %.1f מעל התקרה %.1f",
+		SickDaysAnomaly:    "ימי מחלה %.1f מעל היתרה %.1f",
+		VacDaysAnomaly:     "ימי חופש %.1f מעל היתרה %.1f",
+		HourlyWageAnomaly:  "שכר שעתי %.2f מתחת לשכר המינימום %.2f",
+		MonthlyWageAnomaly: "שכר חודשי %.2f מתחת לשכר המינימום %.2f",
+		TotalChangeAnomaly: "סה״כ %.0f שונה מהחודש הקודם %.0f ביותר מ-%.0f%%",
+	},
+	English: {
+		Hours:          "Hours",
+		PerHour:        "Per hour ₪",
+		Total:          "Total ₪",
+		RegularHours:   "Regular hours",
+		Hours125:       "Overtime 125%",
+		Transport:      "Travel",
+		WorkDays:       "Work days",
+		Holiday:        "Holiday",
+		Gift:           "Gift",
+		SickDays:       "Sick days",
+		VacDays:        "Vacation days",
+		AbsenseHours:   "Hours to deduct",
+		Worker:         "Worker",
+		WorkerType:     "Worker type",
+		Month:          "Month",
+		HoursPerWorker: "Hours per worker",
+		RegularVs125:   "Regular vs overtime 125%",
+		CostByType:     "Cost by worker type",
+		MonthlyTrend:   "Monthly cost trend",
+		TotalLaborCost: "Total labour cost",
+		WorkerTypes: map[string]string{
+			"hourly":  "Hourly",
+			"daily":   "Daily",
+			"monthly": "Monthly",
+		},
+		Payslip:            "Payslip",
+		Period:             "Period",
+		WorkerID:           "ID",
+		Item:               "Item",
+		Quantity:           "Quantity",
+		Rate:               "Rate ₪",
+		Amount:             "Amount ₪",
+		Gross:              "Gross total ₪",
+		MonthlySal:         "Monthly salary",
+		PayslipSubject:     "Payslip for %s",
+		PayslipBody:        "Hello %s,\nAttached is your payslip for %s.\nGross total: %s ₪",
+		ZeroHoursAnomaly:   "no working hours reported",
+		Hours125Anomaly:    "125%% hours %.1f above cap %.1f",
+		SickDaysAnomaly:    "sick days %.1f above balance %.1f",
+		VacDaysAnomaly:     "vacation days %.1f above balance %.1f",
+		HourlyWageAnomaly:  "hourly rate %.2f below minimum wage %.2f",
+		MonthlyWageAnomaly: "monthly salary %.2f below minimum wage %.2f",
+		TotalChangeAnomaly: "total %.0f differs from last month %.0f by more than %.0f%%",
+	},
+	Russian: {
+		Hours:          "Часы",
+		PerHour:        "В час ₪",
+		Total:          "Итого ₪",
+		RegularHours:   "Обычные часы",
+		Hours125:       "Сверхурочные 125%",
+		Transport:      "Проезд",
+		WorkDays:       "Рабочие дни",
+		Holiday:        "Праздник",
+		Gift:           "Подарок",
+		SickDays:       "Больничные дни",
+		VacDays:        "Дни отпуска",
+		AbsenseHours:   "Часы к вычету",
+		Worker:         "Работник",
+		WorkerType:     "Тип работника",
+		Month:          "Месяц",
+		HoursPerWorker: "Часы по работникам",
+		RegularVs125:   "Обычные и сверхурочные 125%",
+		CostByType:     "Затраты по типу работника",
+		MonthlyTrend:   "Динамика затрат по месяцам",
+		TotalLaborCost: "Общие затраты на оплату труда",
+		WorkerTypes: map[string]string{
+			"hourly":  "Почасовой",
+			"daily":   "Поденный",
+			"monthly": "Помесячный",
+		},
+		Payslip:            "Расчетный лист",
+		Period:             "Период",
+		WorkerID:           "Удостоверение",
+		Item:               "Статья",
+		Quantity:           "Количество",
+		Rate:               "Ставка ₪",
+		Amount:             "Сумма ₪",
+		Gross:              "Итого брутто ₪",
+		MonthlySal:         "Месячный оклад",
+		PayslipSubject:     "Расчетный лист за %s",
+		PayslipBody:        "Здравствуйте, %s!\nВо вложении расчетный лист за %s.\nИтого брутто: %s ₪",
+		ZeroHoursAnomaly:   "рабочие часы не указаны",
+		Hours125Anomaly:    "сверхурочные 125%% %.1f ч. превышают лимит %.1f",
+		SickDaysAnomaly:    "больничные дни %.1f превышают остаток %.1f",
+		VacDaysAnomaly:     "дни отпуска %.1f превышают остаток %.1f",
+		HourlyWageAnomaly:  "почасовая ставка %.2f ниже минимальной зарплаты %.2f",
+		MonthlyWageAnomaly: "месячная зарплата %.2f ниже минимальной зарплаты %.2f",
+		TotalChangeAnomaly: "итог %.0f отличается от прошлого месяца %.0f более чем на %.0f%%",
+	},
+	Arabic: {
+		Hours:          "ساعات",
+		PerHour:        "للساعة ₪",
+		Total:          "المجموع ₪",
+		RegularHours:   "ساعات عادية",
+		Hours125:       "ساعات إضافية 125%",
+		Transport:      "مواصلات",
+		WorkDays:       "أيام العمل",
+		Holiday:        "عطلة",
+		Gift:           "هدية",
+		SickDays:       "أيام مرضية",
+		VacDays:        "أيام إجازة",
+		AbsenseHours:   "ساعات للخصم",
+		Worker:         "العامل",
+		WorkerType:     "نوع العامل",
+		Month:          "الشهر",
+		HoursPerWorker: "الساعات لكل عامل",
+		RegularVs125:   "الساعات العادية مقابل الإضافية 125%",
+		CostByType:     "التكلفة حسب نوع العامل",
+		MonthlyTrend:   "اتجاه التكلفة الشهرية",
+		TotalLaborCost: "إجمالي تكلفة العمالة",
+		WorkerTypes: map[string]string{
+			"hourly":  "بالساعة",
+			"daily":   "يومي",
+			"monthly": "شهري",
+		},
+		Payslip:            "قسيمة راتب",
+		Period:             "الفترة",
+		WorkerID:           "رقم الهوية",
+		Item:               "البند",
+		Quantity:           "الكمية",
+		Rate:               "السعر ₪",
+		Amount:             "المبلغ ₪",
+		Gross:              "المجموع الإجمالي ₪",
+		MonthlySal:         "راتب شهري",
+		PayslipSubject:     "قسيمة الراتب للفترة %s",
+		PayslipBody:        "مرحبا %s،\nمرفقة قسيمة راتبك للفترة %s.\nالمجموع الإجمالي: %s ₪",
+		ZeroHoursAnomaly:   "لم يتم الإبلاغ عن ساعات عمل",
+		Hours125Anomaly:    "الساعات الإضافية 125%% %.1f فوق الحد %.1f",
+		SickDaysAnomaly:    "أيام المرض %.1f فوق الرصيد %.1f",
+		VacDaysAnomaly:     "أيام الإجازة %.1f فوق الرصيد %.1f",
+		HourlyWageAnomaly:  "الأجر بالساعة %.2f أقل من الحد الأدنى للأجور %.2f",
+		MonthlyWageAnomaly: "الراتب الشهري %.2f أقل من الحد الأدنى للأجور %.2f",
+		TotalChangeAnomaly: "المجموع %.0f يختلف عن الشهر الماضي %.0f بأكثر من %.0f%%",
+	},
+}
+
+// Languages returns the supported languages
+func Languages() []Language {
+	return []Language{Hebrew, English, Russian, Arabic}
+}
+
+// ParseLanguage returns the language for a language code, the default
+// language is returned for an empty code
+func ParseLanguage(code string) (Language, bool) {
+	if code == "" {
+		return DefaultLanguage, true
+	}
+	lang := Language(code)
+	_, ok := labelCatalog[lang]
+	return lang, ok
+}
+
+// Labels returns the labels of the language, falling back to the default
+// language for unsupported languages
+func (l Language) Labels() Labels {
+	if labels, ok := labelCatalog[l]; ok {
+		return labels
+	}
+	return labelCatalog[DefaultLanguage]
+}
+
+// RightToLeft reports whether sheets in the language are right-to-left
+func (l Language) RightToLeft() bool {
+	switch l {
+	case Hebrew, Arabic:
+		return true
+	case English, Russian:
+		return false
+	default:
+		return DefaultLanguage.RightToLeft()
+	}
+}
+
+// WorkerTypeName returns the display name of a worker type
+func (l Labels) WorkerTypeName(workerType string) string {
+	if name, ok := l.WorkerTypes[workerType]; ok {
+		return name
+	}
+	return workerType
+}
@@ -2,103 +2,503 @@ package attendanceops
 
 import (
 	"context"
-	"math/rand"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/queue"
+)
+
+// Default processor settings
+const (
+	DefaultBatchSize         = 10
+	DefaultConcurrency       = 4
+	DefaultVisibilityTimeout = 10 * time.Second
+	DefaultPollInterval      = 1 * time.Second
 )
 
 type Msg struct {
-	message string
+	message    string
+	msg        queue.Message
+	queue      queue.Queue
+	visibility time.Duration
 }
 
-func(m *Msg) AckMsg() error {
-	return nil
+// ID returns the queue message id
+func (m *Msg) ID() string {
+	return m.msg.ID
 }
 
-func(m *Msg) NackMsg() error {
-	return nil
+// Message returns the message body
+func (m *Msg) Message() string {
+	return m.message
+}
+
+// Deliveries returns the number of deliveries of the message, including
+// the current one
+func (m *Msg) Deliveries() int {
+	return m.msg.Deliveries
+}
+
+func (m *Msg) AckMsg() error {
+	return m.queue.Ack(context.Background(), m.msg)
+}
+
+// NackMsg returns the message to the queue, to be delivered again after
+// the delay
+func (m *Msg) NackMsg(delay time.Duration) error {
+	return m.queue.Nack(context.Background(), m.msg, delay)
+}
+
+// RequeueMsg returns the message to the queue without counting its
+// delivery, to be delivered again after the delay
+func (m *Msg) RequeueMsg(delay time.Duration) error {
+	return m.queue.Requeue(context.Background(), m.msg, delay)
 }
 
-func(m *Msg) KeepAlive() error {
+func (m *Msg) KeepAlive() error {
+	msg, err := m.queue.ExtendLease(context.Background(), m.msg, m.visibility)
+	if err != nil {
+		return err
+	}
+	m.msg = msg
 	return nil
 }
 
+// ErrLeaseExpired is the cause of a handler context canceled after the
+// message lease was lost
+var ErrLeaseExpired = errors.New("message lease expired")
+
+// ProcessorStats are the counters of a message processor
+type ProcessorStats struct {
+	InFlight             int64 `json:"in_flight"`
+	Processed            int64 `json:"processed"`
+	Failed               int64 `json:"failed"`
+	DeadLettered         int64 `json:"dead_lettered"`
+	Duplicates           int64 `json:"duplicates"`
+	LeaseRenewals        int64 `json:"lease_renewals"`
+	LeaseRenewalFailures int64 `json:"lease_renewal_failures"`
+	LeasesExpired        int64 `json:"leases_expired"`
+}
 
-func fetchMessages(numOfMessages int, timeout time.Duration) ([]Msg, error) {
-	_ = numOfMessages
-	_ = timeout
-	return nil, nil
+type processorStats struct {
+	inFlight             atomic.Int64
+	processed            atomic.Int64
+	failed               atomic.Int64
+	deadLettered         atomic.Int64
+	duplicates           atomic.Int64
+	leaseRenewals        atomic.Int64
+	leaseRenewalFailures atomic.Int64
+	leasesExpired        atomic.Int64
 }
 
+// MsgHandler processes a message, the message is acked when the handler
+// succeeds and nacked when it fails
+type MsgHandler func(ctx context.Context, m Msg) error
+
+// MsgProcessor runs the handler for messages fetched from the queue, on at
+// most Concurrency messages at a time. Messages are fetched only for free
+// workers, so no lease runs out while a message waits for a worker.
 type MsgProcessor struct {
-	Shutdown bool
+	BatchSize         int
+	Concurrency       int
+	VisibilityTimeout time.Duration
+	PollInterval      time.Duration
+
+	// LeaseRenewalInterval is the interval between lease extensions of
+	// in-flight messages, by default a third of the visibility timeout
+	LeaseRenewalInterval time.Duration
+
+	// Retry is the retry policy of failed messages, messages failed for
+	// good are moved to DeadLetters
+	Retry       RetryPolicy
+	DeadLetters *queue.DeadLetterStore
+
+	// Idempotency selects the effectively-once messages and their keys,
+	// duplicate deliveries of a key completed in Results are acked
+	// without running the handler again
+	Idempotency IdempotencyFunc
+	Results     ResultStore
+
+	// OnError is called with the errors of processed messages, by default
+	// the errors are logged
+	OnError func(m Msg, err error)
+
+	queue   queue.Queue
+	handler MsgHandler
+	stats   processorStats
+	running inFlightKeys
+
+	stopOnce  sync.Once
+	stop      chan struct{}
+	abortOnce sync.Once
+	abort     chan struct{}
+	done      chan struct{}
 }
 
-func (w *MsgProcessor) Stop() {
-	w.Shutdown = true
+// NewMsgProcessor creates a processor running the handler for messages
+// fetched from the queue
+func NewMsgProcessor(q queue.Queue, handler MsgHandler) *MsgProcessor {
+	return &MsgProcessor{
+		BatchSize:         DefaultBatchSize,
+		Concurrency:       DefaultConcurrency,
+		VisibilityTimeout: DefaultVisibilityTimeout,
+		PollInterval:      DefaultPollInterval,
+		Retry:             DefaultRetryPolicy(),
+		queue:             q,
+		handler:           handler,
+		stop:              make(chan struct{}),
+		abort:             make(chan struct{}),
+		done:              make(chan struct{}),
+	}
 }
 
-func (w *MsgProcessor) Run() error {
-	for !w.Shutdown {
-		msg, err	:= 
-			fetchMessages(10, 10*time.Second)
-		if err != nil {
-			// log error
-			continue
+func (w *MsgProcessor) fetchMessages(ctx context.Context, numOfMessages int, timeout time.Duration) ([]Msg, error) {
+	batch, err := w.queue.Fetch(ctx, numOfMessages, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := make([]Msg, 0, len(batch))
+	for _, msg := range batch {
+		msgs = append(msgs, Msg{
+			message:    string(msg.Body),
+			msg:        msg,
+			queue:      w.queue,
+			visibility: timeout,
+		})
+	}
+
+	return msgs, nil
+}
+
+// Run fetches and processes messages until the context is canceled or
+// Shutdown is called, and returns once in-flight messages are done.
+// Canceling the context also cancels the in-flight handlers.
+func (w *MsgProcessor) Run(ctx context.Context) error {
+	defer close(w.done)
+
+	// handlers are canceled with the run context, or when shutdown
+	// runs out of time
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-w.abort:
+			cancel()
+		case <-ctx.Done():
 		}
+	}()
 
-		if err = w.ProcessMsgs(msg); err != nil {
-			// log error
-			continue
+	slots := make(chan struct{}, max(w.Concurrency, 1))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		// stop before waiting, a free worker would win the select below
+		// at random
+		select {
+		case <-w.stop:
+			return nil
+		default:
+		}
+
+		// wait for a free worker
+		select {
+		case slots <- struct{}{}:
+		case <-w.stop:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		// fetch a message for every free worker, up to the batch size
+		free := 1
+		for free < w.BatchSize && w.tryAcquire(slots) {
+			free++
+		}
+
+		msgs, err := w.fetchMessages(ctx, free, w.VisibilityTimeout)
+		if err != nil && ctx.Err() == nil {
+			log.Error().Msgf("failed to fetch messages, error: %v", err)
+		}
+
+		// release workers left without a message
+		for i := len(msgs); i < free; i++ {
+			<-slots
+		}
+
+		for _, m := range msgs {
+			wg.Add(1)
+			go func(m Msg) {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				if err := w.ProcessMsg(ctx, m); err != nil {
+					w.reportError(m, err)
+				}
+			}(m)
+		}
+
+		// wait for new messages when the queue is empty
+		if len(msgs) == 0 {
+			select {
+			case <-time.After(w.PollInterval):
+			case <-w.stop:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 		}
 	}
+}
 
-	return nil
+// Shutdown stops fetching messages and waits for in-flight messages to be
+// done. When the context ends first, the in-flight handlers are canceled
+// and the context error is returned.
+func (w *MsgProcessor) Shutdown(ctx context.Context) error {
+	w.stopOnce.Do(func() { close(w.stop) })
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		w.abortOnce.Do(func() { close(w.abort) })
+		return ctx.Err()
+	}
 }
 
-func (w *MsgProcessor) ProcessMsgs(msg []Msg) error {
-	for _, m := range msg {
-		go w.ProcessMsg(m)
+func (w *MsgProcessor) tryAcquire(slots chan struct{}) bool {
+	select {
+	case slots <- struct{}{}:
+		return true
+	default:
+		return false
 	}
+}
 
-	return nil
+func (w *MsgProcessor) reportError(m Msg, err error) {
+	if w.OnError != nil {
+		w.OnError(m, err)
+		return
+	}
+	log.Error().Msgf("failed to process message: %s, error: %v", m.msg.ID, err)
 }
 
-func (w *MsgProcessor) ProcessMsg(m Msg) error {
+// ProcessMsg runs the handler for the message while keeping its lease,
+// acks the message when the handler succeeds. Failed messages are retried
+// by the retry policy, and moved to the dead letter store on permanent
+// errors or after the last delivery.
+func (w *MsgProcessor) ProcessMsg(ctx context.Context, m Msg) error {
+	w.stats.inFlight.Add(1)
+	defer w.stats.inFlight.Add(-1)
+
+	// a message crashing the process is never nacked, stop delivering it
+	if w.Retry.MaxDeliveries > 0 && m.msg.Deliveries > w.Retry.MaxDeliveries {
+		w.stats.failed.Add(1)
+		err := fmt.Errorf("message: %s exceeded %d deliveries", m.msg.ID, w.Retry.MaxDeliveries)
+		if dlErr := w.deadLetter(m, err); dlErr != nil {
+			return errors.Join(err, dlErr)
+		}
+		return err
+	}
+
+	// effectively-once messages run once per idempotency key
+	key, once := w.idempotencyKey(m)
+	if once {
+		if !w.running.acquire(key) {
+			// another delivery of the key is running, check it again later.
+			// The delivery is not counted, so waiting behind a slow run
+			// never exhausts the message deliveries.
+			log.Info().Msgf("delivery of message: %s key: %s is running, retrying later", m.msg.ID, key)
+			return m.RequeueMsg(w.VisibilityTimeout)
+		}
+		defer w.running.release(key)
+
+		result, done, err := w.Results.Get(key)
+		if err != nil {
+			return fmt.Errorf("failed to get result of key: %s, error: %w", key, err)
+		}
+		if done {
+			w.stats.duplicates.Add(1)
+			log.Info().Msgf("skipping duplicate delivery of message: %s key: %s, completed at: %s",
+				m.msg.ID, key, result.CompletedAt.Format(time.RFC3339))
+			return m.AckMsg()
+		}
+	}
+
 	// create cancelable context
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
 
-	// keep alive
-	go w.KeepAlive(ctx, m)
+	// keep alive until the handler returns
+	keepAliveCtx, stopKeepAlive := context.WithCancel(ctx)
+	keepAliveDone := make(chan struct{})
+	lease := m
+	go func() {
+		defer close(keepAliveDone)
+		w.KeepAlive(keepAliveCtx, &lease, cancel)
+	}()
 
 	// process the message
-	time.Sleep(1 * time.Second)
+	err := w.runHandler(ctx, m)
+	stopKeepAlive()
+	<-keepAliveDone
 
-	// create random error
-	if rand.Intn(2) == 0 {
-		// if error occurs Nack the message
-		return m.NackMsg()
-	} else {
-		// if no error Ack the message
-		return m.AckMsg()
+	// the message is delivered again after a lost lease
+	if cause := context.Cause(ctx); errors.Is(cause, ErrLeaseExpired) {
+		w.stats.failed.Add(1)
+		return cause
 	}
+
+	if err != nil {
+		w.stats.failed.Add(1)
+
+		// give up on permanent errors and on the last delivery
+		if IsPermanent(err) || w.Retry.exhausted(lease.msg.Deliveries) {
+			if dlErr := w.deadLetter(lease, err); dlErr != nil {
+				return errors.Join(err, dlErr)
+			}
+			return err
+		}
+
+		// if error occurs Nack the message, to retry after the backoff or
+		// the delay requested by the handler
+		backoff := max(w.Retry.Backoff(lease.msg.Deliveries), retryDelay(err))
+		if nackErr := lease.NackMsg(backoff); nackErr != nil {
+			return errors.Join(err, nackErr)
+		}
+		return err
+	}
+
+	// record the result before the ack, so a redelivery after a failed
+	// ack is detected as a duplicate
+	var resultErr error
+	if once {
+		resultErr = w.Results.Put(JobResult{
+			Key:         key,
+			MessageID:   lease.msg.ID,
+			Deliveries:  lease.msg.Deliveries,
+			CompletedAt: time.Now(),
+		})
+		if resultErr != nil {
+			resultErr = fmt.Errorf("failed to record result of key: %s, error: %w", key, resultErr)
+		}
+	}
+
+	// if no error Ack the message
+	if err := lease.AckMsg(); err != nil {
+		w.stats.failed.Add(1)
+		return errors.Join(err, resultErr)
+	}
+	w.stats.processed.Add(1)
+
+	return resultErr
 }
 
-func (w *MsgProcessor) KeepAlive(ctx context.Context, m Msg) error {
-	ticker := time.NewTicker(1 * time.Second)
+// idempotencyKey returns the idempotency key of effectively-once messages,
+// messages run at least once without a result store
+func (w *MsgProcessor) idempotencyKey(m Msg) (string, bool) {
+	if w.Idempotency == nil || w.Results == nil {
+		return "", false
+	}
+
+	key, once := w.Idempotency(m)
+	if key == "" {
+		return "", false
+	}
+	return key, once
+}
+
+// deadLetter moves the message from the queue to the dead letter store,
+// without a store the message is dropped
+func (w *MsgProcessor) deadLetter(m Msg, cause error) error {
+	if w.DeadLetters == nil {
+		log.Error().Msgf("dropping message: %s without dead letter store, error: %v", m.msg.ID, cause)
+	} else if err := w.DeadLetters.Add(m.msg, cause); err != nil {
+		return fmt.Errorf("failed to add dead letter: %s, error: %w", m.msg.ID, err)
+	}
+
+	w.stats.deadLettered.Add(1)
+	log.Warn().Msgf("moved message: %s to dead letters after %d deliveries, error: %v",
+		m.msg.ID, m.msg.Deliveries, cause)
+
+	return m.AckMsg()
+}
+
+// KeepAlive extends the message lease on every renewal interval until the
+// context is done. When the lease is lost, or runs out before a renewal
+// succeeds, the handler context is canceled with ErrLeaseExpired.
+func (w *MsgProcessor) KeepAlive(ctx context.Context, m *Msg, cancelHandler context.CancelCauseFunc) {
+	ticker := time.NewTicker(w.renewalInterval())
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			return nil
+			return
 		case <-ticker.C:
-		default:
-			if err := m.KeepAlive(); err != nil {
-				// log error
-				return err
-			}
-			time.Sleep(1 * time.Second)
 		}
+
+		err := m.KeepAlive()
+		if err == nil {
+			w.stats.leaseRenewals.Add(1)
+			log.Debug().Msgf("extended lease of message: %s until: %s",
+				m.msg.ID, m.msg.LeaseUntil.Format(time.RFC3339))
+			continue
+		}
+
+		w.stats.leaseRenewalFailures.Add(1)
+		log.Error().Msgf("failed to extend lease of message: %s, error: %v", m.msg.ID, err)
+
+		// retry on the next tick while the lease still holds
+		if !errors.Is(err, queue.ErrLeaseLost) && time.Now().Before(m.msg.LeaseUntil) {
+			continue
+		}
+
+		w.stats.leasesExpired.Add(1)
+		log.Warn().Msgf("lease of message: %s expired, canceling processing", m.msg.ID)
+		cancelHandler(fmt.Errorf("message: %s, error: %w", m.msg.ID, ErrLeaseExpired))
+		return
 	}
-}
\ No newline at end of file
+}
+
+// renewalInterval renews leases three times per visibility timeout, so a
+// single failed renewal does not lose the lease
+func (w *MsgProcessor) renewalInterval() time.Duration {
+	if w.LeaseRenewalInterval > 0 {
+		return w.LeaseRenewalInterval
+	}
+	return max(w.VisibilityTimeout/3, time.Millisecond)
+}
+
+// Stats returns the processor counters
+func (w *MsgProcessor) Stats() ProcessorStats {
+	return ProcessorStats{
+		InFlight:             w.stats.inFlight.Load(),
+		Processed:            w.stats.processed.Load(),
+		Failed:               w.stats.failed.Load(),
+		DeadLettered:         w.stats.deadLettered.Load(),
+		Duplicates:           w.stats.duplicates.Load(),
+		LeaseRenewals:        w.stats.leaseRenewals.Load(),
+		LeaseRenewalFailures: w.stats.leaseRenewalFailures.Load(),
+		LeasesExpired:        w.stats.leasesExpired.Load(),
+	}
+}
+
+// runHandler runs the message handler, a panic of the handler fails the
+// message for good instead of crashing the process
+func (w *MsgProcessor) runHandler(ctx context.Context, m Msg) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Msgf("handler of message: %s panicked: %v\n%s", m.msg.ID, r, debug.Stack())
+			err = Permanent(fmt.Errorf("handler panicked: %v", r))
+		}
+	}()
+
+	return w.handler(ctx, m)
+}
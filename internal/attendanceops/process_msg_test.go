@@ -0,0 +1,835 @@
+package attendanceops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/bhops/internal/queue"
+)
+
+const waitTimeout = 5 * time.Second
+
+// newTestProcessor returns a processor of a memory queue with short
+// timeouts, retrying failed messages without backoff
+func newTestProcessor(t *testing.T, handler MsgHandler) (*MsgProcessor, *queue.MemoryQueue) {
+	t.Helper()
+
+	q := queue.NewMemoryQueue()
+	t.Cleanup(func() { q.Close() })
+
+	p := NewMsgProcessor(q, handler)
+	p.VisibilityTimeout = time.Second
+	p.PollInterval = 5 * time.Millisecond
+	p.Retry = RetryPolicy{MaxDeliveries: 3}
+	p.OnError = func(m Msg, err error) {}
+
+	return p, q
+}
+
+// startProcessor runs the processor until the test ends, the returned
+// channel receives the Run error
+func startProcessor(t *testing.T, p *MsgProcessor) <-chan error {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- p.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-p.done
+	})
+
+	return errc
+}
+
+func enqueueMsgs(t *testing.T, q queue.Queue, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		if _, err := q.Enqueue(context.Background(), []byte("message")); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+}
+
+// waitFor waits for the condition to hold
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(waitTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func queueLen(t *testing.T, q *queue.MemoryQueue) int {
+	t.Helper()
+
+	n, err := q.Len()
+	if err != nil {
+		t.Fatalf("Len() error = %v", err)
+	}
+	return n
+}
+
+// fetchMsg fetches a queued message for the processor
+func fetchMsg(t *testing.T, p *MsgProcessor) Msg {
+	t.Helper()
+
+	msgs, err := p.fetchMessages(context.Background(), 1, p.VisibilityTimeout)
+	if err != nil {
+		t.Fatalf("fetchMessages() error = %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("fetched %d messages, want 1", len(msgs))
+	}
+	return msgs[0]
+}
+
+// flakyQueue fails lease extensions while failures are left
+type flakyQueue struct {
+	queue.Queue
+	extendFailures atomic.Int64
+}
+
+func (q *flakyQueue) ExtendLease(ctx context.Context, msg queue.Message, visibility time.Duration) (queue.Message, error) {
+	if q.extendFailures.Add(-1) >= 0 {
+		return msg, fmt.Errorf("queue unavailable")
+	}
+	return q.Queue.ExtendLease(ctx, msg, visibility)
+}
+
+// deliverMsg fetches the queued message for the processor until its
+// given delivery
+func deliverMsg(t *testing.T, p *MsgProcessor, q queue.Queue, deliveries int) Msg {
+	t.Helper()
+
+	m := fetchMsg(t, p)
+	for m.Deliveries() < deliveries {
+		if err := q.Nack(context.Background(), m.msg, 0); err != nil {
+			t.Fatalf("Nack() error = %v", err)
+		}
+		m = fetchMsg(t, p)
+	}
+	return m
+}
+
+// recordingQueue records the delays of nacked messages
+type recordingQueue struct {
+	queue.Queue
+
+	mu     sync.Mutex
+	delays []time.Duration
+}
+
+func (q *recordingQueue) Nack(ctx context.Context, msg queue.Message, delay time.Duration) error {
+	q.record(delay)
+	return q.Queue.Nack(ctx, msg, delay)
+}
+
+func (q *recordingQueue) Requeue(ctx context.Context, msg queue.Message, delay time.Duration) error {
+	q.record(delay)
+	return q.Queue.Requeue(ctx, msg, delay)
+}
+
+func (q *recordingQueue) record(delay time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.delays = append(q.delays, delay)
+}
+
+func TestMsgProcessorConcurrency(t *testing.T) {
+	const messages, concurrency = 12, 3
+
+	var running, maxRunning atomic.Int64
+	var redelivered atomic.Bool
+	handler := func(ctx context.Context, m Msg) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			prev := maxRunning.Load()
+			if n <= prev || maxRunning.CompareAndSwap(prev, n) {
+				break
+			}
+		}
+		if m.Deliveries() > 1 {
+			redelivered.Store(true)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+
+	p, q := newTestProcessor(t, handler)
+	p.Concurrency = concurrency
+	p.BatchSize = messages
+	enqueueMsgs(t, q, messages)
+	errc := startProcessor(t, p)
+
+	waitFor(t, "messages to be processed", func() bool {
+		return p.Stats().Processed == messages
+	})
+
+	if got := maxRunning.Load(); got != concurrency {
+		t.Errorf("max running handlers = %d, want %d", got, concurrency)
+	}
+	if redelivered.Load() {
+		t.Error("a message was delivered again, its lease ran out waiting for a worker")
+	}
+	if n := queueLen(t, q); n != 0 {
+		t.Errorf("queue length = %d, want 0", n)
+	}
+
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
+
+func TestMsgProcessorShutdown(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// handler blocks until released, or until its context is canceled
+		// without a release
+		release      bool
+		timeout      time.Duration
+		wantErr      error
+		wantCanceled bool
+	}{
+		{
+			name:    "in-flight messages done",
+			release: true,
+			timeout: waitTimeout,
+		},
+		{
+			name:         "deadline exceeded",
+			timeout:      20 * time.Millisecond,
+			wantErr:      context.DeadlineExceeded,
+			wantCanceled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			released := make(chan struct{})
+			var canceled atomic.Bool
+			handler := func(ctx context.Context, m Msg) error {
+				close(started)
+				select {
+				case <-released:
+					return nil
+				case <-ctx.Done():
+					canceled.Store(true)
+					return ctx.Err()
+				}
+			}
+
+			p, q := newTestProcessor(t, handler)
+			enqueueMsgs(t, q, 1)
+			errc := startProcessor(t, p)
+			<-started
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			shutdownErr := make(chan error, 1)
+			go func() {
+				shutdownErr <- p.Shutdown(ctx)
+			}()
+
+			if tt.release {
+				// no message is fetched after the shutdown started
+				<-p.stop
+				enqueueMsgs(t, q, 1)
+				time.Sleep(3 * p.PollInterval)
+				close(released)
+			}
+
+			if err := <-shutdownErr; !errors.Is(err, tt.wantErr) {
+				t.Errorf("Shutdown() error = %v, want %v", err, tt.wantErr)
+			}
+			select {
+			case err := <-errc:
+				if err != nil {
+					t.Errorf("Run() error = %v", err)
+				}
+			case <-time.After(waitTimeout):
+				t.Fatal("Run() did not return after Shutdown()")
+			}
+
+			if canceled.Load() != tt.wantCanceled {
+				t.Errorf("handler canceled = %v, want %v", canceled.Load(), tt.wantCanceled)
+			}
+
+			// the message enqueued after the shutdown, or the canceled
+			// message, is left in the queue
+			if n := queueLen(t, q); n != 1 {
+				t.Errorf("queue length = %d, want 1", n)
+			}
+		})
+	}
+}
+
+func TestMsgProcessorLease(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// extendFailures are the failed lease extensions before the
+		// extensions succeed
+		extendFailures int64
+
+		// loseLease nacks the message behind the processor back
+		loseLease bool
+
+		wantErr      error
+		wantExpired  int64
+		wantFailures int64
+		wantQueued   int
+	}{
+		{
+			name: "renewed",
+		},
+		{
+			name:           "renewal retried",
+			extendFailures: 1,
+			wantFailures:   1,
+		},
+		{
+			name:         "lost lease",
+			loseLease:    true,
+			wantErr:      ErrLeaseExpired,
+			wantExpired:  1,
+			wantFailures: 1,
+			wantQueued:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cause error
+			handler := func(ctx context.Context, m Msg) error {
+				if !tt.loseLease {
+					// outlive the visibility timeout
+					time.Sleep(200 * time.Millisecond)
+					return nil
+				}
+
+				<-ctx.Done()
+				cause = context.Cause(ctx)
+				return ctx.Err()
+			}
+
+			p, q := newTestProcessor(t, handler)
+			p.VisibilityTimeout = 60 * time.Millisecond
+			p.LeaseRenewalInterval = 15 * time.Millisecond
+			flaky := &flakyQueue{Queue: q}
+			flaky.extendFailures.Store(tt.extendFailures)
+			p.queue = flaky
+
+			enqueueMsgs(t, q, 1)
+			m := fetchMsg(t, p)
+			if tt.loseLease {
+				if err := q.Nack(context.Background(), m.msg, time.Hour); err != nil {
+					t.Fatalf("Nack() error = %v", err)
+				}
+			}
+
+			err := p.ProcessMsg(context.Background(), m)
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("ProcessMsg() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.loseLease && !errors.Is(cause, ErrLeaseExpired) {
+				t.Errorf("handler context cause = %v, want %v", cause, ErrLeaseExpired)
+			}
+
+			stats := p.Stats()
+			if stats.LeasesExpired != tt.wantExpired || stats.LeaseRenewalFailures != tt.wantFailures {
+				t.Errorf("expired leases: %d renewal failures: %d, want %d and %d",
+					stats.LeasesExpired, stats.LeaseRenewalFailures, tt.wantExpired, tt.wantFailures)
+			}
+			if !tt.loseLease && stats.LeaseRenewals < 3 {
+				t.Errorf("lease renewals = %d, want at least 3", stats.LeaseRenewals)
+			}
+			if n := queueLen(t, q); n != tt.wantQueued {
+				t.Errorf("queue length = %d, want %d", n, tt.wantQueued)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	}
+
+	tests := []struct {
+		name       string
+		policy     RetryPolicy
+		deliveries int
+		want       time.Duration
+	}{
+		{"first delivery", policy, 1, 100 * time.Millisecond},
+		{"third delivery", policy, 3, 400 * time.Millisecond},
+		{"capped", policy, 10, time.Second},
+		{"no backoff", RetryPolicy{}, 3, 0},
+		{"constant", RetryPolicy{InitialBackoff: time.Second}, 5, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Backoff(tt.deliveries); got != tt.want {
+				t.Errorf("Backoff(%d) = %v, want %v", tt.deliveries, got, tt.want)
+			}
+		})
+	}
+
+	// jitter shortens the backoff by up to its fraction
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.Backoff(3); got < 200*time.Millisecond || got > 400*time.Millisecond {
+			t.Fatalf("Backoff(3) with jitter = %v, want within [200ms, 400ms]", got)
+		}
+	}
+}
+
+func TestRetryClassification(t *testing.T) {
+	failure := errors.New("throttled")
+
+	tests := []struct {
+		name          string
+		err           error
+		wantPermanent bool
+		wantDelay     time.Duration
+	}{
+		{"plain", failure, false, 0},
+		{"permanent", Permanent(failure), true, 0},
+		{"wrapped permanent", fmt.Errorf("failed to send, error: %w", Permanent(failure)), true, 0},
+		{"retry after", RetryAfter(failure, time.Minute), false, time.Minute},
+		{"wrapped retry after", fmt.Errorf("failed to send, error: %w", RetryAfter(failure, time.Minute)), false, time.Minute},
+		{"retry after without delay", RetryAfter(failure, 0), false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPermanent(tt.err); got != tt.wantPermanent {
+				t.Errorf("IsPermanent() = %v, want %v", got, tt.wantPermanent)
+			}
+			if got := retryDelay(tt.err); got != tt.wantDelay {
+				t.Errorf("retryDelay() = %v, want %v", got, tt.wantDelay)
+			}
+			if !errors.Is(tt.err, failure) {
+				t.Errorf("error %v does not wrap %v", tt.err, failure)
+			}
+		})
+	}
+
+	if Permanent(nil) != nil || RetryAfter(nil, time.Minute) != nil {
+		t.Error("nil error marked as failed")
+	}
+}
+
+func TestMsgProcessorRetry(t *testing.T) {
+	failure := errors.New("report failed")
+
+	tests := []struct {
+		name       string
+		deliveries int
+		handlerErr error
+		panics     bool
+
+		wantDelay      time.Duration
+		wantDeadLetter bool
+		wantRun        bool
+	}{
+		{
+			name:       "backoff",
+			deliveries: 1,
+			handlerErr: failure,
+			wantDelay:  100 * time.Millisecond,
+			wantRun:    true,
+		},
+		{
+			name:       "growing backoff",
+			deliveries: 2,
+			handlerErr: failure,
+			wantDelay:  200 * time.Millisecond,
+			wantRun:    true,
+		},
+		{
+			name:       "retry after longer than backoff",
+			deliveries: 1,
+			handlerErr: RetryAfter(failure, time.Minute),
+			wantDelay:  time.Minute,
+			wantRun:    true,
+		},
+		{
+			name:       "retry after shorter than backoff",
+			deliveries: 1,
+			handlerErr: RetryAfter(failure, time.Millisecond),
+			wantDelay:  100 * time.Millisecond,
+			wantRun:    true,
+		},
+		{
+			name:           "permanent",
+			deliveries:     1,
+			handlerErr:     fmt.Errorf("failed to build report, error: %w", Permanent(failure)),
+			wantDeadLetter: true,
+			wantRun:        true,
+		},
+		{
+			name:           "last delivery",
+			deliveries:     3,
+			handlerErr:     failure,
+			wantDeadLetter: true,
+			wantRun:        true,
+		},
+		{
+			name:           "panic",
+			deliveries:     1,
+			panics:         true,
+			wantDeadLetter: true,
+			wantRun:        true,
+		},
+		{
+			name:           "exceeded deliveries",
+			deliveries:     4,
+			wantDeadLetter: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var run atomic.Bool
+			handler := func(ctx context.Context, m Msg) error {
+				run.Store(true)
+				if tt.panics {
+					panic("nil workbook")
+				}
+				return tt.handlerErr
+			}
+
+			p, q := newTestProcessor(t, handler)
+			p.Retry = RetryPolicy{MaxDeliveries: 3, InitialBackoff: 100 * time.Millisecond, Multiplier: 2}
+			deadLetters, err := queue.NewDeadLetterStore(t.TempDir())
+			if err != nil {
+				t.Fatalf("NewDeadLetterStore() error = %v", err)
+			}
+			p.DeadLetters = deadLetters
+
+			enqueueMsgs(t, q, 1)
+			m := deliverMsg(t, p, q, tt.deliveries)
+			recorder := &recordingQueue{Queue: q}
+			m.queue = recorder
+
+			if err := p.ProcessMsg(context.Background(), m); err == nil {
+				t.Fatal("ProcessMsg() of a failing message succeeded")
+			}
+			if run.Load() != tt.wantRun {
+				t.Errorf("handler run = %v, want %v", run.Load(), tt.wantRun)
+			}
+
+			letters, err := deadLetters.List()
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if tt.wantDeadLetter {
+				if len(letters) != 1 || letters[0].ID != m.ID() || letters[0].Error == "" {
+					t.Fatalf("dead letters = %+v, want message: %s", letters, m.ID())
+				}
+				if tt.panics && !strings.Contains(letters[0].Error, "nil workbook") {
+					t.Errorf("dead letter error = %q, want the panic", letters[0].Error)
+				}
+				if n := queueLen(t, q); n != 0 {
+					t.Errorf("queue length = %d, want 0", n)
+				}
+				if stats := p.Stats(); stats.DeadLettered != 1 {
+					t.Errorf("dead lettered = %d, want 1", stats.DeadLettered)
+				}
+				return
+			}
+
+			if len(letters) != 0 {
+				t.Errorf("dead letters = %d, want 0", len(letters))
+			}
+			if len(recorder.delays) != 1 || recorder.delays[0] != tt.wantDelay {
+				t.Errorf("nack delays = %v, want [%v]", recorder.delays, tt.wantDelay)
+			}
+			if n := queueLen(t, q); n != 1 {
+				t.Errorf("queue length = %d, want 1", n)
+			}
+		})
+	}
+}
+
+func TestMsgProcessorIdempotency(t *testing.T) {
+	failure := errors.New("report failed")
+	byBody := func(m Msg) (string, bool) { return m.Message(), true }
+
+	tests := []struct {
+		name        string
+		idempotency IdempotencyFunc
+
+		// bodies are delivered in order, failing with the handler error of
+		// the same index
+		bodies      []string
+		handlerErrs []error
+
+		wantRuns       int64
+		wantDuplicates int64
+		wantQueued     int
+	}{
+		{
+			name:           "duplicate skipped",
+			idempotency:    byBody,
+			bodies:         []string{"build:2025-01", "build:2025-01"},
+			wantRuns:       1,
+			wantDuplicates: 1,
+		},
+		{
+			name:        "distinct keys",
+			idempotency: byBody,
+			bodies:      []string{"build:2025-01", "build:2025-02"},
+			wantRuns:    2,
+		},
+		{
+			name:        "at least once",
+			idempotency: func(m Msg) (string, bool) { return m.Message(), false },
+			bodies:      []string{"build:2025-01", "build:2025-01"},
+			wantRuns:    2,
+		},
+		{
+			name:        "no key",
+			idempotency: func(m Msg) (string, bool) { return "", true },
+			bodies:      []string{"build:2025-01", "build:2025-01"},
+			wantRuns:    2,
+		},
+		{
+			name:        "failed run not recorded",
+			idempotency: byBody,
+			bodies:      []string{"build:2025-01", "build:2025-01"},
+			handlerErrs: []error{failure, nil},
+			wantRuns:    2,
+			wantQueued:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var runs atomic.Int64
+			handler := func(ctx context.Context, m Msg) error {
+				run := runs.Add(1)
+				if int(run) <= len(tt.handlerErrs) {
+					return tt.handlerErrs[run-1]
+				}
+				return nil
+			}
+
+			p, q := newTestProcessor(t, handler)
+			p.Retry = RetryPolicy{MaxDeliveries: 3, InitialBackoff: time.Hour}
+			results, err := OpenFileResultStore(filepath.Join(t.TempDir(), "results.json"), DefaultResultRetention)
+			if err != nil {
+				t.Fatalf("OpenFileResultStore() error = %v", err)
+			}
+			p.Idempotency = tt.idempotency
+			p.Results = results
+
+			for _, body := range tt.bodies {
+				if _, err := q.Enqueue(context.Background(), []byte(body)); err != nil {
+					t.Fatalf("Enqueue() error = %v", err)
+				}
+				p.ProcessMsg(context.Background(), fetchMsg(t, p))
+			}
+
+			stats := p.Stats()
+			if runs.Load() != tt.wantRuns || stats.Duplicates != tt.wantDuplicates {
+				t.Errorf("handler runs: %d duplicates: %d, want %d and %d",
+					runs.Load(), stats.Duplicates, tt.wantRuns, tt.wantDuplicates)
+			}
+			if n := queueLen(t, q); n != tt.wantQueued {
+				t.Errorf("queue length = %d, want %d", n, tt.wantQueued)
+			}
+		})
+	}
+}
+
+func TestMsgProcessorIdempotencyRunningKey(t *testing.T) {
+	var runs atomic.Int64
+	p, q := newTestProcessor(t, func(ctx context.Context, m Msg) error {
+		runs.Add(1)
+		return nil
+	})
+	results, err := OpenFileResultStore(filepath.Join(t.TempDir(), "results.json"), DefaultResultRetention)
+	if err != nil {
+		t.Fatalf("OpenFileResultStore() error = %v", err)
+	}
+	p.Idempotency = func(m Msg) (string, bool) { return "build:2025-01", true }
+	p.Results = results
+
+	// a delivery of a running key is checked again after the visibility
+	// timeout
+	p.running.acquire("build:2025-01")
+	enqueueMsgs(t, q, 1)
+	m := fetchMsg(t, p)
+	recorder := &recordingQueue{Queue: q}
+	m.queue = recorder
+
+	if err := p.ProcessMsg(context.Background(), m); err != nil {
+		t.Fatalf("ProcessMsg() error = %v", err)
+	}
+	if runs.Load() != 0 {
+		t.Errorf("handler runs = %d, want 0", runs.Load())
+	}
+	if len(recorder.delays) != 1 || recorder.delays[0] != p.VisibilityTimeout {
+		t.Errorf("nack delays = %v, want [%v]", recorder.delays, p.VisibilityTimeout)
+	}
+	if n := queueLen(t, q); n != 1 {
+		t.Errorf("queue length = %d, want 1", n)
+	}
+
+	// the requeued delivery is not counted
+	waitFor(t, "the message to be visible", func() bool {
+		msgs, err := q.Fetch(context.Background(), 1, time.Second)
+		if err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+		if len(msgs) == 0 {
+			return false
+		}
+		if msgs[0].Deliveries != 1 {
+			t.Errorf("deliveries = %d, want 1", msgs[0].Deliveries)
+		}
+		return true
+	})
+}
+
+func TestMsgProcessorDuplicateOfSlowJob(t *testing.T) {
+	var runs atomic.Int64
+	p, q := newTestProcessor(t, func(ctx context.Context, m Msg) error {
+		runs.Add(1)
+		time.Sleep(150 * time.Millisecond)
+		return nil
+	})
+	p.VisibilityTimeout = 20 * time.Millisecond
+	p.Retry = RetryPolicy{MaxDeliveries: 2}
+	deadLetters, err := queue.NewDeadLetterStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDeadLetterStore() error = %v", err)
+	}
+	results, err := OpenFileResultStore(filepath.Join(t.TempDir(), "results.json"), DefaultResultRetention)
+	if err != nil {
+		t.Fatalf("OpenFileResultStore() error = %v", err)
+	}
+	p.DeadLetters = deadLetters
+	p.Idempotency = func(m Msg) (string, bool) { return m.Message(), true }
+	p.Results = results
+
+	// the duplicate is delivered many times while the job runs, and is
+	// skipped once the job completed
+	enqueueMsgs(t, q, 2)
+	startProcessor(t, p)
+	waitFor(t, "the duplicate to be skipped", func() bool {
+		return p.Stats().Duplicates == 1
+	})
+
+	stats := p.Stats()
+	if runs.Load() != 1 || stats.Processed != 1 || stats.DeadLettered != 0 {
+		t.Errorf("handler runs: %d processed: %d dead lettered: %d, want 1, 1 and 0",
+			runs.Load(), stats.Processed, stats.DeadLettered)
+	}
+	waitFor(t, "the queue to be empty", func() bool {
+		return queueLen(t, q) == 0
+	})
+}
+
+func TestFileResultStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results", "results.json")
+	store, err := OpenFileResultStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("OpenFileResultStore() error = %v", err)
+	}
+
+	now := time.Now()
+	for _, result := range []JobResult{
+		{Key: "expired", MessageID: "1", Deliveries: 1, CompletedAt: now.Add(-2 * time.Hour)},
+		{Key: "build:2025-01", MessageID: "2", Deliveries: 2, CompletedAt: now},
+	} {
+		if err := store.Put(result); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+
+	// results survive reopening the store, expired results are dropped
+	reopened, err := OpenFileResultStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("OpenFileResultStore() error = %v", err)
+	}
+	for _, tt := range []struct {
+		key      string
+		wantDone bool
+	}{
+		{"build:2025-01", true},
+		{"expired", false},
+		{"unknown", false},
+	} {
+		result, done, err := reopened.Get(tt.key)
+		if err != nil || done != tt.wantDone || (done && (result.MessageID != "2" || result.Deliveries != 2)) {
+			t.Errorf("Get(%s) = %+v %v, error: %v, want done: %v", tt.key, result, done, err, tt.wantDone)
+		}
+	}
+}
+
+func TestFileResultStoreShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+
+	// stores of several processes keep the results of each other
+	const stores, perStore = 3, 20
+	var wg sync.WaitGroup
+	for i := 0; i < stores; i++ {
+		store, err := OpenFileResultStore(path, DefaultResultRetention)
+		if err != nil {
+			t.Fatalf("OpenFileResultStore() error = %v", err)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < perStore; j++ {
+				key := fmt.Sprintf("send_payslip:%d:%d", i, j)
+				if err := store.Put(JobResult{Key: key, CompletedAt: time.Now()}); err != nil {
+					t.Errorf("Put() error = %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	store, err := OpenFileResultStore(path, DefaultResultRetention)
+	if err != nil {
+		t.Fatalf("OpenFileResultStore() error = %v", err)
+	}
+	for i := 0; i < stores; i++ {
+		for j := 0; j < perStore; j++ {
+			key := fmt.Sprintf("send_payslip:%d:%d", i, j)
+			if _, done, err := store.Get(key); err != nil || !done {
+				t.Errorf("Get(%s) done: %v, error: %v, want done", key, done, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package attendanceops
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy decides when a failed message is delivered again. Failed
+// messages are retried with exponential backoff until MaxDeliveries, then
+// moved to the dead letter store.
+type RetryPolicy struct {
+	MaxDeliveries  int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Jitter is the fraction of the backoff that is randomized, so failed
+	// messages are not all retried at once
+	Jitter float64
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxDeliveries:  5,
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     5 * time.Minute,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// Backoff returns the delay before the next delivery of a message that
+// failed on its given delivery
+func (p RetryPolicy) Backoff(deliveries int) time.Duration {
+	if p.InitialBackoff <= 0 {
+		return 0
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(max(deliveries-1, 0)))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+
+	// spread retries over [backoff*(1-jitter), backoff]
+	if p.Jitter > 0 {
+		backoff -= backoff * math.Min(p.Jitter, 1) * rand.Float64()
+	}
+
+	return time.Duration(backoff)
+}
+
+// exhausted reports whether a message failed on its last allowed delivery
+func (p RetryPolicy) exhausted(deliveries int) bool {
+	return p.MaxDeliveries > 0 && deliveries >= p.MaxDeliveries
+}
+
+// permanentError marks errors that fail the same way on every retry
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks a handler error as permanent, the message is moved to the
+// dead letter store without retries
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether the error was marked as permanent
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+// retryAfterError delays the next delivery of a failed message
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+// RetryAfter marks a handler error to be retried no sooner than the delay,
+// such as the Retry-After of a throttled request, the retry policy backoff
+// is used when it is longer
+func RetryAfter(err error, delay time.Duration) error {
+	if err == nil || delay <= 0 {
+		return err
+	}
+	return &retryAfterError{err: err, delay: delay}
+}
+
+// retryDelay returns the delay of a RetryAfter error, zero for other errors
+func retryDelay(err error) time.Duration {
+	var retryAfter *retryAfterError
+	if errors.As(err, &retryAfter) {
+		return retryAfter.delay
+	}
+	return 0
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/attendanceops"
+)
+
+// Job statuses
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Files of a job directory
+const (
+	AttendanceReportFile    = "attendance_report.xlsx"
+	NonAttendanceReportFile = "non_attendance_report.json"
+	ReportXLSXFile          = "salary_details.xlsx"
+	ReportJSONFile          = "salary_details.json"
+)
+
+// Job is a single report generation request
+type Job struct {
+	ID         string                  `json:"id"`
+	Status     string                  `json:"status"`
+	Period     string                  `json:"period,omitempty"`
+	Language   string                  `json:"language,omitempty"`
+	CreatedAt  time.Time               `json:"created_at"`
+	FinishedAt *time.Time              `json:"finished_at,omitempty"`
+	Error      string                  `json:"error,omitempty"`
+	Anomalies  []attendanceops.Anomaly `json:"anomalies,omitempty"`
+
+	dir    string
+	report *attendanceops.AttendanceReport
+}
+
+// jobStore tracks jobs by id
+type jobStore struct {
+	mu   sync.RWMutex
+	dir  string
+	jobs map[string]*Job
+}
+
+func newJobStore(dir string) *jobStore {
+	return &jobStore{
+		dir:  dir,
+		jobs: map[string]*Job{},
+	}
+}
+
+// create adds a pending job with its own directory
+func (s *jobStore) create(period, language string) (*Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{
+		ID:        id,
+		Status:    JobPending,
+		Period:    period,
+		Language:  language,
+		CreatedAt: time.Now(),
+		dir:       filepath.Join(s.dir, id),
+	}
+	if err := os.MkdirAll(job.dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create job directory: %s, error: %w", job.dir, err)
+	}
+
+	s.mu.Lock()
+	s.jobs[id] = job
+	s.mu.Unlock()
+
+	return job, nil
+}
+
+// get returns a copy of the job, safe to encode while the job runs
+func (s *jobStore) get(id string) (Job, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+func (s *jobStore) list() []Job {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+
+	return jobs
+}
+
+// update applies a change to the job under the store lock
+func (s *jobStore) update(id string, change func(job *Job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok {
+		change(job)
+	}
+}
+
+// finish marks the job done, failed when err is set
+func (s *jobStore) finish(id string, err error) {
+	s.update(id, func(job *Job) {
+		now := time.Now()
+		job.FinishedAt = &now
+		if err != nil {
+			job.Status = JobFailed
+			job.Error = err.Error()
+			log.Error().Msgf("job: %s failed, error: %v", id, err)
+			return
+		}
+		job.Status = JobSucceeded
+		job.Error = ""
+	})
+}
+
+// expire removes the jobs finished before the cutoff with their directories
+func (s *jobStore) expire(cutoff time.Time) {
+	s.mu.Lock()
+	var expired []*Job
+	for id, job := range s.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			expired = append(expired, job)
+			delete(s.jobs, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, job := range expired {
+		log.Info().Msgf("removing expired job: %s", job.ID)
+		if err := os.RemoveAll(job.dir); err != nil {
+			log.Error().Msgf("failed to remove job directory: %s, error: %v", job.dir, err)
+		}
+	}
+}
+
+// removeStale removes job directories not changed since the cutoff that
+// are not tracked by the store, jobs are kept in memory only so the
+// directories of an earlier run can no longer be reached
+func (s *jobStore) removeStale(cutoff time.Time) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read data directory: %s, error: %w", s.dir, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !isJobID(entry.Name()) {
+			continue
+		}
+		if _, ok := s.jobs[entry.Name()]; ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		dir := filepath.Join(s.dir, entry.Name())
+		log.Info().Msgf("removing stale job directory: %s", dir)
+		if err := os.RemoveAll(dir); err != nil {
+			log.Error().Msgf("failed to remove job directory: %s, error: %v", dir, err)
+		}
+	}
+
+	return nil
+}
+
+func (j Job) path(name string) string {
+	return filepath.Join(j.dir, name)
+}
+
+func newJobID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to create job id, error: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func isJobID(name string) bool {
+	id, err := hex.DecodeString(name)
+	return err == nil && len(id) == 16
+}
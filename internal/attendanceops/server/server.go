@@ -0,0 +1,385 @@
+package server
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/attendanceops"
+)
+
+const (
+	DefaultMaxUploadBytes = 10 << 20
+	DefaultJobTTL         = 24 * time.Hour
+	multipartMemory       = 1 << 20
+	maxAdjustmentsBytes   = 1 << 20
+)
+
+// web holds the offline upload page served at /
+//
+//go:embed web
+var web embed.FS
+
+// Options configures report generation of the server
+type Options struct {
+	WorkerDetailsPath string
+	DataDir           string
+	MaxUploadBytes    int64
+	JobTTL            time.Duration
+	AnomalyRules      attendanceops.AnomalyRules
+	Language          attendanceops.Language
+	Dashboard         bool
+}
+
+// Server serves the attendance report HTTP API:
+//
+//	POST /api/jobs                      upload attendance_report and
+//	                                    non_attendance_report files, start a job
+//	GET  /api/jobs                      list jobs
+//	GET  /api/jobs/{id}                 job status
+//	GET  /api/jobs/{id}/validation      job validation results
+//	GET  /api/jobs/{id}/report.xlsx     download generated workbook
+//	GET  /api/jobs/{id}/report.json     download generated workers JSON
+//	GET  /api/jobs/{id}/workers         workers with their adjustments
+//	PUT  /api/jobs/{id}/adjustments     apply worker adjustments and
+//	                                    regenerate the job outputs
+//	GET  /                              upload page
+type Server struct {
+	opts Options
+	jobs *jobStore
+	mux  *http.ServeMux
+
+	// reportMu serializes changes to job reports
+	reportMu sync.Mutex
+}
+
+// workerView is a worker with the adjustments applied to it
+type workerView struct {
+	attendanceops.Worker
+	Adjustment attendanceops.Adjustment `json:"adjustment"`
+}
+
+func New(opts Options) (*Server, error) {
+	if opts.MaxUploadBytes <= 0 {
+		opts.MaxUploadBytes = DefaultMaxUploadBytes
+	}
+	if opts.Language == "" {
+		opts.Language = attendanceops.DefaultLanguage
+	}
+	if opts.JobTTL <= 0 {
+		opts.JobTTL = DefaultJobTTL
+	}
+	if err := os.MkdirAll(opts.DataDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %s, error: %w", opts.DataDir, err)
+	}
+
+	s := &Server{
+		opts: opts,
+		jobs: newJobStore(opts.DataDir),
+		mux:  http.NewServeMux(),
+	}
+
+	// remove expired job directories of earlier runs
+	if err := s.jobs.removeStale(time.Now().Add(-opts.JobTTL)); err != nil {
+		return nil, err
+	}
+
+	s.mux.HandleFunc("POST /api/jobs", s.handleCreateJob)
+	s.mux.HandleFunc("GET /api/jobs", s.handleListJobs)
+	s.mux.HandleFunc("GET /api/jobs/{id}", s.handleGetJob)
+	s.mux.HandleFunc("GET /api/jobs/{id}/validation", s.handleValidation)
+	s.mux.HandleFunc("GET /api/jobs/{id}/report.xlsx", s.handleDownload(ReportXLSXFile,
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"))
+	s.mux.HandleFunc("GET /api/jobs/{id}/report.json", s.handleDownload(ReportJSONFile,
+		"application/json"))
+	s.mux.HandleFunc("GET /api/jobs/{id}/workers", s.handleWorkers)
+	s.mux.HandleFunc("PUT /api/jobs/{id}/adjustments", s.handleAdjustments)
+
+	webFS, err := fs.Sub(web, "web")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load web files, error: %w", err)
+	}
+	s.mux.Handle("GET /", http.FileServer(http.FS(webFS)))
+
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log.Debug().Msgf("%s %s", r.Method, r.URL.Path)
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxUploadBytes)
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, http.StatusRequestEntityTooLarge,
+				fmt.Errorf("upload larger than %d bytes", s.opts.MaxUploadBytes))
+			return
+		}
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid upload, error: %w", err))
+		return
+	}
+	defer func() {
+		if err := r.MultipartForm.RemoveAll(); err != nil {
+			log.Error().Msgf("failed to remove multipart files, error: %v", err)
+		}
+	}()
+
+	language := r.FormValue("language")
+	lang, ok := attendanceops.ParseLanguage(language)
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unsupported language: %s", language))
+		return
+	}
+	if language == "" {
+		lang = s.opts.Language
+	}
+
+	s.expireJobs()
+
+	job, err := s.jobs.create(r.FormValue("period"), string(lang))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// store uploaded files in the job directory
+	for field, name := range map[string]string{
+		"attendance_report":     AttendanceReportFile,
+		"non_attendance_report": NonAttendanceReportFile,
+	} {
+		if err := saveFormFile(r, field, job.path(name)); err != nil {
+			s.jobs.finish(job.ID, err)
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	go s.runJob(job.ID)
+
+	created, _ := s.jobs.get(job.ID)
+	writeJSON(w, http.StatusAccepted, created)
+}
+
+// runJob creates the attendance report of the job and saves its outputs
+func (s *Server) runJob(id string) {
+	// a malformed upload must fail the job, not the server
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Msgf("report job: %s panicked: %v\n%s", id, r, debug.Stack())
+			s.jobs.finish(id, fmt.Errorf("report job panicked: %v", r))
+		}
+	}()
+
+	s.jobs.update(id, func(job *Job) {
+		job.Status = JobRunning
+	})
+	job, _ := s.jobs.get(id)
+
+	attendanceReport, err := attendanceops.CreateAttendanceReport(
+		job.path(AttendanceReportFile),
+		job.path(NonAttendanceReportFile),
+		s.opts.WorkerDetailsPath,
+	)
+	if err != nil {
+		s.jobs.finish(id, fmt.Errorf("failed to create attendance report, error: %w", err))
+		return
+	}
+
+	s.jobs.finish(id, s.saveReport(id, attendanceReport))
+}
+
+// expireJobs removes the jobs finished more than the job TTL ago
+func (s *Server) expireJobs() {
+	// job outputs may be served or regenerated meanwhile
+	s.reportMu.Lock()
+	defer s.reportMu.Unlock()
+
+	s.jobs.expire(time.Now().Add(-s.opts.JobTTL))
+}
+
+// saveReport saves the job outputs and validation results
+func (s *Server) saveReport(id string, attendanceReport *attendanceops.AttendanceReport) error {
+	job, _ := s.jobs.get(id)
+
+	attendanceReport.SetAnomalyRules(s.opts.AnomalyRules)
+	attendanceReport.SetLanguage(attendanceops.Language(job.Language))
+	attendanceReport.SetDashboard(s.opts.Dashboard)
+
+	if err := attendanceops.SaveAttendanceReport(
+		attendanceReport, job.path(ReportXLSXFile)); err != nil {
+		return err
+	}
+	if err := attendanceops.SaveAttendanceReportJSON(
+		attendanceReport, job.path(ReportJSONFile)); err != nil {
+		return err
+	}
+
+	s.jobs.update(id, func(job *Job) {
+		job.report = attendanceReport
+		job.Anomalies = attendanceReport.Anomalies()
+	})
+
+	return nil
+}
+
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.jobs.list())
+}
+
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("job not found"))
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *Server) handleValidation(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.succeededJob(w, r)
+	if !ok {
+		return
+	}
+
+	anomalies := job.Anomalies
+	if anomalies == nil {
+		anomalies = []attendanceops.Anomaly{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"valid":     len(anomalies) == 0,
+		"anomalies": anomalies,
+	})
+}
+
+func (s *Server) handleDownload(name, contentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, ok := s.succeededJob(w, r)
+		if !ok {
+			return
+		}
+
+		// outputs are replaced when adjustments are applied
+		s.reportMu.Lock()
+		defer s.reportMu.Unlock()
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		http.ServeFile(w, r, job.path(name))
+	}
+}
+
+func (s *Server) handleWorkers(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.succeededJob(w, r)
+	if !ok {
+		return
+	}
+
+	s.reportMu.Lock()
+	defer s.reportMu.Unlock()
+
+	workers := job.report.Workers()
+	views := make([]workerView, 0, len(workers))
+	for _, worker := range workers {
+		adjustment, _ := job.report.Adjustment(worker.WorkerID)
+		views = append(views, workerView{Worker: worker, Adjustment: adjustment})
+	}
+
+	writeJSON(w, http.StatusOK, views)
+}
+
+// handleAdjustments applies adjustments by worker id and regenerates the
+// job outputs
+func (s *Server) handleAdjustments(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.succeededJob(w, r)
+	if !ok {
+		return
+	}
+
+	var adjustments map[string]attendanceops.Adjustment
+	r.Body = http.MaxBytesReader(w, r.Body, maxAdjustmentsBytes)
+	if err := json.NewDecoder(r.Body).Decode(&adjustments); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid adjustments, error: %w", err))
+		return
+	}
+
+	s.reportMu.Lock()
+	defer s.reportMu.Unlock()
+
+	// no adjustment is applied when one of them is invalid
+	if err := job.report.ApplyAdjustments(adjustments); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := s.saveReport(job.ID, job.report); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	updated, _ := s.jobs.get(job.ID)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// succeededJob returns the job of the request, writing an error response
+// when it is not found or has no report
+func (s *Server) succeededJob(w http.ResponseWriter, r *http.Request) (Job, bool) {
+	job, ok := s.jobs.get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("job not found"))
+		return job, false
+	}
+	if job.Status != JobSucceeded || job.report == nil {
+		writeError(w, http.StatusConflict, fmt.Errorf("job is %s", job.Status))
+		return job, false
+	}
+	return job, true
+}
+
+// saveFormFile saves the uploaded file of a form field
+func saveFormFile(r *http.Request, field, path string) error {
+	file, _, err := r.FormFile(field)
+	if err != nil {
+		return fmt.Errorf("missing file: %s, error: %w", field, err)
+	}
+	defer func(file multipart.File) {
+		if err := file.Close(); err != nil {
+			log.Error().Msgf("failed to close uploaded file: %s, error: %v", field, err)
+		}
+	}(file)
+
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %s, error: %w", path, err)
+	}
+	if _, err := io.Copy(out, file); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to save file: %s, error: %w", field, err)
+	}
+
+	return out.Close()
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Error().Msgf("failed to write response, error: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
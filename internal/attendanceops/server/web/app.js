@@ -0,0 +1,185 @@
+"use strict";
+
+// offline upload page of the attendance report server
+
+const files = { attendance: null, nonAttendance: null };
+let jobId = null;
+
+const $ = (id) => document.getElementById(id);
+
+function setStatus(text, error) {
+   $("status").textContent = text;
+   $("status").className = error ? "status error" : "status";
+}
+
+function addFiles(list) {
+   for (const file of list) {
+      const name = file.name.toLowerCase();
+      if (name.endsWith(".xlsx")) {
+         files.attendance = file;
+         $("attendance-name").textContent = file.name;
+         $("attendance-name").className = "";
+      } else if (name.endsWith(".json")) {
+         files.nonAttendance = file;
+         $("non-attendance-name").textContent = file.name;
+         $("non-attendance-name").className = "";
+      }
+   }
+   $("upload-button").disabled = !(files.attendance && files.nonAttendance);
+}
+
+async function request(method, url, body) {
+   const response = await fetch(url, { method, body });
+   const data = await response.json();
+   if (!response.ok) {
+      throw new Error(data.error || response.statusText);
+   }
+   return data;
+}
+
+async function waitForJob(id) {
+   for (;;) {
+      const job = await request("GET", `api/jobs/${id}`);
+      if (job.status === "succeeded") {
+         return job;
+      }
+      if (job.status === "failed") {
+         throw new Error(job.error);
+      }
+      await new Promise((resolve) => setTimeout(resolve, 500));
+   }
+}
+
+async function upload() {
+   const form = new FormData();
+   form.append("attendance_report", files.attendance);
+   form.append("non_attendance_report", files.nonAttendance);
+   form.append("period", $("period").value);
+
+   $("upload-button").disabled = true;
+   setStatus("מעבד את הדוח...");
+   try {
+      const job = await request("POST", "api/jobs", form);
+      jobId = job.id;
+      await waitForJob(jobId);
+      await showReport();
+      setStatus("הדוח נוצר בהצלחה");
+   } catch (err) {
+      setStatus(`יצירת הדוח נכשלה: ${err.message}`, true);
+   } finally {
+      $("upload-button").disabled = false;
+   }
+}
+
+function number(value, digits) {
+   return Number(value).toFixed(digits);
+}
+
+async function showReport() {
+   const [workers, validation] = await Promise.all([
+      request("GET", `api/jobs/${jobId}/workers`),
+      request("GET", `api/jobs/${jobId}/validation`),
+   ]);
+
+   // validation problems
+   const validationEl = $("validation");
+   validationEl.replaceChildren();
+   const anomalies = new Set(validation.anomalies.map((a) => a.worker_id));
+   if (validation.valid) {
+      const ok = document.createElement("p");
+      ok.className = "validation-ok";
+      ok.textContent = "לא נמצאו בעיות";
+      validationEl.append(ok);
+   } else {
+      const list = document.createElement("ul");
+      for (const anomaly of validation.anomalies) {
+         const item = document.createElement("li");
+         item.className = "validation-error";
+         item.textContent = `${anomaly.name}: ${anomaly.message}`;
+         list.append(item);
+      }
+      validationEl.append(list);
+   }
+
+   // workers table with editable adjustments
+   const body = $("workers").querySelector("tbody");
+   body.replaceChildren();
+   for (const worker of workers) {
+      const row = document.createElement("tr");
+      row.dataset.workerId = worker.id;
+      if (anomalies.has(worker.id)) {
+         row.className = "anomaly";
+      }
+
+      const cells = [
+         worker.name,
+         worker.worker_type,
+         number(worker.hours, 1),
+         number(worker.hours_125, 1),
+         number(worker.work_days, 0),
+         number(worker.sick_days, 1),
+         number(worker.vac_days, 1),
+         number(worker.trans_expanses, 0),
+         number(worker.total_sal, 0),
+      ];
+      for (const text of cells) {
+         const cell = document.createElement("td");
+         cell.textContent = text;
+         row.append(cell);
+      }
+
+      for (const field of ["holidays", "holiday_present", "hours_adjustment"]) {
+         const cell = document.createElement("td");
+         const input = document.createElement("input");
+         input.type = "number";
+         input.step = "0.5";
+         input.name = field;
+         input.value = worker.adjustment[field];
+         cell.append(input);
+         row.append(cell);
+      }
+      row.adjustment = worker.adjustment;
+      body.append(row);
+   }
+
+   $("review").hidden = false;
+   $("download").hidden = false;
+   $("download-xlsx").href = `api/jobs/${jobId}/report.xlsx`;
+}
+
+async function saveAdjustments() {
+   const adjustments = {};
+   for (const row of $("workers").querySelectorAll("tbody tr")) {
+      const adjustment = Object.assign({}, row.adjustment);
+      for (const input of row.querySelectorAll("input")) {
+         adjustment[input.name] = Number(input.value) || 0;
+      }
+      adjustments[row.dataset.workerId] = adjustment;
+   }
+
+   $("save-button").disabled = true;
+   try {
+      await request("PUT", `api/jobs/${jobId}/adjustments`, JSON.stringify(adjustments));
+      await showReport();
+      setStatus("ההתאמות נשמרו והדוח עודכן");
+   } catch (err) {
+      setStatus(`שמירת ההתאמות נכשלה: ${err.message}`, true);
+   } finally {
+      $("save-button").disabled = false;
+   }
+}
+
+const dropZone = $("drop-zone");
+dropZone.addEventListener("dragover", (event) => {
+   event.preventDefault();
+   dropZone.classList.add("active");
+});
+dropZone.addEventListener("dragleave", () => dropZone.classList.remove("active"));
+dropZone.addEventListener("drop", (event) => {
+   event.preventDefault();
+   dropZone.classList.remove("active");
+   addFiles(event.dataTransfer.files);
+});
+$("file-input").addEventListener("change", (event) => addFiles(event.target.files));
+$("upload-button").addEventListener("click", upload);
+$("save-button").addEventListener("click", saveAdjustments);
@@ -0,0 +1,43 @@
+// Package filelock locks files across processes, for the workspace and
+// queue files shared by the attendanceops commands running side by side
+package filelock
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Lock is an exclusive lock held on a lock file
+type Lock struct {
+	file *os.File
+}
+
+// Acquire opens the lock file, creating it when missing, and blocks until
+// the lock is held. The lock is released by Release, or when the process
+// exits.
+func Acquire(path string) (*Lock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %s, error: %w", path, err)
+	}
+
+	if err := lock(file); err != nil {
+		if err := file.Close(); err != nil {
+			log.Error().Msgf("failed to close lock file: %s, error: %v", path, err)
+		}
+		return nil, fmt.Errorf("failed to lock file: %s, error: %w", path, err)
+	}
+
+	return &Lock{file: file}, nil
+}
+
+// Release releases the lock and closes the lock file
+func (l *Lock) Release() error {
+	if err := unlock(l.file); err != nil {
+		l.file.Close()
+		return fmt.Errorf("failed to unlock file: %s, error: %w", l.file.Name(), err)
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,201 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/apps/cache"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/apps/confidential"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/apps/public"
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/redact"
+)
+
+// Authentication flows
+const (
+	// FlowClientCredentials gets app-only tokens of the application secret,
+	// the application sends as a configured mailbox
+	FlowClientCredentials = "client_credentials"
+
+	// FlowDeviceCode gets delegated tokens of a user signing in on another
+	// device, the application sends as the signed in user
+	FlowDeviceCode = "device_code"
+)
+
+const (
+	LoginURL = "https://login.microsoftonline.com/"
+
+	// DefaultScope requests the application permissions granted to the
+	// application, for the client credentials flow
+	DefaultScope = "https://graph.microsoft.com/.default"
+
+	// MailSendScope requests the delegated permission to send mail, for the
+	// device code flow
+	MailSendScope = "https://graph.microsoft.com/Mail.Send"
+
+	// MailReadWriteScope requests the delegated permission to read mail
+	// and flag the read messages, for the device code flow
+	MailReadWriteScope = "https://graph.microsoft.com/Mail.ReadWrite"
+)
+
+// AuthConfig configures the token provider of a flow. The tenant is
+// required by the client credentials flow, the device code flow signs in
+// users of any organization without one.
+type AuthConfig struct {
+	Flow         string
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	// Cache keeps the tokens between runs, tokens are kept in memory
+	// without one
+	Cache cache.ExportReplace
+
+	// DeviceCodePrompt shows the sign in message of the device code flow,
+	// by default the message is logged
+	DeviceCodePrompt func(message string)
+}
+
+// NewTokenProvider creates the token source of the configured flow
+func NewTokenProvider(cfg AuthConfig) (TokenSource, error) {
+	if cfg.ClientID == "" {
+		return nil, fmt.Errorf("graph client id is not set")
+	}
+	redact.Secret(cfg.ClientSecret)
+
+	switch cfg.Flow {
+	case FlowClientCredentials, "":
+		return newClientCredentialsTokens(cfg)
+	case FlowDeviceCode:
+		return newDeviceCodeTokens(cfg)
+	default:
+		return nil, fmt.Errorf("unknown graph authentication flow: %s, expected: %s or %s",
+			cfg.Flow, FlowClientCredentials, FlowDeviceCode)
+	}
+}
+
+// clientCredentialsTokens acquires app-only tokens of the application
+// secret in the tenant of the application
+type clientCredentialsTokens struct {
+	client confidential.Client
+	scopes []string
+}
+
+func newClientCredentialsTokens(cfg AuthConfig) (*clientCredentialsTokens, error) {
+	if cfg.TenantID == "" {
+		return nil, fmt.Errorf("graph tenant id is required by the %s flow", FlowClientCredentials)
+	}
+	if cfg.ClientSecret == "" {
+		return nil, fmt.Errorf("graph client secret is required by the %s flow", FlowClientCredentials)
+	}
+
+	cred, err := confidential.NewCredFromSecret(cfg.ClientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create credential, error: %w", err)
+	}
+
+	var options []confidential.Option
+	if cfg.Cache != nil {
+		options = append(options, confidential.WithCache(cfg.Cache))
+	}
+	client, err := confidential.New(LoginURL+cfg.TenantID, cfg.ClientID, cred, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create confidential client application, error: %w", err)
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{DefaultScope}
+	}
+
+	return &clientCredentialsTokens{client: client, scopes: scopes}, nil
+}
+
+func (t *clientCredentialsTokens) Token(ctx context.Context) (string, error) {
+	// use the cached token until it expires
+	result, err := t.client.AcquireTokenSilent(ctx, t.scopes)
+	if err != nil {
+		result, err = t.client.AcquireTokenByCredential(ctx, t.scopes)
+		if err != nil {
+			return "", fmt.Errorf("failed to acquire token, error: %w", err)
+		}
+	}
+
+	return result.AccessToken, nil
+}
+
+// deviceCodeTokens acquires delegated tokens of a signed in user, the user
+// signs in once and the tokens are refreshed from the cache
+type deviceCodeTokens struct {
+	client public.Client
+	scopes []string
+	prompt func(message string)
+
+	// mu serializes sign ins
+	mu sync.Mutex
+}
+
+func newDeviceCodeTokens(cfg AuthConfig) (*deviceCodeTokens, error) {
+	tenant := cfg.TenantID
+	if tenant == "" {
+		tenant = "organizations"
+	}
+
+	options := []public.Option{public.WithAuthority(LoginURL + tenant)}
+	if cfg.Cache != nil {
+		options = append(options, public.WithCache(cfg.Cache))
+	}
+	client, err := public.New(cfg.ClientID, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create public client application, error: %w", err)
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{MailSendScope}
+	}
+
+	prompt := cfg.DeviceCodePrompt
+	if prompt == nil {
+		prompt = func(message string) {
+			log.Warn().Msg(message)
+		}
+	}
+
+	return &deviceCodeTokens{client: client, scopes: scopes, prompt: prompt}, nil
+}
+
+func (t *deviceCodeTokens) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// refresh the token of the signed in user
+	accounts, err := t.client.Accounts(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get signed in accounts, error: %w", err)
+	}
+	if len(accounts) > 0 {
+		result, err := t.client.AcquireTokenSilent(ctx, t.scopes, public.WithSilentAccount(accounts[0]))
+		if err == nil {
+			return result.AccessToken, nil
+		}
+		log.Warn().Msgf("failed to refresh token of: %s, signing in again, error: %v",
+			accounts[0].PreferredUsername, err)
+	}
+
+	// sign in on another device
+	deviceCode, err := t.client.AcquireTokenByDeviceCode(ctx, t.scopes)
+	if err != nil {
+		return "", fmt.Errorf("failed to get device code, error: %w", err)
+	}
+	t.prompt(deviceCode.Result.Message)
+
+	result, err := deviceCode.AuthenticationResult(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign in with device code, error: %w", err)
+	}
+
+	return result.AccessToken, nil
+}
@@ -0,0 +1,174 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/apps/cache"
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/redact"
+	"golang.org/x/crypto/scrypt"
+)
+
+// cacheMagic starts the token cache file, followed by the salt of the key,
+// the nonce and the encrypted cache
+const cacheMagic = "bhops-token-cache-v2\n"
+
+// key derivation of the token cache, the scrypt cost parameters recommended
+// for interactive logins
+const (
+	cacheSaltSize = 16
+	cacheKeySize  = 32
+	scryptN       = 1 << 15
+	scryptR       = 8
+	scryptP       = 1
+)
+
+// EncryptedFileCache keeps the token cache in a file encrypted with
+// AES-GCM, the key is derived with scrypt from a passphrase kept out of the
+// file system, such as an environment variable, and a random salt stored
+// in the file header
+type EncryptedFileCache struct {
+	mu         sync.Mutex
+	path       string
+	passphrase string
+
+	// salt and aead of the last read or written file, the key is derived
+	// again only when the salt changes
+	salt []byte
+	aead cipher.AEAD
+}
+
+func NewEncryptedFileCache(path, passphrase string) (*EncryptedFileCache, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("token cache passphrase is not set")
+	}
+	redact.Secret(passphrase)
+
+	return &EncryptedFileCache{path: path, passphrase: passphrase}, nil
+}
+
+// keyCipher returns the AES-GCM cipher of the key derived from the passphrase
+// and the salt, called with the cache lock held
+func (c *EncryptedFileCache) keyCipher(salt []byte) (cipher.AEAD, error) {
+	if c.aead != nil && bytes.Equal(salt, c.salt) {
+		return c.aead, nil
+	}
+
+	key, err := scrypt.Key([]byte(c.passphrase), salt, scryptN, scryptR, scryptP, cacheKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive token cache key, error: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token cache cipher, error: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token cache cipher, error: %w", err)
+	}
+
+	c.salt, c.aead = bytes.Clone(salt), aead
+	return aead, nil
+}
+
+// Replace loads the decrypted cache file into the token cache, a missing
+// cache file leaves the cache empty
+func (c *EncryptedFileCache) Replace(ctx context.Context, u cache.Unmarshaler, hints cache.ReplaceHints) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	content, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read token cache: %s, error: %w", c.path, err)
+	}
+
+	// caches of earlier versions, encrypted without a salt, are dropped and
+	// the tokens are acquired again
+	if !bytes.HasPrefix(content, []byte(cacheMagic)) {
+		log.Warn().Msgf("Ignoring token cache: %s of an earlier version, sign in again", c.path)
+		return nil
+	}
+	content = content[len(cacheMagic):]
+	if len(content) < cacheSaltSize {
+		return fmt.Errorf("invalid token cache: %s", c.path)
+	}
+
+	aead, err := c.keyCipher(content[:cacheSaltSize])
+	if err != nil {
+		return err
+	}
+	content = content[cacheSaltSize:]
+
+	nonceSize := aead.NonceSize()
+	if len(content) < nonceSize {
+		return fmt.Errorf("invalid token cache: %s", c.path)
+	}
+	plain, err := aead.Open(nil, content[:nonceSize], content[nonceSize:], nil)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt token cache: %s, wrong passphrase? error: %w", c.path, err)
+	}
+
+	if err := u.Unmarshal(plain); err != nil {
+		return fmt.Errorf("failed to unmarshal token cache: %s, error: %w", c.path, err)
+	}
+
+	return nil
+}
+
+// Export encrypts the token cache and replaces the cache file atomically,
+// the file is readable by its owner only
+func (c *EncryptedFileCache) Export(ctx context.Context, m cache.Marshaler, hints cache.ExportHints) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	plain, err := m.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshal token cache, error: %w", err)
+	}
+
+	// the salt of the cache file is kept, a new file gets a random one
+	salt := c.salt
+	if salt == nil {
+		salt = make([]byte, cacheSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return fmt.Errorf("failed to create token cache salt, error: %w", err)
+		}
+	}
+	aead, err := c.keyCipher(salt)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to create token cache nonce, error: %w", err)
+	}
+	content := append([]byte(cacheMagic), salt...)
+	content = append(content, nonce...)
+	content = aead.Seal(content, nonce, plain, nil)
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create token cache dir: %s, error: %w", filepath.Dir(c.path), err)
+	}
+	tmpPath := c.path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0o600); err != nil {
+		return fmt.Errorf("failed to write token cache: %s, error: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		return fmt.Errorf("failed to rename token cache: %s, error: %w", tmpPath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/apps/cache"
+)
+
+// testTokens is the token cache content, marshaled and unmarshaled by the
+// cache
+type testTokens struct {
+	content []byte
+}
+
+func (t *testTokens) Marshal() ([]byte, error) {
+	return t.content, nil
+}
+
+func (t *testTokens) Unmarshal(content []byte) error {
+	t.content = content
+	return nil
+}
+
+func newTestCache(t *testing.T, path, passphrase string) *EncryptedFileCache {
+	t.Helper()
+
+	c, err := NewEncryptedFileCache(path, passphrase)
+	if err != nil {
+		t.Fatalf("NewEncryptedFileCache() error = %v", err)
+	}
+	return c
+}
+
+func TestEncryptedFileCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "token_cache.bin")
+	tokens := []byte(`{"AccessToken":{"secret":"access-token"}}`)
+
+	c := newTestCache(t, path, "passphrase")
+	if err := c.Export(context.Background(), &testTokens{content: tokens}, cache.ExportHints{}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if !bytes.HasPrefix(content, []byte(cacheMagic)) || bytes.Contains(content, []byte("access-token")) {
+		t.Fatalf("token cache file = %q, want encrypted tokens after the header", content)
+	}
+	salt := content[len(cacheMagic) : len(cacheMagic)+cacheSaltSize]
+
+	// a new cache reads the salt of the file, exports keep the salt
+	again := newTestCache(t, path, "passphrase")
+	var got testTokens
+	if err := again.Replace(context.Background(), &got, cache.ReplaceHints{}); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	if !bytes.Equal(got.content, tokens) {
+		t.Errorf("Replace() tokens = %s, want %s", got.content, tokens)
+	}
+	if err := again.Export(context.Background(), &got, cache.ExportHints{}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if content, _ := os.ReadFile(path); !bytes.Equal(content[len(cacheMagic):len(cacheMagic)+cacheSaltSize], salt) {
+		t.Errorf("Export() salt = %x, want the salt of the file %x",
+			content[len(cacheMagic):len(cacheMagic)+cacheSaltSize], salt)
+	}
+
+	// another file gets another salt
+	otherPath := filepath.Join(filepath.Dir(path), "other_cache.bin")
+	other := newTestCache(t, otherPath, "passphrase")
+	if err := other.Export(context.Background(), &testTokens{content: tokens}, cache.ExportHints{}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if content, _ := os.ReadFile(otherPath); bytes.Equal(content[len(cacheMagic):len(cacheMagic)+cacheSaltSize], salt) {
+		t.Errorf("Export() of another file reused salt: %x", salt)
+	}
+
+	wrong := newTestCache(t, path, "wrong passphrase")
+	if err := wrong.Replace(context.Background(), &testTokens{}, cache.ReplaceHints{}); err == nil ||
+		!strings.Contains(err.Error(), "failed to decrypt token cache") {
+		t.Errorf("Replace() with wrong passphrase error = %v, want decrypt error", err)
+	}
+}
+
+func TestEncryptedFileCacheReplace(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		wantErr string
+	}{
+		{
+			name: "missing file",
+		},
+		{
+			name:    "cache of an earlier version",
+			content: []byte("nonce and tokens encrypted without a salt"),
+		},
+		{
+			name:    "truncated salt",
+			content: []byte(cacheMagic + "salt"),
+			wantErr: "invalid token cache",
+		},
+		{
+			name:    "truncated nonce",
+			content: []byte(cacheMagic + strings.Repeat("s", cacheSaltSize) + "nonce"),
+			wantErr: "invalid token cache",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "token_cache.bin")
+			if tt.content != nil {
+				if err := os.WriteFile(path, tt.content, 0o600); err != nil {
+					t.Fatalf("WriteFile() error = %v", err)
+				}
+			}
+
+			var got testTokens
+			err := newTestCache(t, path, "passphrase").Replace(context.Background(), &got, cache.ReplaceHints{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Replace() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got.content != nil {
+				t.Errorf("Replace() = %q error = %v, want an empty cache", got.content, err)
+			}
+		})
+	}
+}
+
+func TestNewEncryptedFileCacheWithoutPassphrase(t *testing.T) {
+	if _, err := NewEncryptedFileCache(filepath.Join(t.TempDir(), "token_cache.bin"), ""); err == nil {
+		t.Errorf("NewEncryptedFileCache() without passphrase error = nil, want error")
+	}
+}
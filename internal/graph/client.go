@@ -0,0 +1,122 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBaseURL = "https://graph.microsoft.com/v1.0"
+	DefaultTimeout = 60 * time.Second
+)
+
+// TokenSource returns the access token of the Graph requests
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is a token source of a fixed access token
+type StaticToken string
+
+func (t StaticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+// Client calls the Microsoft Graph API with the tokens of its token source.
+// Mail is sent as the Mailbox user, app-only tokens require it, without
+// one mail is sent as the signed in user. Transient failures are retried
+// by the Retry policy.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	Mailbox    string
+	Retry      RetryPolicy
+
+	// MaxConcurrentSends caps the mails sent at a time, to stay within the
+	// mailbox limits, it is read on the first send
+	MaxConcurrentSends int
+
+	tokens    TokenSource
+	sendsOnce sync.Once
+	sends     chan struct{}
+}
+
+func NewClient(tokens TokenSource) *Client {
+	return &Client{
+		BaseURL:            DefaultBaseURL,
+		HTTPClient:         &http.Client{Timeout: DefaultTimeout},
+		Retry:              DefaultRetryPolicy(),
+		MaxConcurrentSends: DefaultMaxConcurrentSends,
+		tokens:             tokens,
+	}
+}
+
+// mailboxPath returns the Graph path of the client mailbox
+func (c *Client) mailboxPath() string {
+	if c.Mailbox == "" {
+		return "/me"
+	}
+	return "/users/" + url.PathEscape(c.Mailbox)
+}
+
+// do sends a JSON request to the Graph path and decodes the JSON response
+// into out. Responses with a status other than the expected ones are
+// decoded as a Graph *Error.
+func (c *Client) do(ctx context.Context, method, path string, in, out any, expected ...int) error {
+	var content []byte
+	if in != nil {
+		var err error
+		if content, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("failed to marshal request: %s %s, error: %w", method, path, err)
+		}
+	}
+
+	// a request is made for each attempt, with a fresh token
+	resp, err := c.send(ctx, func() (*http.Request, error) {
+		var body io.Reader
+		if in != nil {
+			body = bytes.NewReader(content)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.BaseURL, "/")+path, body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %s %s, error: %w", method, path, err)
+		}
+		if in != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		token, err := c.tokens.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get access token, error: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if !slices.Contains(expected, resp.StatusCode) {
+		return decodeError(resp)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %s %s, error: %w", method, path, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/vgeshiktor/bhops/internal/redact"
+)
+
+// maxErrorBody bounds the error response read from Graph
+const maxErrorBody = 64 * 1024
+
+// Error is a Graph error response
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestID  string
+	Date       string
+
+	// RetryAfter is the delay requested by the Retry-After header of
+	// throttled and unavailable responses
+	RetryAfter time.Duration
+
+	// Body is the raw response body of errors not in the Graph format, with
+	// secrets redacted
+	Body string
+}
+
+// errorResponse is the JSON body of Graph error responses
+type errorResponse struct {
+	Error struct {
+		Code       string `json:"code"`
+		Message    string `json:"message"`
+		InnerError struct {
+			RequestID       string `json:"request-id"`
+			ClientRequestID string `json:"client-request-id"`
+			Date            string `json:"date"`
+		} `json:"innerError"`
+	} `json:"error"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("graph request failed, status: %d, response: %s", e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("graph request failed, status: %d, code: %s, message: %s, request id: %s",
+		e.StatusCode, e.Code, e.Message, e.RequestID)
+}
+
+// Temporary reports whether the request may succeed when sent again later,
+// for throttled requests, timeouts and server errors
+func (e *Error) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode >= http.StatusInternalServerError
+}
+
+// RetryDelay returns the delay requested by the response before a retry
+func (e *Error) RetryDelay() time.Duration {
+	return e.RetryAfter
+}
+
+// decodeError decodes the Graph error of a failed response
+func decodeError(resp *http.Response) error {
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if err != nil {
+		return fmt.Errorf("failed to read error response, status: %d, error: %w", resp.StatusCode, err)
+	}
+
+	graphErr := &Error{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("request-id"),
+		RetryAfter: retryAfter(resp.Header),
+	}
+
+	var body errorResponse
+	if err := json.Unmarshal(content, &body); err != nil || body.Error.Code == "" {
+		graphErr.Body = redact.String(string(content))
+		return graphErr
+	}
+
+	graphErr.Code = body.Error.Code
+	graphErr.Message = body.Error.Message
+	graphErr.Date = body.Error.InnerError.Date
+	if body.Error.InnerError.RequestID != "" {
+		graphErr.RequestID = body.Error.InnerError.RequestID
+	}
+
+	return graphErr
+}
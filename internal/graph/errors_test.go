@@ -0,0 +1,173 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/bhops/internal/mail"
+)
+
+func TestDecodeError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		header  http.Header
+		body    string
+		want    Error
+		wantErr string
+	}{
+		{
+			name:   "graph error body",
+			status: http.StatusBadRequest,
+			header: http.Header{"Request-Id": {"header-request-id"}},
+			body: `{"error":{"code":"ErrorInvalidRecipients","message":"At least one recipient isn't valid.",` +
+				`"innerError":{"request-id":"body-request-id","date":"2025-02-01T10:00:00"}}}`,
+			want: Error{
+				StatusCode: http.StatusBadRequest,
+				Code:       "ErrorInvalidRecipients",
+				Message:    "At least one recipient isn't valid.",
+				RequestID:  "body-request-id",
+				Date:       "2025-02-01T10:00:00",
+			},
+			wantErr: "code: ErrorInvalidRecipients",
+		},
+		{
+			name:   "request id header without inner error",
+			status: http.StatusNotFound,
+			header: http.Header{"Request-Id": {"header-request-id"}},
+			body:   `{"error":{"code":"ErrorItemNotFound","message":"The specified object was not found in the store."}}`,
+			want: Error{
+				StatusCode: http.StatusNotFound,
+				Code:       "ErrorItemNotFound",
+				Message:    "The specified object was not found in the store.",
+				RequestID:  "header-request-id",
+			},
+			wantErr: "request id: header-request-id",
+		},
+		{
+			name:   "throttled with retry after",
+			status: http.StatusTooManyRequests,
+			header: http.Header{"Retry-After": {"7"}},
+			body:   `{"error":{"code":"TooManyRequests","message":"Please retry again later."}}`,
+			want: Error{
+				StatusCode: http.StatusTooManyRequests,
+				Code:       "TooManyRequests",
+				Message:    "Please retry again later.",
+				RetryAfter: 7 * time.Second,
+			},
+			wantErr: "status: 429",
+		},
+		{
+			name:   "body not in graph format is redacted",
+			status: http.StatusBadGateway,
+			body:   `upstream failed for Authorization: Bearer abc.def.ghi`,
+			want: Error{
+				StatusCode: http.StatusBadGateway,
+				Body:       "upstream failed for Authorization: Bearer [REDACTED]",
+			},
+			wantErr: "response: upstream failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.header
+			if header == nil {
+				header = http.Header{}
+			}
+			resp := &http.Response{
+				StatusCode: tt.status,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+
+			var graphErr *Error
+			err := decodeError(resp)
+			if !errors.As(err, &graphErr) {
+				t.Fatalf("decodeError() = %v, want *Error", err)
+			}
+			if *graphErr != tt.want {
+				t.Errorf("decodeError() = %+v, want %+v", *graphErr, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Error() = %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSendMailGraphError(t *testing.T) {
+	tests := []struct {
+		name          string
+		setup         func(client *Client, fail func(...int))
+		wantStatus    int
+		wantCode      string
+		wantTemporary bool
+	}{
+		{
+			name: "invalid token",
+			setup: func(client *Client, fail func(...int)) {
+				client.tokens = StaticToken("expired-access-token")
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "InvalidAuthenticationToken",
+		},
+		{
+			// sendMail is not idempotent, unavailable responses are not
+			// retried
+			name: "service unavailable",
+			setup: func(client *Client, fail func(...int)) {
+				fail(http.StatusServiceUnavailable)
+			},
+			wantStatus:    http.StatusServiceUnavailable,
+			wantCode:      "serviceNotAvailable",
+			wantTemporary: true,
+		},
+		{
+			name: "throttled on every attempt",
+			setup: func(client *Client, fail func(...int)) {
+				fail(http.StatusTooManyRequests, http.StatusTooManyRequests)
+				client.Retry.MaxAttempts = 2
+			},
+			wantStatus:    http.StatusTooManyRequests,
+			wantCode:      "TooManyRequests",
+			wantTemporary: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := newTestClient(t)
+			tt.setup(client, server.FailNext)
+
+			err := client.SendMail(context.Background(), mail.Mail{
+				Subject:  "Payslip",
+				TextBody: "Hello",
+				To:       []string{"dana@example.com"},
+			})
+
+			var graphErr *Error
+			if !errors.As(err, &graphErr) {
+				t.Fatalf("SendMail() error = %v, want *Error", err)
+			}
+			if graphErr.StatusCode != tt.wantStatus || graphErr.Code != tt.wantCode {
+				t.Errorf("error status: %d code: %s, want status: %d code: %s",
+					graphErr.StatusCode, graphErr.Code, tt.wantStatus, tt.wantCode)
+			}
+			if graphErr.RequestID == "" {
+				t.Error("error has no request id")
+			}
+			if graphErr.Temporary() != tt.wantTemporary {
+				t.Errorf("Temporary() = %v, want %v", graphErr.Temporary(), tt.wantTemporary)
+			}
+			if sent := server.Sent(); len(sent) != 0 {
+				t.Errorf("sent %d mails, want 0", len(sent))
+			}
+		})
+	}
+}
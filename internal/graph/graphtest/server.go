@@ -0,0 +1,486 @@
+// Package graphtest provides a local fake of the Microsoft Graph mail API,
+// for running the mail senders and the inbox poller without a Microsoft
+// account
+package graphtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a mail sent through the fake server, or received in its inbox
+type Message struct {
+	ID            string       `json:"id"`
+	Subject       string       `json:"subject"`
+	From          *Recipient   `json:"from,omitempty"`
+	Body          Body         `json:"body"`
+	ToRecipients  []Recipient  `json:"toRecipients"`
+	CcRecipients  []Recipient  `json:"ccRecipients"`
+	BccRecipients []Recipient  `json:"bccRecipients"`
+	Attachments   []Attachment `json:"attachments"`
+	Categories    []string     `json:"categories"`
+	IsRead        bool         `json:"isRead"`
+	ReceivedAt    time.Time    `json:"receivedDateTime"`
+	SentAt        time.Time    `json:"-"`
+
+	// Mailbox is the user id of /users/{id} requests, or "me"
+	Mailbox string `json:"-"`
+}
+
+type Body struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+type Recipient struct {
+	EmailAddress struct {
+		Address string `json:"address"`
+	} `json:"emailAddress"`
+}
+
+type Attachment struct {
+	ID           string `json:"id,omitempty"`
+	Name         string `json:"name"`
+	ContentType  string `json:"contentType"`
+	ContentBytes []byte `json:"contentBytes"`
+}
+
+// upload is a large attachment upload session of a draft
+type upload struct {
+	draftID    string
+	attachment Attachment
+	size       int
+}
+
+// Server is a fake Graph server accepting the requests of the graph client
+// with its access token. Sent mails are kept in memory, requests with a
+// wrong token fail with a Graph error body.
+type Server struct {
+	*httptest.Server
+
+	// Token is the accepted access token
+	Token string
+
+	// AppOnly rejects /me requests, as Graph does for app-only tokens
+	AppOnly bool
+
+	// RetryAfter is the Retry-After header of the injected 429 and 503
+	// failures
+	RetryAfter time.Duration
+
+	mu      sync.Mutex
+	nextID  int
+	drafts  map[string]*Message
+	uploads map[string]*upload
+	sent    []Message
+	inbox   []*Message
+	fail    []int
+}
+
+// NewServer starts a fake Graph server, the client base URL is the server
+// URL
+func NewServer(token string) *Server {
+	s := &Server{
+		Token:   token,
+		drafts:  map[string]*Message{},
+		uploads: map[string]*upload{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Sent returns the mails sent through the server
+func (s *Server) Sent() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.sent...)
+}
+
+// Deliver adds a message received from the address to the inbox, and
+// returns its id
+func (s *Server) Deliver(from, subject string, attachments ...Attachment) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg := &Message{
+		ID:         s.newID("inbox"),
+		Subject:    subject,
+		From:       &Recipient{},
+		ReceivedAt: time.Now().UTC(),
+	}
+	msg.From.EmailAddress.Address = from
+	for _, a := range attachments {
+		a.ID = s.newID("attachment")
+		msg.Attachments = append(msg.Attachments, a)
+	}
+	s.inbox = append(s.inbox, msg)
+
+	return msg.ID
+}
+
+// Inbox returns the messages of the inbox
+func (s *Server) Inbox() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var inbox []Message
+	for _, msg := range s.inbox {
+		inbox = append(inbox, *msg)
+	}
+	return inbox
+}
+
+// FailNext makes the next requests fail with the status codes, in order
+func (s *Server) FailNext(statusCodes ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fail = append(s.fail, statusCodes...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.fail) > 0 {
+		status := s.fail[0]
+		s.fail = s.fail[1:]
+		if s.RetryAfter > 0 && (status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(s.RetryAfter.Seconds())))
+		}
+		code := "serviceNotAvailable"
+		if status == http.StatusTooManyRequests {
+			code = "TooManyRequests"
+		}
+		writeError(w, status, code, "injected failure")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	// upload session URLs are pre-authenticated
+	if len(parts) == 2 && parts[0] == "upload" && r.Method == http.MethodPut {
+		s.uploadChunk(w, r, parts[1])
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+s.Token {
+		writeError(w, http.StatusUnauthorized, "InvalidAuthenticationToken", "Access token is empty or invalid.")
+		return
+	}
+
+	// serve /users/{id} requests as /me requests of the mailbox
+	mailbox := "me"
+	if len(parts) > 2 && parts[0] == "users" {
+		mailbox = parts[1]
+		parts = append([]string{"me"}, parts[2:]...)
+	} else if s.AppOnly && len(parts) > 0 && parts[0] == "me" {
+		writeError(w, http.StatusBadRequest, "BadRequest", "/me request is only valid with delegated authentication flow.")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && match(parts, "me", "mailFolders", "*", "messages"):
+		s.listInbox(w, r, parts[2])
+	case r.Method == http.MethodGet && match(parts, "me", "messages", "*", "attachments", "*"):
+		s.getAttachment(w, parts[2], parts[4])
+	case r.Method == http.MethodPatch && match(parts, "me", "messages", "*"):
+		s.updateMessage(w, r, parts[2])
+	case r.Method == http.MethodPost && match(parts, "me", "sendMail"):
+		var req struct {
+			Message Message `json:"message"`
+		}
+		if !decode(w, r, &req) {
+			return
+		}
+		req.Message.Mailbox = mailbox
+		s.send(w, req.Message)
+	case r.Method == http.MethodPost && match(parts, "me", "messages"):
+		var msg Message
+		if !decode(w, r, &msg) {
+			return
+		}
+		msg.ID = s.newID("draft")
+		msg.Mailbox = mailbox
+		s.drafts[msg.ID] = &msg
+		writeJSON(w, http.StatusCreated, msg)
+	case r.Method == http.MethodPost && match(parts, "me", "messages", "*", "attachments"):
+		draft, ok := s.draft(w, parts[2])
+		if !ok {
+			return
+		}
+		var attachment Attachment
+		if !decode(w, r, &attachment) {
+			return
+		}
+		draft.Attachments = append(draft.Attachments, attachment)
+		writeJSON(w, http.StatusCreated, attachment)
+	case r.Method == http.MethodPost && match(parts, "me", "messages", "*", "attachments", "createUploadSession"):
+		if _, ok := s.draft(w, parts[2]); !ok {
+			return
+		}
+		var req struct {
+			AttachmentItem struct {
+				Name        string `json:"name"`
+				Size        int    `json:"size"`
+				ContentType string `json:"contentType"`
+			} `json:"AttachmentItem"`
+		}
+		if !decode(w, r, &req) {
+			return
+		}
+		id := s.newID("upload")
+		s.uploads[id] = &upload{
+			draftID:    parts[2],
+			attachment: Attachment{Name: req.AttachmentItem.Name, ContentType: req.AttachmentItem.ContentType},
+			size:       req.AttachmentItem.Size,
+		}
+		writeJSON(w, http.StatusCreated, map[string]any{
+			"uploadUrl":          s.URL + "/upload/" + id,
+			"nextExpectedRanges": []string{"0-"},
+		})
+	case r.Method == http.MethodPost && match(parts, "me", "messages", "*", "send"):
+		draft, ok := s.draft(w, parts[2])
+		if !ok {
+			return
+		}
+		delete(s.drafts, draft.ID)
+		s.send(w, *draft)
+	default:
+		writeError(w, http.StatusNotFound, "ResourceNotFound", "Resource not found for the segment '"+parts[len(parts)-1]+"'.")
+	}
+}
+
+// listInbox lists the inbox messages with attachments received since the
+// receivedDateTime of the $filter, newest first. Pages have $top messages
+// and link to the next page with $skip.
+func (s *Server) listInbox(w http.ResponseWriter, r *http.Request, folder string) {
+	if !strings.EqualFold(folder, "inbox") {
+		writeError(w, http.StatusNotFound, "ErrorInvalidIdMalformed", "Id is malformed.")
+		return
+	}
+
+	query := r.URL.Query()
+	var since time.Time
+	if _, after, ok := strings.Cut(query.Get("$filter"), "receivedDateTime ge "); ok {
+		var err error
+		if since, err = time.Parse(time.RFC3339, strings.Fields(after)[0]); err != nil {
+			writeError(w, http.StatusBadRequest, "BadRequest", "Invalid filter clause: "+err.Error())
+			return
+		}
+	}
+	top, err := strconv.Atoi(query.Get("$top"))
+	if err != nil || top <= 0 {
+		top = 10
+	}
+	skip, _ := strconv.Atoi(query.Get("$skip"))
+
+	var matched []map[string]any
+	for i := len(s.inbox) - 1; i >= 0; i-- {
+		msg := s.inbox[i]
+		if len(msg.Attachments) == 0 || msg.ReceivedAt.Before(since) {
+			continue
+		}
+
+		// attachments are listed without their content
+		var attachments []map[string]any
+		for _, a := range msg.Attachments {
+			attachments = append(attachments, map[string]any{
+				"id": a.ID, "name": a.Name, "contentType": a.ContentType, "size": len(a.ContentBytes),
+			})
+		}
+		matched = append(matched, map[string]any{
+			"id":               msg.ID,
+			"subject":          msg.Subject,
+			"from":             msg.From,
+			"receivedDateTime": msg.ReceivedAt,
+			"categories":       msg.Categories,
+			"attachments":      attachments,
+		})
+	}
+
+	page := map[string]any{"value": []map[string]any{}}
+	if skip < len(matched) {
+		end := min(skip+top, len(matched))
+		page["value"] = matched[skip:end]
+		if end < len(matched) {
+			query.Set("$skip", strconv.Itoa(end))
+			page["@odata.nextLink"] = s.URL + r.URL.Path + "?" + query.Encode()
+		}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) getAttachment(w http.ResponseWriter, messageID, attachmentID string) {
+	msg, ok := s.inboxMessage(w, messageID)
+	if !ok {
+		return
+	}
+
+	for _, a := range msg.Attachments {
+		if a.ID == attachmentID {
+			writeJSON(w, http.StatusOK, map[string]any{
+				"@odata.type":  "#microsoft.graph.fileAttachment",
+				"id":           a.ID,
+				"name":         a.Name,
+				"contentType":  a.ContentType,
+				"size":         len(a.ContentBytes),
+				"contentBytes": a.ContentBytes,
+			})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "ErrorItemNotFound", "The specified object was not found in the store.")
+}
+
+// updateMessage updates the categories and the read flag of a message
+func (s *Server) updateMessage(w http.ResponseWriter, r *http.Request, id string) {
+	msg, ok := s.inboxMessage(w, id)
+	if !ok {
+		return
+	}
+
+	var update struct {
+		Categories *[]string `json:"categories"`
+		IsRead     *bool     `json:"isRead"`
+	}
+	if !decode(w, r, &update) {
+		return
+	}
+	if update.Categories != nil {
+		msg.Categories = *update.Categories
+	}
+	if update.IsRead != nil {
+		msg.IsRead = *update.IsRead
+	}
+	writeJSON(w, http.StatusOK, msg)
+}
+
+func (s *Server) inboxMessage(w http.ResponseWriter, id string) (*Message, bool) {
+	for _, msg := range s.inbox {
+		if msg.ID == id {
+			return msg, true
+		}
+	}
+	writeError(w, http.StatusNotFound, "ErrorItemNotFound", "The specified object was not found in the store.")
+	return nil, false
+}
+
+// uploadChunk appends a chunk to the attachment of an upload session, the
+// last chunk adds the attachment to the draft
+func (s *Server) uploadChunk(w http.ResponseWriter, r *http.Request, id string) {
+	session, ok := s.uploads[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "ResourceNotFound", "Upload session not found.")
+		return
+	}
+
+	chunk, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+
+	// Content-Range: bytes start-end/total
+	var start, end, total int
+	if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil ||
+		start != len(session.attachment.ContentBytes) || end-start+1 != len(chunk) || total != session.size {
+		writeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange",
+			"Invalid Content-Range: "+r.Header.Get("Content-Range"))
+		return
+	}
+	session.attachment.ContentBytes = append(session.attachment.ContentBytes, chunk...)
+
+	if len(session.attachment.ContentBytes) < session.size {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"nextExpectedRanges": []string{strconv.Itoa(len(session.attachment.ContentBytes)) + "-"},
+		})
+		return
+	}
+
+	delete(s.uploads, id)
+	if draft, ok := s.drafts[session.draftID]; ok {
+		draft.Attachments = append(draft.Attachments, session.attachment)
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) send(w http.ResponseWriter, msg Message) {
+	if len(msg.ToRecipients)+len(msg.CcRecipients)+len(msg.BccRecipients) == 0 {
+		writeError(w, http.StatusBadRequest, "ErrorInvalidRecipients", "At least one recipient isn't valid.")
+		return
+	}
+
+	if msg.ID == "" {
+		msg.ID = s.newID("message")
+	}
+	msg.SentAt = time.Now()
+	s.sent = append(s.sent, msg)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) draft(w http.ResponseWriter, id string) (*Message, bool) {
+	draft, ok := s.drafts[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "ErrorItemNotFound", "The specified object was not found in the store.")
+	}
+	return draft, ok
+}
+
+func (s *Server) newID(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s-%d", prefix, s.nextID)
+}
+
+// match reports whether the path parts match the pattern, * matches any
+// part
+func match(parts []string, pattern ...string) bool {
+	if len(parts) != len(pattern) {
+		return false
+	}
+	for i, p := range pattern {
+		if p != "*" && p != parts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", "Unable to read JSON request payload.")
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a Graph error body
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	requestID := fmt.Sprintf("fake-%d", time.Now().UnixNano())
+	w.Header().Set("request-id", requestID)
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": message,
+			"innerError": map[string]any{
+				"request-id": requestID,
+				"date":       time.Now().UTC().Format("2006-01-02T15:04:05"),
+			},
+		},
+	})
+}
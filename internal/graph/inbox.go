@@ -0,0 +1,123 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MessagesPageSize is the number of messages listed per request
+const MessagesPageSize = 50
+
+// Message is a received mail message with its attachments metadata
+type Message struct {
+	ID          string
+	Subject     string
+	From        string
+	ReceivedAt  time.Time
+	Categories  []string
+	Attachments []AttachmentInfo
+}
+
+// AttachmentInfo is the metadata of a message attachment, the content is
+// read with GetAttachment
+type AttachmentInfo struct {
+	ID          string
+	Name        string
+	ContentType string
+	Size        int
+}
+
+// receivedMessage is the Graph message resource of listed messages
+type receivedMessage struct {
+	ID               string    `json:"id"`
+	Subject          string    `json:"subject"`
+	From             recipient `json:"from"`
+	ReceivedDateTime time.Time `json:"receivedDateTime"`
+	Categories       []string  `json:"categories"`
+	Attachments      []struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		ContentType string `json:"contentType"`
+		Size        int    `json:"size"`
+	} `json:"attachments"`
+}
+
+type messagesPage struct {
+	Value    []receivedMessage `json:"value"`
+	NextLink string            `json:"@odata.nextLink"`
+}
+
+// ListMessages lists the messages with attachments of a mail folder, such
+// as "inbox", received since the time, newest first
+func (c *Client) ListMessages(ctx context.Context, folder string, since time.Time) ([]Message, error) {
+	query := url.Values{}
+	query.Set("$filter", fmt.Sprintf("hasAttachments eq true and receivedDateTime ge %s", since.UTC().Format(time.RFC3339)))
+	query.Set("$orderby", "receivedDateTime desc")
+	query.Set("$select", "id,subject,from,receivedDateTime,categories")
+	query.Set("$expand", "attachments($select=id,name,contentType,size)")
+	query.Set("$top", fmt.Sprint(MessagesPageSize))
+
+	path := c.mailboxPath() + "/mailFolders/" + url.PathEscape(folder) + "/messages?" + query.Encode()
+
+	var messages []Message
+	for path != "" {
+		var page messagesPage
+		if err := c.do(ctx, http.MethodGet, path, nil, &page, http.StatusOK); err != nil {
+			return nil, fmt.Errorf("failed to list messages of folder: %s, error: %w", folder, err)
+		}
+
+		for _, m := range page.Value {
+			message := Message{
+				ID:         m.ID,
+				Subject:    m.Subject,
+				From:       m.From.EmailAddress.Address,
+				ReceivedAt: m.ReceivedDateTime,
+				Categories: m.Categories,
+			}
+			for _, a := range m.Attachments {
+				message.Attachments = append(message.Attachments, AttachmentInfo{
+					ID:          a.ID,
+					Name:        a.Name,
+					ContentType: a.ContentType,
+					Size:        a.Size,
+				})
+			}
+			messages = append(messages, message)
+		}
+
+		// next links are absolute URLs of the base URL
+		path = strings.TrimPrefix(page.NextLink, strings.TrimSuffix(c.BaseURL, "/"))
+	}
+
+	return messages, nil
+}
+
+// GetAttachment reads the content of a file attachment of a message
+func (c *Client) GetAttachment(ctx context.Context, messageID, attachmentID string) ([]byte, error) {
+	var attachment fileAttachment
+	path := c.mailboxPath() + "/messages/" + url.PathEscape(messageID) + "/attachments/" + url.PathEscape(attachmentID)
+	if err := c.do(ctx, http.MethodGet, path, nil, &attachment, http.StatusOK); err != nil {
+		return nil, fmt.Errorf("failed to get attachment: %s of message: %s, error: %w", attachmentID, messageID, err)
+	}
+
+	return attachment.ContentBytes, nil
+}
+
+// SetCategories replaces the categories of a message and marks it as read
+func (c *Client) SetCategories(ctx context.Context, messageID string, categories []string) error {
+	update := struct {
+		Categories []string `json:"categories"`
+		IsRead     bool     `json:"isRead"`
+	}{Categories: categories, IsRead: true}
+
+	path := c.mailboxPath() + "/messages/" + url.PathEscape(messageID)
+	if err := c.do(ctx, http.MethodPatch, path, update, nil, http.StatusOK); err != nil {
+		return fmt.Errorf("failed to update categories of message: %s, error: %w", messageID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/vgeshiktor/bhops/internal/mail"
+)
+
+// Graph body content types
+const (
+	bodyHTML = "HTML"
+	bodyText = "Text"
+)
+
+const (
+	// LargeAttachmentSize is the size of mails sent in a single request,
+	// mails with larger attachments are uploaded to a draft first
+	LargeAttachmentSize = 3 * 1024 * 1024
+
+	// UploadChunkSize is the size of the large attachment upload chunks,
+	// Graph requires a multiple of 320 KiB
+	UploadChunkSize = 10 * 320 * 1024
+)
+
+// message is the Graph message resource
+type message struct {
+	ID            string           `json:"id,omitempty"`
+	Subject       string           `json:"subject"`
+	Body          itemBody         `json:"body"`
+	ToRecipients  []recipient      `json:"toRecipients,omitempty"`
+	CcRecipients  []recipient      `json:"ccRecipients,omitempty"`
+	BccRecipients []recipient      `json:"bccRecipients,omitempty"`
+	Attachments   []fileAttachment `json:"attachments,omitempty"`
+}
+
+type itemBody struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+type recipient struct {
+	EmailAddress emailAddress `json:"emailAddress"`
+}
+
+type emailAddress struct {
+	Address string `json:"address"`
+	Name    string `json:"name,omitempty"`
+}
+
+type fileAttachment struct {
+	ODataType    string `json:"@odata.type"`
+	Name         string `json:"name"`
+	ContentType  string `json:"contentType"`
+	ContentBytes []byte `json:"contentBytes"`
+}
+
+type sendMailRequest struct {
+	Message         message `json:"message"`
+	SaveToSentItems bool    `json:"saveToSentItems"`
+}
+
+type uploadSessionRequest struct {
+	AttachmentItem struct {
+		AttachmentType string `json:"attachmentType"`
+		Name           string `json:"name"`
+		Size           int    `json:"size"`
+		ContentType    string `json:"contentType,omitempty"`
+	} `json:"AttachmentItem"`
+}
+
+type uploadSession struct {
+	UploadURL          string   `json:"uploadUrl"`
+	NextExpectedRanges []string `json:"nextExpectedRanges"`
+}
+
+// SendMail sends the mail from the client mailbox and saves it to the sent
+// items. Mails with attachments larger than LargeAttachmentSize are
+// created as a draft, their large attachments are uploaded in chunks and
+// the draft is sent. At most MaxConcurrentSends mails are sent at a time.
+func (c *Client) SendMail(ctx context.Context, m mail.Mail) error {
+	if len(m.To)+len(m.Cc)+len(m.Bcc) == 0 {
+		return fmt.Errorf("mail: %q has no recipients", m.Subject)
+	}
+
+	release, err := c.acquireSend(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to send mail: %q, error: %w", m.Subject, err)
+	}
+	defer release()
+
+	size := 0
+	for _, a := range m.Attachments {
+		size += len(a.Content)
+	}
+
+	msg := newMessage(m)
+	if size <= LargeAttachmentSize {
+		for _, a := range m.Attachments {
+			msg.Attachments = append(msg.Attachments, newFileAttachment(a))
+		}
+		req := sendMailRequest{Message: msg, SaveToSentItems: true}
+		if err := c.do(ctx, http.MethodPost, c.mailboxPath()+"/sendMail", req, nil, http.StatusAccepted); err != nil {
+			return fmt.Errorf("failed to send mail: %q, error: %w", m.Subject, err)
+		}
+		return nil
+	}
+
+	// create draft, attach files and send it
+	var draft message
+	if err := c.do(ctx, http.MethodPost, c.mailboxPath()+"/messages", msg, &draft, http.StatusCreated); err != nil {
+		return fmt.Errorf("failed to create draft of mail: %q, error: %w", m.Subject, err)
+	}
+
+	draftPath := c.mailboxPath() + "/messages/" + url.PathEscape(draft.ID)
+	for _, a := range m.Attachments {
+		if err := c.attach(ctx, draftPath, a); err != nil {
+			return fmt.Errorf("failed to attach: %s to mail: %q, error: %w", a.Name, m.Subject, err)
+		}
+	}
+
+	if err := c.do(ctx, http.MethodPost, draftPath+"/send", nil, nil, http.StatusAccepted); err != nil {
+		return fmt.Errorf("failed to send draft of mail: %q, error: %w", m.Subject, err)
+	}
+
+	return nil
+}
+
+// attach adds an attachment to a draft, large attachments are uploaded in
+// an upload session
+func (c *Client) attach(ctx context.Context, draftPath string, a mail.Attachment) error {
+	if len(a.Content) <= LargeAttachmentSize {
+		return c.do(ctx, http.MethodPost, draftPath+"/attachments", newFileAttachment(a), nil, http.StatusCreated)
+	}
+
+	var req uploadSessionRequest
+	req.AttachmentItem.AttachmentType = "file"
+	req.AttachmentItem.Name = a.Name
+	req.AttachmentItem.Size = len(a.Content)
+	req.AttachmentItem.ContentType = a.MediaType()
+
+	var session uploadSession
+	err := c.do(ctx, http.MethodPost, draftPath+"/attachments/createUploadSession", req, &session, http.StatusCreated)
+	if err != nil {
+		return fmt.Errorf("failed to create upload session, error: %w", err)
+	}
+
+	for start := 0; start < len(a.Content); start += UploadChunkSize {
+		end := min(start+UploadChunkSize, len(a.Content))
+		if err := c.uploadChunk(ctx, session.UploadURL, a.Content[start:end], start, len(a.Content)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// uploadChunk puts a chunk of an attachment to the upload session URL. The
+// URL is pre-authenticated, it must not get the access token.
+func (c *Client) uploadChunk(ctx context.Context, uploadURL string, chunk []byte, start, total int) error {
+	resp, err := c.send(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, uploadURL, bytes.NewReader(chunk))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create upload request, error: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+len(chunk)-1, total))
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload bytes: %d-%d, error: %w", start, start+len(chunk)-1, err)
+	}
+	defer resp.Body.Close()
+
+	// the last chunk creates the attachment
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("failed to upload bytes: %d-%d, error: %w", start, start+len(chunk)-1, decodeError(resp))
+	}
+
+	return nil
+}
+
+func newMessage(m mail.Mail) message {
+	body := itemBody{ContentType: bodyHTML, Content: m.HTMLBody}
+	if m.HTMLBody == "" {
+		body = itemBody{ContentType: bodyText, Content: m.TextBody}
+	}
+
+	return message{
+		Subject:       m.Subject,
+		Body:          body,
+		ToRecipients:  recipients(m.To),
+		CcRecipients:  recipients(m.Cc),
+		BccRecipients: recipients(m.Bcc),
+	}
+}
+
+func newFileAttachment(a mail.Attachment) fileAttachment {
+	return fileAttachment{
+		ODataType:    "#microsoft.graph.fileAttachment",
+		Name:         a.Name,
+		ContentType:  a.MediaType(),
+		ContentBytes: a.Content,
+	}
+}
+
+func recipients(addresses []string) []recipient {
+	var list []recipient
+	for _, address := range addresses {
+		list = append(list, recipient{EmailAddress: emailAddress{Address: address}})
+	}
+	return list
+}
@@ -0,0 +1,204 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/bhops/internal/graph/graphtest"
+	"github.com/vgeshiktor/bhops/internal/mail"
+)
+
+const testToken = "test-access-token"
+
+// newTestClient returns a client of a fake Graph server, retrying without
+// waiting
+func newTestClient(t *testing.T) (*Client, *graphtest.Server) {
+	t.Helper()
+
+	server := graphtest.NewServer(testToken)
+	t.Cleanup(server.Close)
+
+	client := NewClient(StaticToken(testToken))
+	client.BaseURL = server.URL
+	client.Mailbox = "payroll@example.com"
+	client.Retry.InitialBackoff = time.Millisecond
+	client.Retry.MaxBackoff = time.Millisecond
+
+	return client, server
+}
+
+// requestLog records the method and path of the client requests
+type requestLog struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (l *requestLog) RoundTrip(req *http.Request) (*http.Response, error) {
+	l.mu.Lock()
+	l.requests = append(l.requests, req.Method+" "+req.URL.Path)
+	l.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (l *requestLog) count(method, pathSuffix string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := 0
+	for _, r := range l.requests {
+		if strings.HasPrefix(r, method+" ") && strings.HasSuffix(r, pathSuffix) {
+			n++
+		}
+	}
+	return n
+}
+
+func addresses(recipients []graphtest.Recipient) []string {
+	var list []string
+	for _, r := range recipients {
+		list = append(list, r.EmailAddress.Address)
+	}
+	return list
+}
+
+func TestSendMail(t *testing.T) {
+	client, server := newTestClient(t)
+
+	m := mail.Mail{
+		Subject:  "Payslip 2025-01",
+		TextBody: "Hello",
+		HTMLBody: "<p>Hello</p>",
+		To:       []string{"dana@example.com", "avi@example.com"},
+		Cc:       []string{"office@example.com"},
+		Bcc:      []string{"archive@example.com"},
+		Attachments: []mail.Attachment{
+			{Name: "payslip.xlsx", Content: []byte("small workbook")},
+		},
+	}
+	if err := client.SendMail(context.Background(), m); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	sent := server.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d mails, want 1", len(sent))
+	}
+	got := sent[0]
+
+	if got.Mailbox != client.Mailbox {
+		t.Errorf("mailbox = %q, want %q", got.Mailbox, client.Mailbox)
+	}
+	if got.Subject != m.Subject {
+		t.Errorf("subject = %q, want %q", got.Subject, m.Subject)
+	}
+	if got.Body.ContentType != bodyHTML || got.Body.Content != m.HTMLBody {
+		t.Errorf("body = %+v, want HTML body %q", got.Body, m.HTMLBody)
+	}
+	for _, tt := range []struct {
+		name string
+		got  []graphtest.Recipient
+		want []string
+	}{
+		{"to", got.ToRecipients, m.To},
+		{"cc", got.CcRecipients, m.Cc},
+		{"bcc", got.BccRecipients, m.Bcc},
+	} {
+		if !slices.Equal(addresses(tt.got), tt.want) {
+			t.Errorf("%s recipients = %v, want %v", tt.name, addresses(tt.got), tt.want)
+		}
+	}
+
+	if len(got.Attachments) != 1 {
+		t.Fatalf("attachments = %d, want 1", len(got.Attachments))
+	}
+	attachment := got.Attachments[0]
+	if attachment.Name != "payslip.xlsx" || attachment.ContentType != "application/octet-stream" ||
+		!bytes.Equal(attachment.ContentBytes, m.Attachments[0].Content) {
+		t.Errorf("attachment = %s %s %q, want payslip.xlsx application/octet-stream %q",
+			attachment.Name, attachment.ContentType, attachment.ContentBytes, m.Attachments[0].Content)
+	}
+}
+
+func TestSendMailLargeAttachment(t *testing.T) {
+	client, server := newTestClient(t)
+	requests := &requestLog{}
+	client.HTTPClient.Transport = requests
+
+	// three upload chunks, the last one partial
+	large := bytes.Repeat([]byte("0123456789abcdef"), (2*UploadChunkSize+1024)/16)
+	if len(large) <= LargeAttachmentSize {
+		t.Fatalf("attachment of %d bytes is not large", len(large))
+	}
+
+	m := mail.Mail{
+		Subject:  "Payslips 2025-01",
+		TextBody: "Attached",
+		To:       []string{"dana@example.com"},
+		Attachments: []mail.Attachment{
+			{Name: "notes.txt", ContentType: "text/plain", Content: []byte("small")},
+			{Name: "payslips.zip", ContentType: "application/zip", Content: large},
+		},
+	}
+	if err := client.SendMail(context.Background(), m); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	// the mail is sent as a draft, the large attachment in 3 chunks
+	for _, tt := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodPost, "/sendMail", 0},
+		{http.MethodPost, "/messages", 1},
+		{http.MethodPost, "/attachments", 1},
+		{http.MethodPost, "/attachments/createUploadSession", 1},
+		{http.MethodPut, "", 3},
+		{http.MethodPost, "/send", 1},
+	} {
+		if got := requests.count(tt.method, tt.path); got != tt.want {
+			t.Errorf("%s *%s requests = %d, want %d", tt.method, tt.path, got, tt.want)
+		}
+	}
+
+	sent := server.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d mails, want 1", len(sent))
+	}
+	got := sent[0]
+
+	if got.Body.ContentType != bodyText || got.Body.Content != m.TextBody {
+		t.Errorf("body = %+v, want text body %q", got.Body, m.TextBody)
+	}
+	if len(got.Attachments) != len(m.Attachments) {
+		t.Fatalf("attachments = %d, want %d", len(got.Attachments), len(m.Attachments))
+	}
+	for i, want := range m.Attachments {
+		attachment := got.Attachments[i]
+		if attachment.Name != want.Name || attachment.ContentType != want.ContentType {
+			t.Errorf("attachment %d = %s %s, want %s %s",
+				i, attachment.Name, attachment.ContentType, want.Name, want.ContentType)
+		}
+		if !bytes.Equal(attachment.ContentBytes, want.Content) {
+			t.Errorf("attachment %s has %d bytes, want %d uploaded bytes",
+				want.Name, len(attachment.ContentBytes), len(want.Content))
+		}
+	}
+}
+
+func TestSendMailNoRecipients(t *testing.T) {
+	client, server := newTestClient(t)
+
+	err := client.SendMail(context.Background(), mail.Mail{Subject: "Payslip", TextBody: "Hello"})
+	if err == nil {
+		t.Fatal("SendMail() without recipients succeeded")
+	}
+	if sent := server.Sent(); len(sent) != 0 {
+		t.Errorf("sent %d mails, want 0", len(sent))
+	}
+}
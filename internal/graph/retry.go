@@ -0,0 +1,150 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultMaxConcurrentSends is the number of mails sent at a time, Exchange
+// Online allows 4 concurrent requests per mailbox
+const DefaultMaxConcurrentSends = 4
+
+// RetryPolicy retries the requests failing with a transient error, after
+// the Retry-After of the response or an exponential backoff. Throttled
+// requests, status 429, were not run by Graph and are retried for every
+// method, other transient failures only for idempotent methods.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// MaxRetryAfter is the longest Retry-After waited by the client, the
+	// errors of longer waits are returned with their RetryAfter
+	MaxRetryAfter time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     30 * time.Second,
+		MaxRetryAfter:  30 * time.Second,
+	}
+}
+
+// backoff returns the delay before the retry of a failed attempt, with a
+// jitter of 20%
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(2, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	return time.Duration(backoff - backoff*0.2*rand.Float64())
+}
+
+// send sends the requests made by newRequest until a response is not a
+// transient failure or the retry policy gives up. The last failed response
+// is returned for the caller to decode.
+func (c *Client) send(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		var wait time.Duration
+		var reason string
+		resp, err := c.HTTPClient.Do(req)
+		switch {
+		case err != nil:
+			if ctx.Err() != nil || !idempotent(req.Method) || attempt >= c.Retry.MaxAttempts {
+				return nil, fmt.Errorf("failed to send request: %s %s, error: %w", req.Method, req.URL.Path, err)
+			}
+			wait = c.Retry.backoff(attempt)
+			reason = err.Error()
+		case retryable(resp.StatusCode, req.Method):
+			wait = retryAfter(resp.Header)
+			if wait == 0 {
+				wait = c.Retry.backoff(attempt)
+			}
+			if attempt >= c.Retry.MaxAttempts || wait > c.Retry.MaxRetryAfter {
+				return resp, nil
+			}
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+			resp.Body.Close()
+			reason = resp.Status
+		default:
+			return resp, nil
+		}
+
+		log.Warn().Msgf("graph request: %s %s failed, reason: %s, retrying in: %s, attempt: %d/%d",
+			req.Method, req.URL.Path, reason, wait.Round(time.Millisecond), attempt, c.Retry.MaxAttempts)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("failed to send request: %s %s, error: %w", req.Method, req.URL.Path, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// acquireSend waits for a send slot of the client and returns the func
+// releasing it
+func (c *Client) acquireSend(ctx context.Context) (func(), error) {
+	c.sendsOnce.Do(func() {
+		c.sends = make(chan struct{}, max(c.MaxConcurrentSends, 1))
+	})
+
+	select {
+	case c.sends <- struct{}{}:
+		return func() { <-c.sends }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// retryable reports whether a response status is a transient failure of
+// the method
+func retryable(status int, method string) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusServiceUnavailable, http.StatusGatewayTimeout, http.StatusBadGateway:
+		return idempotent(method)
+	}
+	return false
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns the delay of a Retry-After header, in seconds or an
+// HTTP date, zero without one
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
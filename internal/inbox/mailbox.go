@@ -0,0 +1,107 @@
+// Package inbox ingests the attendance exports emailed by the clock vendor.
+// A poller finds the messages of the configured senders in a mailbox,
+// saves their MM-YYYY.xlsx attachments into the workspace period inputs and
+// enqueues the attendance report build of the period.
+package inbox
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/vgeshiktor/bhops/internal/graph"
+)
+
+const (
+	// DefaultFolder is the mail folder polled for attendance exports
+	DefaultFolder = "inbox"
+
+	// ProcessedCategory is the category of the processed Graph messages
+	ProcessedCategory = "bhops-processed"
+)
+
+// Message is a received message with its attachments metadata
+type Message struct {
+	ID          string
+	From        string
+	Subject     string
+	ReceivedAt  time.Time
+	Processed   bool
+	Attachments []Attachment
+
+	// Labels are the mailbox labels of the message, such as the Graph
+	// categories
+	Labels []string
+}
+
+// Attachment is the metadata of a message attachment
+type Attachment struct {
+	ID   string
+	Name string
+	Size int
+}
+
+// Mailbox is an inbound mailbox, processed messages are flagged in the
+// mailbox so they are ingested once
+type Mailbox interface {
+	// Messages returns the messages with attachments received since the
+	// time
+	Messages(ctx context.Context, since time.Time) ([]Message, error)
+
+	// Attachment returns the content of a message attachment
+	Attachment(ctx context.Context, message Message, attachment Attachment) ([]byte, error)
+
+	// MarkProcessed flags the message as processed
+	MarkProcessed(ctx context.Context, message Message) error
+}
+
+// GraphMailbox is the mail folder of a Graph mailbox, processed messages
+// get the ProcessedCategory and are marked as read
+type GraphMailbox struct {
+	Folder string
+
+	client *graph.Client
+}
+
+func NewGraphMailbox(client *graph.Client) *GraphMailbox {
+	return &GraphMailbox{Folder: DefaultFolder, client: client}
+}
+
+func (m *GraphMailbox) Messages(ctx context.Context, since time.Time) ([]Message, error) {
+	received, err := m.client.ListMessages(ctx, m.Folder, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []Message
+	for _, r := range received {
+		message := Message{
+			ID:         r.ID,
+			From:       r.From,
+			Subject:    r.Subject,
+			ReceivedAt: r.ReceivedAt,
+			Processed:  slices.Contains(r.Categories, ProcessedCategory),
+			Labels:     r.Categories,
+		}
+		for _, a := range r.Attachments {
+			message.Attachments = append(message.Attachments, Attachment{ID: a.ID, Name: a.Name, Size: a.Size})
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+func (m *GraphMailbox) Attachment(ctx context.Context, message Message, attachment Attachment) ([]byte, error) {
+	return m.client.GetAttachment(ctx, message.ID, attachment.ID)
+}
+
+// MarkProcessed adds the ProcessedCategory to the categories of the
+// message
+func (m *GraphMailbox) MarkProcessed(ctx context.Context, message Message) error {
+	categories := append([]string{}, message.Labels...)
+	if !slices.Contains(categories, ProcessedCategory) {
+		categories = append(categories, ProcessedCategory)
+	}
+	return m.client.SetCategories(ctx, message.ID, categories)
+}
@@ -0,0 +1,217 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/jobs"
+	"github.com/vgeshiktor/bhops/internal/queue"
+	"github.com/vgeshiktor/bhops/internal/workspace"
+)
+
+const (
+	// DefaultPollInterval is how often the mailbox is polled
+	DefaultPollInterval = 5 * time.Minute
+
+	// DefaultLookback bounds the received time of the polled messages
+	DefaultLookback = 45 * 24 * time.Hour
+)
+
+// Ingested is an attendance export saved from a message
+type Ingested struct {
+	MessageID string
+	From      string
+	File      string
+	Period    string
+	Archived  string
+	JobID     string
+}
+
+// Poller ingests the attendance exports of the allowed senders. Messages
+// of other senders are left untouched, messages of the allowed senders are
+// marked processed once all their exports are saved and their report
+// builds are enqueued.
+type Poller struct {
+	// Interval is how often the mailbox is polled
+	Interval time.Duration
+
+	// Lookback bounds the received time of the polled messages
+	Lookback time.Duration
+
+	mailbox   Mailbox
+	workspace *workspace.Workspace
+	queue     queue.Queue
+	senders   []string
+}
+
+// NewPoller creates a poller of the mailbox. Senders are email addresses,
+// or domains such as @example.com, matched case insensitively.
+func NewPoller(mailbox Mailbox, ws *workspace.Workspace, q queue.Queue, senders []string) (*Poller, error) {
+	p := &Poller{
+		Interval:  DefaultPollInterval,
+		Lookback:  DefaultLookback,
+		mailbox:   mailbox,
+		workspace: ws,
+		queue:     q,
+	}
+
+	for _, sender := range senders {
+		sender = strings.ToLower(strings.TrimSpace(sender))
+		if sender != "" {
+			p.senders = append(p.senders, sender)
+		}
+	}
+	if len(p.senders) == 0 {
+		return nil, fmt.Errorf("no inbox senders configured")
+	}
+
+	return p, nil
+}
+
+// Run polls the mailbox every Interval until the context is canceled
+func (p *Poller) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.Poll(ctx, time.Now()); err != nil {
+			log.Error().Msgf("failed to poll inbox, error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll ingests the unprocessed messages of the allowed senders. A message
+// failing to be ingested is left unprocessed and retried on the next poll.
+func (p *Poller) Poll(ctx context.Context, now time.Time) ([]Ingested, error) {
+	messages, err := p.mailbox.Messages(ctx, now.Add(-p.Lookback))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inbox messages, error: %w", err)
+	}
+
+	// ingest oldest first, the latest export of a period is kept
+	slices.SortStableFunc(messages, func(a, b Message) int {
+		return a.ReceivedAt.Compare(b.ReceivedAt)
+	})
+
+	var ingested []Ingested
+	var errs []error
+	for _, message := range messages {
+		if message.Processed || !p.allowed(message.From) {
+			continue
+		}
+
+		exports, err := p.ingest(ctx, message)
+		ingested = append(ingested, exports...)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := p.mailbox.MarkProcessed(ctx, message); err != nil {
+			errs = append(errs, fmt.Errorf("failed to mark message: %s as processed, error: %w", message.ID, err))
+		}
+	}
+
+	return ingested, errors.Join(errs...)
+}
+
+// ingest saves the attendance exports of a message and enqueues the report
+// builds of their periods. An export already saved with the same content,
+// such as one of a message polled again, enqueues no build.
+func (p *Poller) ingest(ctx context.Context, message Message) ([]Ingested, error) {
+	var ingested []Ingested
+	exports := 0
+	for _, attachment := range message.Attachments {
+		name, ok := exportPeriod(attachment.Name)
+		if !ok {
+			log.Debug().Msgf("skipping attachment: %s of message: %s, not an attendance export",
+				attachment.Name, message.ID)
+			continue
+		}
+		exports++
+
+		content, err := p.mailbox.Attachment(ctx, message, attachment)
+		if err != nil {
+			return ingested, err
+		}
+
+		period, err := p.workspace.Period(name)
+		if err != nil {
+			return ingested, err
+		}
+		archived, saved, err := period.SaveInput(attachment.Name, content)
+		if err != nil {
+			return ingested, err
+		}
+		inputPath := filepath.Join(period.InputDir(), filepath.Base(attachment.Name))
+		if !saved {
+			log.Info().Msgf("Skipping attendance export: %s from: %s for period: %s, already ingested",
+				attachment.Name, message.From, name)
+			continue
+		}
+
+		env, err := jobs.NewEnvelope(jobs.TypeBuildReport, jobs.BuildReportPayload{Period: name}, "inbox:"+message.ID)
+		if err == nil {
+			_, err = jobs.Enqueue(ctx, p.queue, env)
+		}
+		if err != nil {
+			// drop the saved export, so the next poll saves it again and
+			// enqueues its build
+			if err := os.Remove(inputPath); err != nil {
+				log.Error().Msgf("failed to remove input: %s, error: %v", inputPath, err)
+			}
+			return ingested, fmt.Errorf("failed to enqueue report build of period: %s, error: %w", name, err)
+		}
+
+		log.Info().Msgf("Ingested attendance export: %s from: %s for period: %s, job: %s",
+			attachment.Name, message.From, name, env.ID)
+
+		ingested = append(ingested, Ingested{
+			MessageID: message.ID,
+			From:      message.From,
+			File:      inputPath,
+			Period:    name,
+			Archived:  archived,
+			JobID:     env.ID,
+		})
+	}
+
+	if exports == 0 {
+		log.Warn().Msgf("no attendance export in message: %q from: %s", message.Subject, message.From)
+	}
+
+	return ingested, nil
+}
+
+// allowed reports whether the address is an allowed sender
+func (p *Poller) allowed(from string) bool {
+	from = strings.ToLower(from)
+	for _, sender := range p.senders {
+		if from == sender || strings.HasPrefix(sender, "@") && strings.HasSuffix(from, sender) {
+			return true
+		}
+	}
+	return false
+}
+
+// exportPeriod returns the period of an attendance export attachment,
+// named MM-YYYY.xlsx
+func exportPeriod(name string) (string, bool) {
+	if !strings.EqualFold(filepath.Ext(name), ".xlsx") || strings.HasPrefix(name, "~$") {
+		return "", false
+	}
+	return workspace.PeriodOfExport(name)
+}
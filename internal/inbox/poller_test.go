@@ -0,0 +1,332 @@
+package inbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/bhops/internal/jobs"
+	"github.com/vgeshiktor/bhops/internal/queue"
+	"github.com/vgeshiktor/bhops/internal/workspace"
+)
+
+var testNow = time.Date(2025, time.February, 3, 9, 0, 0, 0, time.UTC)
+
+// fakeMailbox is a mailbox of fixed messages, the content of an attachment
+// is its id unless it is failing
+type fakeMailbox struct {
+	mu       sync.Mutex
+	messages []Message
+	failing  map[string]bool
+	marked   []string
+}
+
+func (m *fakeMailbox) Messages(ctx context.Context, since time.Time) ([]Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var messages []Message
+	for _, message := range m.messages {
+		if !message.ReceivedAt.Before(since) {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+func (m *fakeMailbox) Attachment(ctx context.Context, message Message, attachment Attachment) ([]byte, error) {
+	if m.failing[attachment.ID] {
+		return nil, fmt.Errorf("failed to get attachment: %s", attachment.ID)
+	}
+	return []byte(attachment.ID), nil
+}
+
+func (m *fakeMailbox) MarkProcessed(ctx context.Context, message Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.marked = append(m.marked, message.ID)
+	return nil
+}
+
+// newTestPoller returns a poller of the mailbox into a temporary workspace
+func newTestPoller(t *testing.T, mailbox Mailbox) (*Poller, *workspace.Workspace, *queue.MemoryQueue) {
+	t.Helper()
+
+	ws := workspace.New(t.TempDir())
+	q := queue.NewMemoryQueue()
+	t.Cleanup(func() { q.Close() })
+
+	p, err := NewPoller(mailbox, ws, q, []string{"clock@vendor.com", " @Example.com "})
+	if err != nil {
+		t.Fatalf("NewPoller() error = %v", err)
+	}
+	return p, ws, q
+}
+
+// queuedJobs returns the jobs in the queue
+func queuedJobs(t *testing.T, q queue.Queue) []jobs.Envelope {
+	t.Helper()
+
+	msgs, err := q.Fetch(context.Background(), 100, time.Minute)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	var envs []jobs.Envelope
+	for _, msg := range msgs {
+		env, err := jobs.DecodeEnvelope(msg.Body)
+		if err != nil {
+			t.Fatalf("DecodeEnvelope() error = %v", err)
+		}
+		envs = append(envs, env)
+	}
+	return envs
+}
+
+func message(id, from string, attachments ...string) Message {
+	m := Message{ID: id, From: from, Subject: "Attendance", ReceivedAt: testNow.Add(-time.Hour)}
+	for _, name := range attachments {
+		m.Attachments = append(m.Attachments, Attachment{ID: id + "/" + name, Name: name})
+	}
+	return m
+}
+
+func TestPoll(t *testing.T) {
+	processed := message("processed", "clock@vendor.com", "01-2025.xlsx")
+	processed.Processed = true
+	old := message("old", "clock@vendor.com", "01-2025.xlsx")
+	old.ReceivedAt = testNow.Add(-DefaultLookback - time.Hour)
+
+	tests := []struct {
+		name        string
+		messages    []Message
+		failing     []string
+		wantPeriods []string
+		wantMarked  []string
+		wantErr     bool
+	}{
+		{
+			name: "allowed senders",
+			messages: []Message{
+				message("vendor", "Clock@Vendor.com", "01-2025.xlsx"),
+				message("domain", "hr@example.com", "12-2024.xlsx"),
+			},
+			wantPeriods: []string{"2025-01", "2024-12"},
+			wantMarked:  []string{"vendor", "domain"},
+		},
+		{
+			name: "other senders",
+			messages: []Message{
+				message("other", "clock@other.com", "01-2025.xlsx"),
+				message("subdomain", "hr@mail.example.com.evil", "01-2025.xlsx"),
+			},
+		},
+		{
+			name:     "processed and old messages",
+			messages: []Message{processed, old},
+		},
+		{
+			name: "export names",
+			messages: []Message{
+				message("names", "clock@vendor.com",
+					"report.pdf", "01-2025.csv", "13-2025.xlsx", "~$01-2025.xlsx", "attendance-01-2025.xlsx", "02-2025.XLSX"),
+			},
+			wantPeriods: []string{"2025-02"},
+			wantMarked:  []string{"names"},
+		},
+		{
+			name:       "no exports",
+			messages:   []Message{message("empty", "clock@vendor.com", "report.pdf")},
+			wantMarked: []string{"empty"},
+		},
+		{
+			name: "ingestion fails",
+			messages: []Message{
+				message("failing", "clock@vendor.com", "01-2025.xlsx", "02-2025.xlsx"),
+				message("next", "clock@vendor.com", "03-2025.xlsx"),
+			},
+			failing:     []string{"failing/02-2025.xlsx"},
+			wantPeriods: []string{"2025-01", "2025-03"},
+			wantMarked:  []string{"next"},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mailbox := &fakeMailbox{messages: tt.messages, failing: map[string]bool{}}
+			for _, id := range tt.failing {
+				mailbox.failing[id] = true
+			}
+			p, ws, q := newTestPoller(t, mailbox)
+
+			ingested, err := p.Poll(context.Background(), testNow)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Poll() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var periods []string
+			for _, in := range ingested {
+				periods = append(periods, in.Period)
+			}
+			if !slices.Equal(periods, tt.wantPeriods) {
+				t.Errorf("ingested periods = %v, want %v", periods, tt.wantPeriods)
+			}
+			if !slices.Equal(mailbox.marked, tt.wantMarked) {
+				t.Errorf("processed messages = %v, want %v", mailbox.marked, tt.wantMarked)
+			}
+
+			// every ingested export is saved and its report build enqueued
+			envs := queuedJobs(t, q)
+			if len(envs) != len(ingested) {
+				t.Fatalf("queued jobs = %d, want %d", len(envs), len(ingested))
+			}
+			for i, in := range ingested {
+				period, err := ws.Period(in.Period)
+				if err != nil {
+					t.Fatalf("Period() error = %v", err)
+				}
+				if dir := filepath.Dir(in.File); dir != period.InputDir() {
+					t.Errorf("export saved to: %s, want %s", dir, period.InputDir())
+				}
+				if _, err := os.Stat(in.File); err != nil {
+					t.Errorf("export not saved, error: %v", err)
+				}
+
+				env := envs[i]
+				var payload jobs.BuildReportPayload
+				if err := env.DecodePayload(&payload); err != nil {
+					t.Fatalf("DecodePayload() error = %v", err)
+				}
+				if env.Type != jobs.TypeBuildReport || payload.Period != in.Period || env.ID != in.JobID ||
+					env.CorrelationID != "inbox:"+in.MessageID {
+					t.Errorf("job = %s %+v id: %s correlation: %s, want %s of period: %s id: %s from message: %s",
+						env.Type, payload, env.ID, env.CorrelationID, jobs.TypeBuildReport, in.Period, in.JobID, in.MessageID)
+				}
+			}
+		})
+	}
+}
+
+func TestPollArchivesOlderExport(t *testing.T) {
+	older := message("older", "clock@vendor.com", "01-2025.xlsx")
+	older.ReceivedAt = testNow.Add(-2 * time.Hour)
+	newer := message("newer", "clock@vendor.com", "01-2025.xlsx")
+
+	// listed newest first, ingested oldest first
+	mailbox := &fakeMailbox{messages: []Message{newer, older}}
+	p, ws, _ := newTestPoller(t, mailbox)
+
+	ingested, err := p.Poll(context.Background(), testNow)
+	if err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	if len(ingested) != 2 {
+		t.Fatalf("ingested %d exports, want 2", len(ingested))
+	}
+	if ingested[0].MessageID != "older" || ingested[0].Archived != "" {
+		t.Errorf("first ingested = %s archived: %q, want older without archive",
+			ingested[0].MessageID, ingested[0].Archived)
+	}
+
+	period, err := ws.Period("2025-01")
+	if err != nil {
+		t.Fatalf("Period() error = %v", err)
+	}
+	archived := ingested[1].Archived
+	if filepath.Dir(archived) != period.ArchiveDir() {
+		t.Fatalf("older export archived to: %q, want %s", archived, period.ArchiveDir())
+	}
+
+	for _, tt := range []struct {
+		path string
+		want string
+	}{
+		{ingested[1].File, "newer/01-2025.xlsx"},
+		{archived, "older/01-2025.xlsx"},
+	} {
+		content, err := os.ReadFile(tt.path)
+		if err != nil || string(content) != tt.want {
+			t.Errorf("content of: %s = %q, error: %v, want %q", tt.path, content, err, tt.want)
+		}
+	}
+}
+
+func TestPollUnchangedExport(t *testing.T) {
+	// the message is listed again, as when marking it processed failed
+	mailbox := &fakeMailbox{messages: []Message{message("vendor", "clock@vendor.com", "01-2025.xlsx")}}
+	p, _, q := newTestPoller(t, mailbox)
+
+	for i, want := range []int{1, 0} {
+		ingested, err := p.Poll(context.Background(), testNow)
+		if err != nil {
+			t.Fatalf("Poll() error = %v", err)
+		}
+		if len(ingested) != want {
+			t.Errorf("poll: %d ingested %d exports, want %d", i+1, len(ingested), want)
+		}
+		if envs := queuedJobs(t, q); len(envs) != want {
+			t.Errorf("poll: %d queued %d jobs, want %d", i+1, len(envs), want)
+		}
+	}
+	if !slices.Equal(mailbox.marked, []string{"vendor", "vendor"}) {
+		t.Errorf("processed messages = %v, want vendor on both polls", mailbox.marked)
+	}
+}
+
+// failingQueue fails the enqueues while failing is set
+type failingQueue struct {
+	queue.Queue
+	failing bool
+}
+
+func (q *failingQueue) Enqueue(ctx context.Context, body []byte) (string, error) {
+	if q.failing {
+		return "", fmt.Errorf("queue is unavailable")
+	}
+	return q.Queue.Enqueue(ctx, body)
+}
+
+func TestPollEnqueueFails(t *testing.T) {
+	mailbox := &fakeMailbox{messages: []Message{message("vendor", "clock@vendor.com", "01-2025.xlsx")}}
+	ws := workspace.New(t.TempDir())
+	memory := queue.NewMemoryQueue()
+	t.Cleanup(func() { memory.Close() })
+	q := &failingQueue{Queue: memory, failing: true}
+	p, err := NewPoller(mailbox, ws, q, []string{"clock@vendor.com"})
+	if err != nil {
+		t.Fatalf("NewPoller() error = %v", err)
+	}
+
+	// the export is dropped, the message is left unprocessed
+	if _, err := p.Poll(context.Background(), testNow); err == nil {
+		t.Fatalf("Poll() error = nil, want enqueue error")
+	}
+	period, err := ws.Period("2025-01")
+	if err != nil {
+		t.Fatalf("Period() error = %v", err)
+	}
+	inputPath := filepath.Join(period.InputDir(), "01-2025.xlsx")
+	if _, err := os.Stat(inputPath); !os.IsNotExist(err) {
+		t.Errorf("export of failed enqueue stat error = %v, want not exist", err)
+	}
+	if len(mailbox.marked) != 0 {
+		t.Errorf("processed messages = %v, want none", mailbox.marked)
+	}
+
+	// the next poll saves the export again and enqueues its build
+	q.failing = false
+	ingested, err := p.Poll(context.Background(), testNow)
+	if err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	if envs := queuedJobs(t, memory); len(ingested) != 1 || len(envs) != 1 || envs[0].ID != ingested[0].JobID {
+		t.Errorf("ingested %d exports, queued %d jobs, want the build of the export", len(ingested), len(envs))
+	}
+}
@@ -0,0 +1,103 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/vgeshiktor/bhops/internal/queue"
+)
+
+// Envelope is a typed job message, the payload is decoded by the handler
+// registered for the job type. Effectively-once jobs run once per
+// idempotency key, or per job id without one.
+type Envelope struct {
+	Type           string          `json:"type"`
+	ID             string          `json:"id"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	CorrelationID  string          `json:"correlation_id,omitempty"`
+	IdempotencyKey string          `json:"idempotency_key,omitempty"`
+}
+
+// NewEnvelope creates a job of the type with the JSON encoded payload
+func NewEnvelope(jobType string, payload any, correlationID string) (Envelope, error) {
+	id, err := newID()
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	env := Envelope{
+		Type:          jobType,
+		ID:            id,
+		CreatedAt:     time.Now().UTC(),
+		CorrelationID: correlationID,
+	}
+
+	if payload != nil {
+		content, err := json.Marshal(payload)
+		if err != nil {
+			return env, fmt.Errorf("failed to marshal payload of job: %s, error: %w", jobType, err)
+		}
+		env.Payload = content
+	}
+
+	return env, nil
+}
+
+// Encode returns the JSON encoding of the envelope
+func (e Envelope) Encode() ([]byte, error) {
+	content, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job: %s, error: %w", e.ID, err)
+	}
+	return content, nil
+}
+
+// Key returns the idempotency key of the job
+func (e Envelope) Key() string {
+	if e.IdempotencyKey != "" {
+		return e.IdempotencyKey
+	}
+	return e.Type + ":" + e.ID
+}
+
+// DecodeEnvelope decodes a JSON encoded envelope
+func DecodeEnvelope(content []byte) (Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(content, &env); err != nil {
+		return env, fmt.Errorf("failed to unmarshal job, error: %w", err)
+	}
+	if env.Type == "" {
+		return env, fmt.Errorf("job: %s has no type", env.ID)
+	}
+	return env, nil
+}
+
+// DecodePayload decodes the job payload into v
+func (e Envelope) DecodePayload(v any) error {
+	if err := json.Unmarshal(e.Payload, v); err != nil {
+		return fmt.Errorf("failed to unmarshal payload of job: %s, error: %w", e.ID, err)
+	}
+	return nil
+}
+
+// Enqueue adds the job to the queue and returns the queue message id
+func Enqueue(ctx context.Context, q queue.Queue, env Envelope) (string, error) {
+	content, err := env.Encode()
+	if err != nil {
+		return "", err
+	}
+	return q.Enqueue(ctx, content)
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to create job id, error: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
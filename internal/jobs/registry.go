@@ -0,0 +1,139 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/attendanceops"
+)
+
+// DefaultTimeout is the timeout of handlers registered without one
+const DefaultTimeout = 5 * time.Minute
+
+// Delivery is the delivery semantics of a job type
+type Delivery int
+
+const (
+	// AtLeastOnce jobs run on every delivery, handlers must tolerate
+	// running again after a redelivery
+	AtLeastOnce Delivery = iota
+
+	// EffectivelyOnce jobs are skipped when a job with the same idempotency
+	// key already completed
+	EffectivelyOnce
+)
+
+// Handler runs a job
+type Handler func(ctx context.Context, env Envelope) error
+
+// HandlerOptions configures the runs of a job type, each run is canceled
+// after the timeout
+type HandlerOptions struct {
+	Timeout  time.Duration
+	Delivery Delivery
+}
+
+type registration struct {
+	handler Handler
+	options HandlerOptions
+}
+
+// Registry routes jobs to the handler registered for their type
+type Registry struct {
+	mu       sync.RWMutex
+	handlers map[string]registration
+}
+
+func NewRegistry() *Registry {
+	return &Registry{handlers: map[string]registration{}}
+}
+
+// Register sets the handler of a job type
+func (r *Registry) Register(jobType string, handler Handler, options HandlerOptions) {
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultTimeout
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers[jobType] = registration{handler: handler, options: options}
+}
+
+// Types returns the registered job types
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]string, 0, len(r.handlers))
+	for jobType := range r.handlers {
+		types = append(types, jobType)
+	}
+	sort.Strings(types)
+
+	return types
+}
+
+// Handle decodes the job and runs its handler. Jobs that cannot be decoded
+// or have no handler fail with a permanent error.
+func (r *Registry) Handle(ctx context.Context, content []byte) error {
+	env, err := DecodeEnvelope(content)
+	if err != nil {
+		return attendanceops.Permanent(err)
+	}
+
+	r.mu.RLock()
+	reg, ok := r.handlers[env.Type]
+	r.mu.RUnlock()
+	if !ok {
+		return attendanceops.Permanent(fmt.Errorf("no handler for job: %s of type: %s", env.ID, env.Type))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, reg.options.Timeout)
+	defer cancel()
+
+	logger := log.With().
+		Str("job_id", env.ID).
+		Str("job_type", env.Type).
+		Str("correlation_id", env.CorrelationID).
+		Logger()
+	logger.Info().Msg("Running job")
+
+	started := time.Now()
+	if err := reg.handler(logger.WithContext(ctx), env); err != nil {
+		return fmt.Errorf("job: %s of type: %s failed, error: %w", env.ID, env.Type, err)
+	}
+
+	logger.Info().Msgf("Job done in: %s", time.Since(started).Round(time.Millisecond))
+
+	return nil
+}
+
+// MsgHandler returns the processor handler running the jobs of the queue
+func (r *Registry) MsgHandler() attendanceops.MsgHandler {
+	return func(ctx context.Context, m attendanceops.Msg) error {
+		return r.Handle(ctx, []byte(m.Message()))
+	}
+}
+
+// Idempotency returns the idempotency key of effectively-once jobs, for
+// the processor Idempotency
+func (r *Registry) Idempotency(m attendanceops.Msg) (string, bool) {
+	env, err := DecodeEnvelope([]byte(m.Message()))
+	if err != nil {
+		return "", false
+	}
+
+	r.mu.RLock()
+	reg, ok := r.handlers[env.Type]
+	r.mu.RUnlock()
+	if !ok || reg.options.Delivery != EffectivelyOnce {
+		return "", false
+	}
+
+	return env.Key(), true
+}
@@ -0,0 +1,119 @@
+package jobs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Job types
+const (
+	TypeBuildReport      = "build_attendance_report"
+	TypeGeneratePayslips = "generate_payslips"
+	TypeSendPayslip      = "send_payslip"
+	TypeSendPayslips     = "send_payslips"
+)
+
+// payloads create the payload of every job type
+var payloads = map[string]func() payload{
+	TypeBuildReport:      func() payload { return &BuildReportPayload{} },
+	TypeGeneratePayslips: func() payload { return &GeneratePayslipsPayload{} },
+	TypeSendPayslip:      func() payload { return &SendPayslipPayload{} },
+	TypeSendPayslips:     func() payload { return &SendPayslipsPayload{} },
+}
+
+// payload is a job payload checking its required fields
+type payload interface {
+	validate() error
+}
+
+// Types returns the known job types
+func Types() []string {
+	types := make([]string, 0, len(payloads))
+	for jobType := range payloads {
+		types = append(types, jobType)
+	}
+	sort.Strings(types)
+
+	return types
+}
+
+// ValidatePayload checks the job type is known and the JSON payload
+// decodes into the payload of the type, without unknown fields and with
+// its required fields set
+func ValidatePayload(jobType string, content json.RawMessage) error {
+	newPayload, ok := payloads[jobType]
+	if !ok {
+		return fmt.Errorf("unknown job type: %s, expected one of: %v", jobType, Types())
+	}
+	if len(content) == 0 {
+		return fmt.Errorf("missing payload of job type: %s", jobType)
+	}
+
+	p := newPayload()
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return fmt.Errorf("invalid payload of job type: %s, error: %w", jobType, err)
+	}
+	if dec.More() {
+		return fmt.Errorf("invalid payload of job type: %s, error: unexpected data after payload", jobType)
+	}
+	if err := p.validate(); err != nil {
+		return fmt.Errorf("invalid payload of job type: %s, error: %w", jobType, err)
+	}
+
+	return nil
+}
+
+// BuildReportPayload builds the attendance report of a workspace period
+type BuildReportPayload struct {
+	Period string `json:"period"`
+}
+
+// GeneratePayslipsPayload computes the payroll of a workspace period from
+// its latest attendance report and saves the payslips
+type GeneratePayslipsPayload struct {
+	Period string `json:"period"`
+}
+
+// SendPayslipPayload publishes the payslip of a worker on a channel
+type SendPayslipPayload struct {
+	Period   string `json:"period"`
+	WorkerID string `json:"worker_id"`
+	Channel  string `json:"channel"`
+}
+
+// SendPayslipsPayload enqueues a send payslip job for every worker in the
+// payroll of a workspace period
+type SendPayslipsPayload struct {
+	Period  string `json:"period"`
+	Channel string `json:"channel"`
+}
+
+func (p *BuildReportPayload) validate() error {
+	return requirePeriod(p.Period)
+}
+
+func (p *GeneratePayslipsPayload) validate() error {
+	return requirePeriod(p.Period)
+}
+
+func (p *SendPayslipPayload) validate() error {
+	if p.WorkerID == "" {
+		return fmt.Errorf("missing field: worker_id")
+	}
+	return requirePeriod(p.Period)
+}
+
+func (p *SendPayslipsPayload) validate() error {
+	return requirePeriod(p.Period)
+}
+
+func requirePeriod(period string) error {
+	if period == "" {
+		return fmt.Errorf("missing field: period")
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Package mail holds the mail messages sent by the mail backends, such as
+// Microsoft Graph and SMTP
+package mail
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+)
+
+// Mail is a mail message, a mail has a text body, an HTML body or both.
+// Backends sending a single body send the HTML body when there is one.
+type Mail struct {
+	Subject     string
+	TextBody    string
+	HTMLBody    string
+	To          []string
+	Cc          []string
+	Bcc         []string
+	Attachments []Attachment
+}
+
+// Recipients returns the To, Cc and Bcc addresses of the mail
+func (m Mail) Recipients() []string {
+	recipients := append([]string{}, m.To...)
+	recipients = append(recipients, m.Cc...)
+	return append(recipients, m.Bcc...)
+}
+
+// Attachment is a file attached to a mail
+type Attachment struct {
+	Name        string
+	ContentType string
+	Content     []byte
+}
+
+// AttachFile reads a file attachment, the content type is taken from the
+// file extension
+func AttachFile(path string) (Attachment, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to read attachment: %s, error: %w", path, err)
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return Attachment{Name: filepath.Base(path), ContentType: contentType, Content: content}, nil
+}
+
+// MediaType returns the content type of the attachment, octet-stream when
+// it is not set
+func (a Attachment) MediaType() string {
+	if a.ContentType == "" {
+		return "application/octet-stream"
+	}
+	return a.ContentType
+}
@@ -0,0 +1,242 @@
+// Package smtp sends mails through an SMTP server, as an alternative to
+// Microsoft Graph for mailboxes outside Microsoft 365
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	netsmtp "net/smtp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/vgeshiktor/bhops/internal/mail"
+	"github.com/vgeshiktor/bhops/internal/redact"
+)
+
+// Connection security of the SMTP server
+const (
+	// SecurityStartTLS upgrades a plain connection with STARTTLS, the
+	// server must support it
+	SecurityStartTLS = "starttls"
+
+	// SecurityTLS connects with TLS, as on port 465
+	SecurityTLS = "tls"
+
+	// SecurityNone sends in plain text, for local relays only
+	SecurityNone = "none"
+)
+
+const DefaultTimeout = 60 * time.Second
+
+// defaultPorts are the server ports of the securities, used when the
+// server address has no port
+var defaultPorts = map[string]string{
+	SecurityStartTLS: "587",
+	SecurityTLS:      "465",
+	SecurityNone:     "25",
+}
+
+// Config configures the SMTP server and the sender of the mails
+type Config struct {
+	// Addr is the server host:port, the port defaults to the port of the
+	// security
+	Addr     string
+	Security string
+	Username string
+	Password string
+
+	// From is the sender address, such as "Payroll <payroll@example.com>"
+	From string
+}
+
+// Client sends mails through an SMTP server, a connection is opened for
+// each mail. Mails are authenticated with PLAIN auth when a username is
+// set, which requires TLS unless the server is local.
+type Client struct {
+	// TLSConfig is the TLS config of the server connections, the server
+	// certificate is verified against the system roots when it is nil
+	TLSConfig *tls.Config
+	Timeout   time.Duration
+
+	host     string
+	addr     string
+	security string
+	username string
+	password string
+	from     *netmail.Address
+}
+
+// NewClient validates the config and creates a client
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.Security == "" {
+		cfg.Security = SecurityStartTLS
+	}
+	port, ok := defaultPorts[cfg.Security]
+	if !ok {
+		return nil, fmt.Errorf("invalid smtp security: %s, expected: %s, %s or %s",
+			cfg.Security, SecurityStartTLS, SecurityTLS, SecurityNone)
+	}
+
+	if cfg.Addr == "" {
+		return nil, fmt.Errorf("an smtp server address must be set")
+	}
+	host, _, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		host = cfg.Addr
+		cfg.Addr = net.JoinHostPort(cfg.Addr, port)
+	}
+
+	if cfg.From == "" {
+		cfg.From = cfg.Username
+	}
+	from, err := netmail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtp sender: %q, error: %w", cfg.From, err)
+	}
+
+	redact.Secret(cfg.Password)
+
+	return &Client{
+		Timeout:  DefaultTimeout,
+		host:     host,
+		addr:     cfg.Addr,
+		security: cfg.Security,
+		username: cfg.Username,
+		password: cfg.Password,
+		from:     from,
+	}, nil
+}
+
+// SendMail sends the mail to its To, Cc and Bcc recipients
+func (c *Client) SendMail(ctx context.Context, m mail.Mail) error {
+	recipients := m.Recipients()
+	if len(recipients) == 0 {
+		return fmt.Errorf("mail: %q has no recipients", m.Subject)
+	}
+
+	content, err := buildMessage(c.from, m, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to build mail: %q, error: %w", m.Subject, err)
+	}
+
+	client, closeConn, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+
+	if err := client.Mail(c.from.Address); err != nil {
+		return fmt.Errorf("failed to send mail: %q, sender: %s, error: %w", m.Subject, c.from.Address, replyError(err))
+	}
+	for _, recipient := range recipients {
+		address, err := netmail.ParseAddress(recipient)
+		if err != nil {
+			return fmt.Errorf("invalid recipient: %s, error: %w", recipient, err)
+		}
+		if err := client.Rcpt(address.Address); err != nil {
+			return fmt.Errorf("failed to send mail: %q, recipient: %s, error: %w", m.Subject, address.Address, replyError(err))
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send mail: %q, error: %w", m.Subject, replyError(err))
+	}
+	if _, err := w.Write(content); err != nil {
+		return fmt.Errorf("failed to write mail: %q, error: %w", m.Subject, err)
+	}
+	// the server accepts or rejects the mail on close
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send mail: %q, error: %w", m.Subject, replyError(err))
+	}
+
+	return client.Quit()
+}
+
+// connect opens an authenticated connection to the server and returns the
+// func closing it, the connection is also closed when the context is done
+func (c *Client) connect(ctx context.Context) (*netsmtp.Client, func(), error) {
+	tlsConfig := c.tlsConfig()
+	dialer := &net.Dialer{Timeout: c.Timeout}
+
+	var conn net.Conn
+	var err error
+	if c.security == SecurityTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", c.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to smtp server: %s, error: %w", c.addr, err)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.Timeout)
+	}
+	_ = conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+
+	client, err := netsmtp.NewClient(conn, c.host)
+	if err != nil {
+		stop()
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to connect to smtp server: %s, error: %w", c.addr, err)
+	}
+
+	closeConn := func() {
+		stop()
+		client.Close()
+	}
+	if err := c.handshake(client, tlsConfig); err != nil {
+		closeConn()
+		return nil, nil, err
+	}
+
+	return client, closeConn, nil
+}
+
+// handshake upgrades the connection to TLS and authenticates
+func (c *Client) handshake(client *netsmtp.Client, tlsConfig *tls.Config) error {
+	if err := client.Hello("localhost"); err != nil {
+		return fmt.Errorf("failed to greet smtp server: %s, error: %w", c.addr, replyError(err))
+	}
+
+	if c.security == SecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server: %s does not support STARTTLS", c.addr)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("failed to start TLS with smtp server: %s, error: %w", c.addr, err)
+		}
+	}
+
+	if c.username == "" {
+		return nil
+	}
+	ok, mechanisms := client.Extension("AUTH")
+	if !ok || !slices.Contains(strings.Fields(mechanisms), "PLAIN") {
+		return fmt.Errorf("smtp server: %s does not support PLAIN auth", c.addr)
+	}
+	if err := client.Auth(netsmtp.PlainAuth("", c.username, c.password, c.host)); err != nil {
+		return fmt.Errorf("failed to authenticate to smtp server: %s, username: %s, error: %w", c.addr, c.username, replyError(err))
+	}
+
+	return nil
+}
+
+func (c *Client) tlsConfig() *tls.Config {
+	if c.TLSConfig == nil {
+		return &tls.Config{ServerName: c.host, MinVersion: tls.VersionTLS12}
+	}
+
+	tlsConfig := c.TLSConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = c.host
+	}
+	return tlsConfig
+}
@@ -0,0 +1,268 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vgeshiktor/bhops/internal/mail"
+	"github.com/vgeshiktor/bhops/internal/mail/smtp/smtptest"
+)
+
+const (
+	testUsername = "payroll@example.com"
+	testPassword = "smtp-test-password"
+)
+
+// newTestServer starts a fake server of the security requiring the test
+// username and a client of the server
+func newTestServer(t *testing.T, security, password string) (*Client, *smtptest.Server) {
+	t.Helper()
+
+	newServer := smtptest.NewServer
+	if security == SecurityTLS {
+		newServer = smtptest.NewTLSServer
+	}
+	server, err := newServer(testUsername, testPassword)
+	if err != nil {
+		t.Fatalf("failed to start smtp server, error: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	client, err := NewClient(Config{
+		Addr:     server.Addr,
+		Security: security,
+		Username: testUsername,
+		Password: password,
+		From:     "Payroll <" + testUsername + ">",
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	client.TLSConfig = server.ClientTLSConfig()
+
+	return client, server
+}
+
+func TestSendMail(t *testing.T) {
+	for _, security := range []string{SecurityStartTLS, SecurityTLS} {
+		t.Run(security, func(t *testing.T) {
+			client, server := newTestServer(t, security, testPassword)
+
+			m := mail.Mail{
+				Subject:  "Payslip 2025-01",
+				TextBody: "Hello",
+				To:       []string{"Dana <dana@example.com>", "avi@example.com"},
+				Cc:       []string{"office@example.com"},
+				Bcc:      []string{"archive@example.com"},
+			}
+			if err := client.SendMail(context.Background(), m); err != nil {
+				t.Fatalf("SendMail() error = %v", err)
+			}
+
+			messages := server.Messages()
+			if len(messages) != 1 {
+				t.Fatalf("received %d mails, want 1", len(messages))
+			}
+			got := messages[0]
+
+			if !got.TLS {
+				t.Error("mail was not sent over TLS")
+			}
+			if got.Username != testUsername {
+				t.Errorf("authenticated as: %q, want %q", got.Username, testUsername)
+			}
+			if got.From != testUsername {
+				t.Errorf("envelope sender = %q, want %q", got.From, testUsername)
+			}
+			wantTo := []string{"dana@example.com", "avi@example.com", "office@example.com", "archive@example.com"}
+			if !slices.Equal(got.To, wantTo) {
+				t.Errorf("envelope recipients = %v, want %v", got.To, wantTo)
+			}
+
+			parsed, err := got.Parse()
+			if err != nil {
+				t.Fatalf("failed to parse mail, error: %v", err)
+			}
+			if bcc := parsed.Header.Get("Bcc"); bcc != "" {
+				t.Errorf("Bcc header = %q, want none", bcc)
+			}
+			if to := parsed.Header.Get("To"); to != `"Dana" <dana@example.com>, <avi@example.com>` {
+				t.Errorf("To header = %q", to)
+			}
+		})
+	}
+}
+
+func TestSendMailErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		password      string
+		fail          []int
+		wantCode      int
+		wantTemporary bool
+	}{
+		{
+			name:     "wrong password",
+			password: "wrong-password",
+			wantCode: 535,
+		},
+		{
+			name:          "temporary failure",
+			password:      testPassword,
+			fail:          []int{451},
+			wantCode:      451,
+			wantTemporary: true,
+		},
+		{
+			name:     "rejected mail",
+			password: testPassword,
+			fail:     []int{550},
+			wantCode: 550,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := newTestServer(t, SecurityStartTLS, tt.password)
+			server.FailNext(tt.fail...)
+
+			err := client.SendMail(context.Background(), mail.Mail{
+				Subject:  "Payslip",
+				TextBody: "Hello",
+				To:       []string{"dana@example.com"},
+			})
+
+			var smtpErr *Error
+			if !errors.As(err, &smtpErr) {
+				t.Fatalf("SendMail() error = %v, want *Error", err)
+			}
+			if smtpErr.Code != tt.wantCode || smtpErr.Temporary() != tt.wantTemporary {
+				t.Errorf("error code: %d temporary: %v, want code: %d temporary: %v",
+					smtpErr.Code, smtpErr.Temporary(), tt.wantCode, tt.wantTemporary)
+			}
+			if strings.Contains(err.Error(), testPassword) {
+				t.Errorf("error %q contains the password", err.Error())
+			}
+			if messages := server.Messages(); len(messages) != 0 {
+				t.Errorf("received %d mails, want 0", len(messages))
+			}
+		})
+	}
+}
+
+func TestSendMailMultipart(t *testing.T) {
+	client, server := newTestServer(t, SecurityStartTLS, testPassword)
+
+	// longer than a base64 line
+	content := bytes.Repeat([]byte{0x50, 0x4b, 0x03, 0x04, 0xff}, 40)
+	subject := "תלוש שכר לתקופה 2025-01"
+	m := mail.Mail{
+		Subject:  subject,
+		TextBody: "שלום דנה",
+		HTMLBody: "<p>שלום דנה</p>",
+		To:       []string{"dana@example.com"},
+		Attachments: []mail.Attachment{
+			{Name: "תלוש-2025-01.xlsx", ContentType: "application/vnd.ms-excel", Content: content},
+		},
+	}
+	if err := client.SendMail(context.Background(), m); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("received %d mails, want 1", len(messages))
+	}
+	parsed, err := messages[0].Parse()
+	if err != nil {
+		t.Fatalf("failed to parse mail, error: %v", err)
+	}
+
+	// the subject is sent encoded and decodes to the original
+	decoder := &mime.WordDecoder{}
+	rawSubject := parsed.Header.Get("Subject")
+	if rawSubject == subject || !strings.HasPrefix(rawSubject, "=?UTF-8?b?") {
+		t.Errorf("Subject header = %q, want an encoded word", rawSubject)
+	}
+	decoded, err := decoder.DecodeHeader(rawSubject)
+	if err != nil || decoded != subject {
+		t.Errorf("decoded subject = %q, error: %v, want %q", decoded, err, subject)
+	}
+
+	// mixed parts are the alternative bodies and the attachment
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %q, error: %v, want multipart/mixed", parsed.Header.Get("Content-Type"), err)
+	}
+	parts := readParts(t, parsed.Body, params["boundary"])
+	if len(parts) != 2 {
+		t.Fatalf("mixed parts = %d, want 2", len(parts))
+	}
+
+	bodyType, bodyParams, _ := mime.ParseMediaType(parts[0].header.Get("Content-Type"))
+	if bodyType != "multipart/alternative" {
+		t.Fatalf("body Content-Type = %q, want multipart/alternative", bodyType)
+	}
+	bodies := readParts(t, bytes.NewReader(parts[0].content), bodyParams["boundary"])
+	for i, want := range []struct{ contentType, text string }{
+		{"text/plain", m.TextBody},
+		{"text/html", m.HTMLBody},
+	} {
+		if i >= len(bodies) {
+			t.Fatalf("alternative parts = %d, want 2", len(bodies))
+		}
+		contentType, _, _ := mime.ParseMediaType(bodies[i].header.Get("Content-Type"))
+		text, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(bodies[i].content)))
+		if err != nil || contentType != want.contentType || string(text) != want.text {
+			t.Errorf("alternative part %d = %s %q, error: %v, want %s %q",
+				i, contentType, text, err, want.contentType, want.text)
+		}
+	}
+
+	attachment := parts[1]
+	_, disposition, err := mime.ParseMediaType(attachment.header.Get("Content-Disposition"))
+	if err != nil || disposition["filename"] != m.Attachments[0].Name {
+		t.Errorf("attachment file name = %q, error: %v, want %q",
+			disposition["filename"], err, m.Attachments[0].Name)
+	}
+	if encoding := attachment.header.Get("Content-Transfer-Encoding"); encoding != "base64" {
+		t.Errorf("attachment encoding = %q, want base64", encoding)
+	}
+	decodedContent, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding,
+		strings.NewReader(strings.ReplaceAll(string(attachment.content), "\r\n", ""))))
+	if err != nil || !bytes.Equal(decodedContent, content) {
+		t.Errorf("attachment content = %x, error: %v, want %x", decodedContent, err, content)
+	}
+}
+
+// readParts reads the raw parts of a multipart body, without decoding
+// their transfer encoding
+func readParts(t *testing.T, body io.Reader, boundary string) []part {
+	t.Helper()
+
+	var parts []part
+	reader := multipart.NewReader(body, boundary)
+	for {
+		p, err := reader.NextRawPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatalf("failed to read part, error: %v", err)
+		}
+		content, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatalf("failed to read part content, error: %v", err)
+		}
+		parts = append(parts, part{header: p.Header, content: content})
+	}
+}
@@ -0,0 +1,33 @@
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+)
+
+// Error is a reply of the SMTP server rejecting a command, 4xx replies are
+// temporary failures and 5xx replies permanent ones
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("smtp server replied: %d %s", e.Code, e.Message)
+}
+
+// Temporary reports whether the command may succeed when sent again later
+func (e *Error) Temporary() bool {
+	return e.Code >= 400 && e.Code < 500
+}
+
+// replyError returns the server reply of an error as an *Error, other
+// errors, such as network errors, are returned as is
+func replyError(err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return &Error{Code: protoErr.Code, Message: protoErr.Msg}
+	}
+	return err
+}
@@ -0,0 +1,170 @@
+package smtp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/vgeshiktor/bhops/internal/mail"
+)
+
+// base64LineLength is the line length of base64 encoded attachments
+const base64LineLength = 76
+
+// part is a MIME part, its content is already transfer encoded
+type part struct {
+	header  textproto.MIMEHeader
+	content []byte
+}
+
+// buildMessage builds the MIME message of a mail. The text and HTML bodies
+// are sent as multipart/alternative parts and the attachments as
+// multipart/mixed parts, non-ASCII headers are encoded as UTF-8.
+func buildMessage(from *netmail.Address, m mail.Mail, date time.Time) ([]byte, error) {
+	to, err := formatAddresses(m.To)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := formatAddresses(m.Cc)
+	if err != nil {
+		return nil, err
+	}
+
+	body := newBodyPart(m)
+	if len(m.Attachments) > 0 {
+		parts := []part{body}
+		for _, a := range m.Attachments {
+			parts = append(parts, newAttachmentPart(a))
+		}
+		body = newMultipart("mixed", parts...)
+	}
+
+	// bcc recipients are only in the envelope
+	var buf bytes.Buffer
+	writeHeader(&buf, "From", from.String())
+	if to != "" {
+		writeHeader(&buf, "To", to)
+	}
+	if cc != "" {
+		writeHeader(&buf, "Cc", cc)
+	}
+	writeHeader(&buf, "Subject", mime.BEncoding.Encode("UTF-8", m.Subject))
+	writeHeader(&buf, "Date", date.Format(time.RFC1123Z))
+	writeHeader(&buf, "Message-ID", messageID(from, date))
+	writeHeader(&buf, "MIME-Version", "1.0")
+	writeHeader(&buf, "Content-Type", body.header.Get("Content-Type"))
+	if encoding := body.header.Get("Content-Transfer-Encoding"); encoding != "" {
+		writeHeader(&buf, "Content-Transfer-Encoding", encoding)
+	}
+	buf.WriteString("\r\n")
+	buf.Write(body.content)
+
+	return buf.Bytes(), nil
+}
+
+// newBodyPart returns the body of the mail, an alternative of the text and
+// HTML bodies when it has both
+func newBodyPart(m mail.Mail) part {
+	switch {
+	case m.HTMLBody == "":
+		return newTextPart("text/plain", m.TextBody)
+	case m.TextBody == "":
+		return newTextPart("text/html", m.HTMLBody)
+	default:
+		return newMultipart("alternative", newTextPart("text/plain", m.TextBody), newTextPart("text/html", m.HTMLBody))
+	}
+}
+
+func newTextPart(contentType, text string) part {
+	var buf bytes.Buffer
+	w := quotedprintable.NewWriter(&buf)
+	// writes to a buffer do not fail
+	_, _ = w.Write([]byte(text))
+	_ = w.Close()
+
+	return part{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {contentType + "; charset=UTF-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		content: buf.Bytes(),
+	}
+}
+
+// newAttachmentPart returns the base64 encoded part of an attachment, a
+// non-ASCII file name is encoded as in RFC 2231
+func newAttachmentPart(a mail.Attachment) part {
+	encoded := base64.StdEncoding.EncodeToString(a.Content)
+
+	var buf bytes.Buffer
+	for len(encoded) > base64LineLength {
+		buf.WriteString(encoded[:base64LineLength] + "\r\n")
+		encoded = encoded[base64LineLength:]
+	}
+	buf.WriteString(encoded + "\r\n")
+
+	return part{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(a.MediaType(), map[string]string{"name": a.Name})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})},
+			"Content-Transfer-Encoding": {"base64"},
+		},
+		content: buf.Bytes(),
+	}
+}
+
+func newMultipart(subtype string, parts ...part) part {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	// writes to a buffer do not fail
+	for _, p := range parts {
+		partWriter, _ := w.CreatePart(p.header)
+		_, _ = partWriter.Write(p.content)
+	}
+	_ = w.Close()
+
+	return part{
+		header:  textproto.MIMEHeader{"Content-Type": {"multipart/" + subtype + "; boundary=" + w.Boundary()}},
+		content: buf.Bytes(),
+	}
+}
+
+func writeHeader(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key + ": " + value + "\r\n")
+}
+
+// formatAddresses validates the addresses and formats them as a header
+// value
+func formatAddresses(addresses []string) (string, error) {
+	var formatted []string
+	for _, address := range addresses {
+		parsed, err := netmail.ParseAddress(address)
+		if err != nil {
+			return "", fmt.Errorf("invalid address: %s, error: %w", address, err)
+		}
+		formatted = append(formatted, parsed.String())
+	}
+	return strings.Join(formatted, ", "), nil
+}
+
+// messageID returns a unique message id in the domain of the sender
+func messageID(from *netmail.Address, date time.Time) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from.Address, "@"); i >= 0 {
+		domain = from.Address[i+1:]
+	}
+
+	random := make([]byte, 8)
+	_, _ = rand.Read(random)
+
+	return fmt.Sprintf("<%d.%s@%s>", date.UnixNano(), hex.EncodeToString(random), domain)
+}
@@ -0,0 +1,421 @@
+// Package smtptest provides a local fake SMTP server, for running the SMTP
+// mail sender without a mail provider
+package smtptest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a mail received by the fake server
+type Message struct {
+	From string
+	To   []string
+	Data []byte
+
+	// Username is the authenticated user, TLS reports whether the mail was
+	// sent over TLS
+	Username   string
+	TLS        bool
+	ReceivedAt time.Time
+}
+
+// Parse parses the received mail, the header values are as sent, use a
+// mime.WordDecoder to decode encoded subjects
+func (m Message) Parse() (*netmail.Message, error) {
+	return netmail.ReadMessage(strings.NewReader(string(m.Data)))
+}
+
+// Server is a fake SMTP server on a local port. It offers STARTTLS, or
+// accepts TLS connections only when started with NewTLSServer, with a
+// self-signed certificate. Mails must be authenticated with PLAIN auth
+// over TLS when the server has a username.
+type Server struct {
+	// Addr is the host:port of the server
+	Addr string
+
+	Username string
+	Password string
+
+	implicitTLS bool
+	tlsConfig   *tls.Config
+	roots       *x509.CertPool
+	listener    net.Listener
+
+	mu       sync.Mutex
+	nextID   int
+	messages []Message
+	fail     []int
+	conns    map[net.Conn]bool
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a fake server offering STARTTLS, the username is
+// required when it is set
+func NewServer(username, password string) (*Server, error) {
+	return newServer(username, password, false)
+}
+
+// NewTLSServer starts a fake server accepting TLS connections
+func NewTLSServer(username, password string) (*Server, error) {
+	return newServer(username, password, true)
+}
+
+func newServer(username, password string, implicitTLS bool) (*Server, error) {
+	cert, roots, err := selfSignedCertificate()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		Username:    username,
+		Password:    password,
+		implicitTLS: implicitTLS,
+		tlsConfig:   &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12},
+		roots:       roots,
+		conns:       map[net.Conn]bool{},
+	}
+
+	s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen, error: %w", err)
+	}
+	if implicitTLS {
+		s.listener = tls.NewListener(s.listener, s.tlsConfig)
+	}
+	s.Addr = s.listener.Addr().String()
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// ClientTLSConfig returns a TLS config trusting the server certificate
+func (s *Server) ClientTLSConfig() *tls.Config {
+	return &tls.Config{RootCAs: s.roots, MinVersion: tls.VersionTLS12}
+}
+
+// Close stops the server, closes its connections and waits for them
+func (s *Server) Close() error {
+	err := s.listener.Close()
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// Messages returns the mails received by the server
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
+
+// FailNext makes the next mails fail with the reply codes, in order, such
+// as 451 for a temporary failure or 550 for a rejected mail
+func (s *Server) FailNext(codes ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fail = append(s.fail, codes...)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+
+			_ = conn.SetDeadline(time.Now().Add(time.Minute))
+			newSession(s, conn).run()
+		}()
+	}
+}
+
+// session is the SMTP conversation of a connection
+type session struct {
+	server *Server
+	conn   net.Conn
+	text   *textproto.Conn
+	tls    bool
+
+	greeted  bool
+	username string
+	from     string
+	to       []string
+}
+
+func newSession(s *Server, conn net.Conn) *session {
+	return &session{server: s, conn: conn, text: textproto.NewConn(conn), tls: s.implicitTLS}
+}
+
+func (c *session) run() {
+	c.reply(220, "smtptest ESMTP ready")
+
+	for {
+		line, err := c.text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			c.greeted = true
+			c.reset()
+			c.hello()
+		case "STARTTLS":
+			if c.tls || c.server.implicitTLS {
+				c.reply(503, "5.5.1 TLS already active")
+				continue
+			}
+			c.reply(220, "2.0.0 Ready to start TLS")
+			tlsConn := tls.Server(c.conn, c.server.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			// the client greets again over TLS
+			c.conn, c.text, c.tls, c.greeted = tlsConn, textproto.NewConn(tlsConn), true, false
+			c.reset()
+		case "AUTH":
+			c.auth(arg)
+		case "MAIL":
+			c.mail(arg)
+		case "RCPT":
+			c.rcpt(arg)
+		case "DATA":
+			c.data()
+		case "RSET":
+			c.reset()
+			c.reply(250, "2.0.0 OK")
+		case "NOOP":
+			c.reply(250, "2.0.0 OK")
+		case "QUIT":
+			c.reply(221, "2.0.0 Bye")
+			return
+		default:
+			c.reply(502, "5.5.2 Command not recognized")
+		}
+	}
+}
+
+// hello replies to EHLO with the server extensions, AUTH is offered over
+// TLS only
+func (c *session) hello() {
+	lines := []string{"smtptest", "8BITMIME"}
+	if !c.tls {
+		lines = append(lines, "STARTTLS")
+	}
+	if c.tls && c.server.Username != "" {
+		lines = append(lines, "AUTH PLAIN")
+	}
+
+	for i, line := range lines {
+		separator := "-"
+		if i == len(lines)-1 {
+			separator = " "
+		}
+		c.text.PrintfLine("250%s%s", separator, line)
+	}
+}
+
+func (c *session) auth(arg string) {
+	mechanism, response, _ := strings.Cut(arg, " ")
+	switch {
+	case !c.greeted:
+		c.reply(503, "5.5.1 EHLO first")
+		return
+	case !c.tls:
+		c.reply(538, "5.7.11 Encryption required for requested authentication mechanism")
+		return
+	case !strings.EqualFold(mechanism, "PLAIN"):
+		c.reply(504, "5.5.4 Unrecognized authentication type")
+		return
+	}
+
+	if response == "" {
+		c.reply(334, "")
+		line, err := c.text.ReadLine()
+		if err != nil {
+			return
+		}
+		response = line
+	}
+
+	// the PLAIN response is authzid\x00username\x00password
+	decoded, err := base64.StdEncoding.DecodeString(response)
+	fields := strings.Split(string(decoded), "\x00")
+	if err != nil || len(fields) != 3 || fields[1] != c.server.Username || fields[2] != c.server.Password {
+		c.reply(535, "5.7.8 Authentication credentials invalid")
+		return
+	}
+
+	c.username = fields[1]
+	c.reply(235, "2.7.0 Authentication successful")
+}
+
+func (c *session) mail(arg string) {
+	switch {
+	case !c.greeted:
+		c.reply(503, "5.5.1 EHLO first")
+	case c.server.Username != "" && c.username == "":
+		c.reply(530, "5.7.0 Authentication required")
+	case !hasPrefixFold(arg, "FROM:"):
+		c.reply(501, "5.5.4 Syntax: MAIL FROM:<address>")
+	default:
+		c.reset()
+		c.from = address(arg[len("FROM:"):])
+		c.reply(250, "2.1.0 OK")
+	}
+}
+
+func (c *session) rcpt(arg string) {
+	switch {
+	case c.from == "":
+		c.reply(503, "5.5.1 MAIL first")
+	case !hasPrefixFold(arg, "TO:"):
+		c.reply(501, "5.5.4 Syntax: RCPT TO:<address>")
+	default:
+		to := address(arg[len("TO:"):])
+		if !strings.Contains(to, "@") {
+			c.reply(553, "5.1.3 Invalid recipient: "+to)
+			return
+		}
+		c.to = append(c.to, to)
+		c.reply(250, "2.1.5 OK")
+	}
+}
+
+func (c *session) data() {
+	if len(c.to) == 0 {
+		c.reply(503, "5.5.1 RCPT first")
+		return
+	}
+
+	c.reply(354, "Start mail input; end with <CRLF>.<CRLF>")
+	content, err := c.text.ReadDotBytes()
+	if err != nil {
+		return
+	}
+
+	s := c.server
+	s.mu.Lock()
+	if len(s.fail) > 0 {
+		code := s.fail[0]
+		s.fail = s.fail[1:]
+		s.mu.Unlock()
+		c.reset()
+		c.reply(code, "injected failure")
+		return
+	}
+	s.nextID++
+	id := s.nextID
+	s.messages = append(s.messages, Message{
+		From:       c.from,
+		To:         c.to,
+		Data:       content,
+		Username:   c.username,
+		TLS:        c.tls,
+		ReceivedAt: time.Now(),
+	})
+	s.mu.Unlock()
+
+	c.reset()
+	c.reply(250, fmt.Sprintf("2.0.0 OK queued as %d", id))
+}
+
+// reset clears the mail transaction
+func (c *session) reset() {
+	c.from = ""
+	c.to = nil
+}
+
+func (c *session) reply(code int, message string) {
+	c.text.PrintfLine("%d %s", code, message)
+}
+
+// address returns the address of a MAIL or RCPT argument, such as
+// <a@b.com> BODY=8BITMIME
+func address(arg string) string {
+	arg = strings.TrimSpace(arg)
+	if i := strings.Index(arg, ">"); strings.HasPrefix(arg, "<") && i > 0 {
+		return arg[1:i]
+	}
+	return strings.Fields(arg + " ")[0]
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// selfSignedCertificate creates the server certificate of 127.0.0.1 and
+// localhost, and a pool with the certificate
+func selfSignedCertificate() (tls.Certificate, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to generate key, error: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "smtptest"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:              []string{"localhost"},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to create certificate, error: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to parse certificate, error: %w", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, roots, nil
+}
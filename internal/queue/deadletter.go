@@ -0,0 +1,134 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrDeadLetterNotFound is returned for unknown dead letter ids
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetter is a message removed from the queue after its processing
+// failed for good
+type DeadLetter struct {
+	Message
+	Error  string    `json:"error"`
+	DeadAt time.Time `json:"dead_at"`
+}
+
+// DeadLetterStore keeps dead letters as one JSON file per message in a
+// directory, so they can be listed, inspected and replayed
+type DeadLetterStore struct {
+	dir string
+}
+
+func NewDeadLetterStore(dir string) (*DeadLetterStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create dead letter dir: %s, error: %w", dir, err)
+	}
+	return &DeadLetterStore{dir: dir}, nil
+}
+
+// Add stores the message with the error that failed it
+func (s *DeadLetterStore) Add(msg Message, cause error) error {
+	letter := DeadLetter{
+		Message: msg,
+		Error:   cause.Error(),
+		DeadAt:  time.Now(),
+	}
+	letter.Receipt = ""
+	letter.LeaseUntil = time.Time{}
+
+	content, err := json.MarshalIndent(letter, "", "   ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %s, error: %w", msg.ID, err)
+	}
+
+	path := s.path(msg.ID)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write dead letter: %s, error: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to rename dead letter: %s, error: %w", tmpPath, err)
+	}
+
+	return nil
+}
+
+// List returns the dead letters, oldest first
+func (s *DeadLetterStore) List() ([]DeadLetter, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letter dir: %s, error: %w", s.dir, err)
+	}
+
+	var letters []DeadLetter
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok {
+			continue
+		}
+		letter, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].DeadAt.Before(letters[j].DeadAt)
+	})
+
+	return letters, nil
+}
+
+// Get returns a dead letter by message id
+func (s *DeadLetterStore) Get(id string) (DeadLetter, error) {
+	var letter DeadLetter
+
+	content, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return letter, fmt.Errorf("message: %s, error: %w", id, ErrDeadLetterNotFound)
+	}
+	if err != nil {
+		return letter, fmt.Errorf("failed to read dead letter: %s, error: %w", id, err)
+	}
+
+	if err := json.Unmarshal(content, &letter); err != nil {
+		return letter, fmt.Errorf("failed to unmarshal dead letter: %s, error: %w", id, err)
+	}
+
+	return letter, nil
+}
+
+// Replay enqueues the dead letter body as a new message and removes the
+// dead letter, it returns the id of the new message
+func (s *DeadLetterStore) Replay(ctx context.Context, id string, q Queue) (string, error) {
+	letter, err := s.Get(id)
+	if err != nil {
+		return "", err
+	}
+
+	newID, err := q.Enqueue(ctx, letter.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to enqueue dead letter: %s, error: %w", id, err)
+	}
+
+	if err := os.Remove(s.path(id)); err != nil {
+		return newID, fmt.Errorf("failed to remove replayed dead letter: %s, error: %w", id, err)
+	}
+
+	return newID, nil
+}
+
+func (s *DeadLetterStore) path(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+".json")
+}
@@ -0,0 +1,77 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDeadLetterStore(t *testing.T) {
+	store, err := NewDeadLetterStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDeadLetterStore() error = %v", err)
+	}
+
+	q := NewMemoryQueue()
+	defer q.Close()
+	enqueue(t, q, "first", "second")
+	msgs := fetch(t, q, 2)
+	for i, msg := range msgs {
+		if err := store.Add(msg, errors.New("failed "+string(msg.Body))); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		if err := q.Ack(context.Background(), msg); err != nil {
+			t.Fatalf("Ack() error = %v", err)
+		}
+		if i == 0 {
+			// dead letters are listed oldest first
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	letters, err := store.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(letters) != 2 {
+		t.Fatalf("dead letters = %d, want 2", len(letters))
+	}
+	for i, letter := range letters {
+		if letter.ID != msgs[i].ID || letter.Error != "failed "+string(msgs[i].Body) {
+			t.Errorf("dead letter %d = %s %q, want %s", i, letter.ID, letter.Error, msgs[i].ID)
+		}
+		if letter.Receipt != "" || !letter.LeaseUntil.IsZero() {
+			t.Errorf("dead letter %s keeps the lease of its last delivery", letter.ID)
+		}
+	}
+
+	// a replayed dead letter is a new message with the same body
+	newID, err := store.Replay(context.Background(), msgs[0].ID, q)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	replayed := fetch(t, q, 1)
+	if len(replayed) != 1 || replayed[0].ID != newID || string(replayed[0].Body) != "first" ||
+		replayed[0].Deliveries != 1 {
+		t.Errorf("replayed messages = %v, want %s with body %q", replayed, newID, "first")
+	}
+
+	for _, id := range []string{msgs[0].ID, "unknown"} {
+		if _, err := store.Get(id); !errors.Is(err, ErrDeadLetterNotFound) {
+			t.Errorf("Get(%s) error = %v, want %v", id, err, ErrDeadLetterNotFound)
+		}
+	}
+	if _, err := store.Replay(context.Background(), "unknown", q); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("Replay() of unknown letter error = %v, want %v", err, ErrDeadLetterNotFound)
+	}
+
+	// files other than dead letters are ignored
+	if err := os.WriteFile(store.path("notes")+".txt", []byte("notes"), 0o644); err != nil {
+		t.Fatalf("failed to write file, error: %v", err)
+	}
+	if letters, err := store.List(); err != nil || len(letters) != 1 {
+		t.Errorf("List() = %d letters, error: %v, want 1", len(letters), err)
+	}
+}
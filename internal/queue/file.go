@@ -0,0 +1,102 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/filelock"
+)
+
+// FileQueue is a durable queue keeping its messages in a JSON file. The
+// file is shared by the processes opening it: every operation locks the
+// file, loads the messages and replaces the file atomically on change, so
+// messages and their leases survive restarts; leases of a stopped consumer
+// simply expire.
+type FileQueue struct {
+	*MemoryQueue
+	path string
+	lock *filelock.Lock
+}
+
+// OpenFileQueue opens the queue file, creating its dir when missing
+func OpenFileQueue(path string) (*FileQueue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create queue dir: %s, error: %w", filepath.Dir(path), err)
+	}
+
+	q := &FileQueue{MemoryQueue: NewMemoryQueue(), path: path}
+	q.store = q
+
+	// check the queue file can be read
+	if _, err := q.Len(); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// load locks the queue file and reads its entries, a missing file is an
+// empty queue
+func (q *FileQueue) load() ([]*entry, error) {
+	lock, err := filelock.Acquire(q.path + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock queue file: %s, error: %w", q.path, err)
+	}
+
+	var entries []*entry
+	content, err := os.ReadFile(q.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		err = nil
+	case err != nil:
+		err = fmt.Errorf("failed to read queue file: %s, error: %w", q.path, err)
+	default:
+		if err = json.Unmarshal(content, &entries); err != nil {
+			err = fmt.Errorf("failed to unmarshal queue file: %s, error: %w", q.path, err)
+		}
+	}
+	if err != nil {
+		q.release(lock)
+		return nil, err
+	}
+
+	q.lock = lock
+	return entries, nil
+}
+
+// save writes the queue entries, called with the queue file locked
+func (q *FileQueue) save(entries []*entry) error {
+	if entries == nil {
+		entries = []*entry{}
+	}
+
+	content, err := json.MarshalIndent(entries, "", "   ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal queue, error: %w", err)
+	}
+
+	tmpPath := q.path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write queue file: %s, error: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, q.path); err != nil {
+		return fmt.Errorf("failed to rename queue file: %s, error: %w", tmpPath, err)
+	}
+
+	return nil
+}
+
+func (q *FileQueue) unlock() {
+	q.release(q.lock)
+	q.lock = nil
+}
+
+func (q *FileQueue) release(lock *filelock.Lock) {
+	if err := lock.Release(); err != nil {
+		log.Error().Msgf("failed to unlock queue file: %s, error: %v", q.path, err)
+	}
+}
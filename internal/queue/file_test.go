@@ -0,0 +1,106 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func openFileQueue(t *testing.T, path string) *FileQueue {
+	t.Helper()
+
+	q, err := OpenFileQueue(path)
+	if err != nil {
+		t.Fatalf("OpenFileQueue() error = %v", err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+func TestFileQueueShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	producer := openFileQueue(t, path)
+	consumer := openFileQueue(t, path)
+
+	ids := enqueue(t, producer, "first", "second")
+
+	// the lease of a consumer hides the message from the others
+	msgs := fetch(t, consumer, 1)
+	if len(msgs) != 1 || msgs[0].ID != ids[0] {
+		t.Fatalf("consumer fetched %v, want %s", msgs, ids[0])
+	}
+	others := fetch(t, producer, 2)
+	if len(others) != 1 || others[0].ID != ids[1] {
+		t.Fatalf("producer fetched %v, want %s", others, ids[1])
+	}
+
+	if err := consumer.Ack(context.Background(), msgs[0]); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if err := producer.Nack(context.Background(), others[0], 0); err != nil {
+		t.Fatalf("Nack() error = %v", err)
+	}
+
+	// messages survive reopening the file
+	reopened := openFileQueue(t, path)
+	checkLen(t, reopened, 1)
+	again := fetch(t, reopened, 2)
+	if len(again) != 1 || again[0].ID != ids[1] || again[0].Deliveries != 2 || string(again[0].Body) != "second" {
+		t.Errorf("reopened queue fetched %v, want %s on second delivery", again, ids[1])
+	}
+}
+
+func TestFileQueueConcurrentEnqueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+
+	// every queue reloads the file, so no enqueue is lost
+	const queues, perQueue = 4, 20
+	var wg sync.WaitGroup
+	for i := 0; i < queues; i++ {
+		q := openFileQueue(t, path)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perQueue; j++ {
+				if _, err := q.Enqueue(context.Background(), []byte("body")); err != nil {
+					t.Errorf("Enqueue() error = %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	checkLen(t, openFileQueue(t, path), queues*perQueue)
+}
+
+func TestFileQueueCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatalf("failed to write queue file, error: %v", err)
+	}
+
+	if _, err := OpenFileQueue(path); err == nil {
+		t.Fatal("OpenFileQueue() of a corrupt file succeeded")
+	}
+
+	// a failed operation keeps the queue usable once the file is fixed
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("failed to remove queue file, error: %v", err)
+	}
+	q := openFileQueue(t, path)
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatalf("failed to write queue file, error: %v", err)
+	}
+	if _, err := q.Enqueue(context.Background(), []byte("body")); err == nil || errors.Is(err, ErrClosed) {
+		t.Fatalf("Enqueue() to a corrupt file error = %v, want an unmarshal error", err)
+	}
+	if err := os.WriteFile(path, []byte("[]"), 0o644); err != nil {
+		t.Fatalf("failed to write queue file, error: %v", err)
+	}
+	enqueue(t, q, "body")
+	checkLen(t, q, 1)
+}
@@ -0,0 +1,258 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// entry is a queued message and the state of its current lease
+type entry struct {
+	Message
+	VisibleAt time.Time `json:"visible_at"`
+}
+
+// MemoryQueue is an in-process queue, messages are lost when the process
+// exits
+type MemoryQueue struct {
+	mu      sync.Mutex
+	entries []*entry
+	closed  bool
+	now     func() time.Time
+
+	// store, when set, keeps the entries shared with other processes, the
+	// entries are loaded before and saved after every operation
+	store store
+}
+
+// store keeps the queue entries outside the process, see FileQueue
+type store interface {
+	// load locks the store and returns its entries
+	load() ([]*entry, error)
+
+	// save replaces the stored entries
+	save(entries []*entry) error
+
+	// unlock releases the store lock taken by load
+	unlock()
+}
+
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{now: time.Now}
+}
+
+func (q *MemoryQueue) Enqueue(ctx context.Context, body []byte) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return "", ErrClosed
+	}
+
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+
+	err = q.update(func() (bool, error) {
+		now := q.now()
+		q.entries = append(q.entries, &entry{
+			Message: Message{
+				ID:         id,
+				Body:       append([]byte(nil), body...),
+				EnqueuedAt: now,
+			},
+			VisibleAt: now,
+		})
+		return true, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (q *MemoryQueue) Fetch(ctx context.Context, max int, visibility time.Duration) ([]Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil, ErrClosed
+	}
+
+	// lease visible messages in enqueue order
+	var batch []Message
+	err := q.update(func() (bool, error) {
+		now := q.now()
+		for _, e := range q.entries {
+			if len(batch) >= max {
+				break
+			}
+			if e.VisibleAt.After(now) {
+				continue
+			}
+
+			receipt, err := newID()
+			if err != nil {
+				return false, err
+			}
+			e.Receipt = receipt
+			e.Deliveries++
+			e.LeaseUntil = now.Add(visibility)
+			e.VisibleAt = e.LeaseUntil
+			batch = append(batch, e.Message)
+		}
+		return len(batch) > 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+func (q *MemoryQueue) Ack(ctx context.Context, msg Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.update(func() (bool, error) {
+		i, err := q.leased(msg)
+		if err != nil {
+			return false, err
+		}
+		q.entries = append(q.entries[:i], q.entries[i+1:]...)
+		return true, nil
+	})
+}
+
+func (q *MemoryQueue) Nack(ctx context.Context, msg Message, delay time.Duration) error {
+	return q.release(msg, delay, false)
+}
+
+func (q *MemoryQueue) Requeue(ctx context.Context, msg Message, delay time.Duration) error {
+	return q.release(msg, delay, true)
+}
+
+func (q *MemoryQueue) ExtendLease(ctx context.Context, msg Message, visibility time.Duration) (Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	extended := msg
+	err := q.update(func() (bool, error) {
+		i, err := q.leased(msg)
+		if err != nil {
+			return false, err
+		}
+		e := q.entries[i]
+		e.LeaseUntil = q.now().Add(visibility)
+		e.VisibleAt = e.LeaseUntil
+		extended = e.Message
+		return true, nil
+	})
+	if err != nil {
+		return msg, err
+	}
+
+	return extended, nil
+}
+
+// Len returns the number of queued messages, including leased messages
+func (q *MemoryQueue) Len() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	err := q.update(func() (bool, error) {
+		return false, nil
+	})
+	return len(q.entries), err
+}
+
+func (q *MemoryQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	return nil
+}
+
+// release ends the lease of a message, making it visible again after the
+// delay. An uncounted delivery is taken back from the message deliveries.
+func (q *MemoryQueue) release(msg Message, delay time.Duration, uncounted bool) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.update(func() (bool, error) {
+		i, err := q.leased(msg)
+		if err != nil {
+			return false, err
+		}
+		e := q.entries[i]
+		e.Receipt = ""
+		e.LeaseUntil = time.Time{}
+		e.VisibleAt = q.now().Add(delay)
+		if uncounted && e.Deliveries > 0 {
+			e.Deliveries--
+		}
+		return true, nil
+	})
+}
+
+// leased returns the index of the message entry when the message lease is
+// still held by the receipt of the message
+func (q *MemoryQueue) leased(msg Message) (int, error) {
+	if q.closed {
+		return -1, ErrClosed
+	}
+
+	for i, e := range q.entries {
+		if e.ID != msg.ID {
+			continue
+		}
+		if e.Receipt != msg.Receipt || !e.LeaseUntil.After(q.now()) {
+			return -1, fmt.Errorf("message: %s, error: %w", msg.ID, ErrLeaseLost)
+		}
+		return i, nil
+	}
+
+	return -1, fmt.Errorf("message: %s not found, error: %w", msg.ID, ErrLeaseLost)
+}
+
+// update applies a change to the entries, called with the queue lock held.
+// With a store the entries are loaded first, and saved when the change
+// reports them changed. The entries are restored when the change or the
+// save fails, so memory never holds changes that were not stored.
+func (q *MemoryQueue) update(change func() (bool, error)) error {
+	entries := q.entries
+	if q.store != nil {
+		loaded, err := q.store.load()
+		if err != nil {
+			return err
+		}
+		defer q.store.unlock()
+		entries = loaded
+	}
+
+	// change copies of the entries
+	q.entries = make([]*entry, len(entries))
+	for i, e := range entries {
+		copied := *e
+		q.entries[i] = &copied
+	}
+
+	changed, err := change()
+	if err == nil && changed && q.store != nil {
+		err = q.store.save(q.entries)
+	}
+	if err != nil {
+		q.entries = entries
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,272 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testVisibility = 10 * time.Second
+
+// testClock is the time of a test queue, moved by the test
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// testQueue is a queue whose length is checked by the tests
+type testQueue interface {
+	Queue
+	Len() (int, error)
+}
+
+// forEachQueue runs the test on a memory queue and on a file queue, both
+// on a test clock
+func forEachQueue(t *testing.T, test func(t *testing.T, q testQueue, clock *testClock)) {
+	t.Helper()
+
+	for _, tt := range []struct {
+		name string
+		open func(t *testing.T) (testQueue, *MemoryQueue)
+	}{
+		{
+			name: "memory",
+			open: func(t *testing.T) (testQueue, *MemoryQueue) {
+				q := NewMemoryQueue()
+				return q, q
+			},
+		},
+		{
+			name: "file",
+			open: func(t *testing.T) (testQueue, *MemoryQueue) {
+				q, err := OpenFileQueue(filepath.Join(t.TempDir(), "queue", "queue.json"))
+				if err != nil {
+					t.Fatalf("OpenFileQueue() error = %v", err)
+				}
+				return q, q.MemoryQueue
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			q, memory := tt.open(t)
+			t.Cleanup(func() { q.Close() })
+
+			clock := &testClock{now: time.Date(2025, time.January, 1, 8, 0, 0, 0, time.UTC)}
+			memory.now = clock.Now
+
+			test(t, q, clock)
+		})
+	}
+}
+
+func enqueue(t *testing.T, q Queue, bodies ...string) []string {
+	t.Helper()
+
+	var ids []string
+	for _, body := range bodies {
+		id, err := q.Enqueue(context.Background(), []byte(body))
+		if err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func fetch(t *testing.T, q Queue, max int) []Message {
+	t.Helper()
+
+	msgs, err := q.Fetch(context.Background(), max, testVisibility)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	return msgs
+}
+
+func checkLen(t *testing.T, q testQueue, want int) {
+	t.Helper()
+
+	n, err := q.Len()
+	if err != nil {
+		t.Fatalf("Len() error = %v", err)
+	}
+	if n != want {
+		t.Errorf("Len() = %d, want %d", n, want)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	forEachQueue(t, func(t *testing.T, q testQueue, clock *testClock) {
+		ids := enqueue(t, q, "first", "second", "third")
+
+		// batches are leased in enqueue order, leased messages are hidden
+		for _, want := range [][]string{ids[:2], ids[2:], nil} {
+			msgs := fetch(t, q, 2)
+			if len(msgs) != len(want) {
+				t.Fatalf("fetched %d messages, want %d", len(msgs), len(want))
+			}
+			for i, msg := range msgs {
+				if msg.ID != want[i] || msg.Deliveries != 1 || msg.Receipt == "" {
+					t.Errorf("message = %s deliveries: %d receipt: %q, want %s on first delivery",
+						msg.ID, msg.Deliveries, msg.Receipt, want[i])
+				}
+				if !msg.LeaseUntil.Equal(clock.now.Add(testVisibility)) {
+					t.Errorf("lease of message: %s until: %s, want %s",
+						msg.ID, msg.LeaseUntil, clock.now.Add(testVisibility))
+				}
+			}
+		}
+
+		checkLen(t, q, 3)
+	})
+}
+
+func TestLease(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// settle acts on the fetched message, the message is visible again
+		// after the returned time
+		settle func(t *testing.T, q Queue, clock *testClock, msg Message) time.Duration
+
+		wantLen        int
+		wantDeliveries int
+	}{
+		{
+			name: "ack",
+			settle: func(t *testing.T, q Queue, clock *testClock, msg Message) time.Duration {
+				if err := q.Ack(context.Background(), msg); err != nil {
+					t.Fatalf("Ack() error = %v", err)
+				}
+				return 0
+			},
+		},
+		{
+			name: "nack",
+			settle: func(t *testing.T, q Queue, clock *testClock, msg Message) time.Duration {
+				if err := q.Nack(context.Background(), msg, time.Minute); err != nil {
+					t.Fatalf("Nack() error = %v", err)
+				}
+				if err := q.Ack(context.Background(), msg); !errors.Is(err, ErrLeaseLost) {
+					t.Errorf("Ack() after Nack() error = %v, want %v", err, ErrLeaseLost)
+				}
+				return time.Minute
+			},
+			wantLen:        1,
+			wantDeliveries: 2,
+		},
+		{
+			name: "requeue",
+			settle: func(t *testing.T, q Queue, clock *testClock, msg Message) time.Duration {
+				if err := q.Requeue(context.Background(), msg, time.Minute); err != nil {
+					t.Fatalf("Requeue() error = %v", err)
+				}
+				return time.Minute
+			},
+			wantLen:        1,
+			wantDeliveries: 1,
+		},
+		{
+			name: "expired lease",
+			settle: func(t *testing.T, q Queue, clock *testClock, msg Message) time.Duration {
+				clock.advance(testVisibility)
+				for _, err := range []error{
+					q.Ack(context.Background(), msg),
+					q.Nack(context.Background(), msg, 0),
+				} {
+					if !errors.Is(err, ErrLeaseLost) {
+						t.Errorf("settle after lease expired error = %v, want %v", err, ErrLeaseLost)
+					}
+				}
+				if _, err := q.ExtendLease(context.Background(), msg, testVisibility); !errors.Is(err, ErrLeaseLost) {
+					t.Errorf("ExtendLease() after lease expired error = %v, want %v", err, ErrLeaseLost)
+				}
+				return 0
+			},
+			wantLen:        1,
+			wantDeliveries: 2,
+		},
+		{
+			name: "extended lease",
+			settle: func(t *testing.T, q Queue, clock *testClock, msg Message) time.Duration {
+				clock.advance(testVisibility / 2)
+				extended, err := q.ExtendLease(context.Background(), msg, testVisibility)
+				if err != nil {
+					t.Fatalf("ExtendLease() error = %v", err)
+				}
+				if !extended.LeaseUntil.Equal(clock.now.Add(testVisibility)) || extended.Receipt != msg.Receipt {
+					t.Errorf("extended lease until: %s receipt: %s, want %s receipt: %s",
+						extended.LeaseUntil, extended.Receipt, clock.now.Add(testVisibility), msg.Receipt)
+				}
+				return testVisibility
+			},
+			wantLen:        1,
+			wantDeliveries: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachQueue(t, func(t *testing.T, q testQueue, clock *testClock) {
+				enqueue(t, q, "body")
+				msgs := fetch(t, q, 1)
+				if len(msgs) != 1 {
+					t.Fatalf("fetched %d messages, want 1", len(msgs))
+				}
+
+				visibleAfter := tt.settle(t, q, clock, msgs[0])
+				checkLen(t, q, tt.wantLen)
+				if tt.wantLen == 0 {
+					return
+				}
+
+				// hidden until the end of the delay or lease, then delivered
+				// again with a new receipt
+				if visibleAfter > 0 {
+					clock.advance(visibleAfter - time.Second)
+					if msgs := fetch(t, q, 1); len(msgs) != 0 {
+						t.Fatalf("fetched message: %s before it is visible", msgs[0].ID)
+					}
+					clock.advance(time.Second)
+				}
+				again := fetch(t, q, 1)
+				if len(again) != 1 {
+					t.Fatalf("fetched %d messages again, want 1", len(again))
+				}
+				if again[0].Deliveries != tt.wantDeliveries || again[0].Receipt == msgs[0].Receipt ||
+					string(again[0].Body) != "body" {
+					t.Errorf("message delivered again = %q deliveries: %d receipt: %s, want %q deliveries: %d new receipt",
+						again[0].Body, again[0].Deliveries, again[0].Receipt, "body", tt.wantDeliveries)
+				}
+			})
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	forEachQueue(t, func(t *testing.T, q testQueue, clock *testClock) {
+		enqueue(t, q, "body")
+		msgs := fetch(t, q, 1)
+
+		if err := q.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+
+		_, enqueueErr := q.Enqueue(context.Background(), []byte("body"))
+		_, fetchErr := q.Fetch(context.Background(), 1, testVisibility)
+		for _, err := range []error{enqueueErr, fetchErr, q.Ack(context.Background(), msgs[0])} {
+			if !errors.Is(err, ErrClosed) {
+				t.Errorf("operation on closed queue error = %v, want %v", err, ErrClosed)
+			}
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrLeaseLost is returned when a message lease expired, or the message
+	// was fetched again by another consumer, before it was acked, nacked or
+	// extended
+	ErrLeaseLost = errors.New("message lease lost")
+
+	// ErrClosed is returned by operations on a closed queue
+	ErrClosed = errors.New("queue closed")
+)
+
+// Message is a message delivered by a queue. The receipt identifies the
+// delivery, a message fetched again gets a new receipt. Deliveries counts
+// the fetches of the message, including the current one.
+type Message struct {
+	ID         string    `json:"id"`
+	Body       []byte    `json:"body"`
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	Deliveries int       `json:"deliveries"`
+	Receipt    string    `json:"receipt,omitempty"`
+	LeaseUntil time.Time `json:"lease_until"`
+}
+
+// Queue is a work queue with visibility timeouts: fetched messages are
+// hidden from other consumers until their lease ends, and are delivered
+// again unless acked before that
+type Queue interface {
+	// Enqueue adds a message to the queue and returns its id
+	Enqueue(ctx context.Context, body []byte) (string, error)
+
+	// Fetch leases up to max visible messages for the visibility timeout,
+	// it returns an empty batch when no message is visible
+	Fetch(ctx context.Context, max int, visibility time.Duration) ([]Message, error)
+
+	// Ack removes a leased message from the queue
+	Ack(ctx context.Context, msg Message) error
+
+	// Nack ends the lease of a message, making it visible again after the
+	// delay
+	Nack(ctx context.Context, msg Message, delay time.Duration) error
+
+	// Requeue ends the lease of a message like Nack without counting the
+	// delivery, for messages returned before they were processed
+	Requeue(ctx context.Context, msg Message, delay time.Duration) error
+
+	// ExtendLease extends the lease of a message by the visibility timeout
+	// from now
+	ExtendLease(ctx context.Context, msg Message, visibility time.Duration) (Message, error)
+
+	Close() error
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to create message id, error: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
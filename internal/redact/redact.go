@@ -0,0 +1,74 @@
+// Package redact removes secrets, such as access tokens and client
+// secrets, from log lines and error messages
+package redact
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const REDACTED = "[REDACTED]"
+
+// minSecretLength skips short values that would redact common words
+const minSecretLength = 8
+
+var (
+	mu      sync.RWMutex
+	secrets = map[string]bool{}
+
+	// patterns of secrets redacted without being registered, the first
+	// group is kept
+	patterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`),
+		regexp.MustCompile(`()eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`),
+		regexp.MustCompile(`(?i)((?:access_token|refresh_token|id_token|client_secret|password)\\?"?\s*[:=]\s*\\?"?)[^"\\&\s,}]+`),
+	}
+)
+
+// Secret registers a secret value, it is redacted from every string
+func Secret(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	secrets[value] = true
+}
+
+// String returns s with the registered secrets and the token patterns
+// redacted
+func String(s string) string {
+	mu.RLock()
+	for secret := range secrets {
+		s = strings.ReplaceAll(s, secret, REDACTED)
+	}
+	mu.RUnlock()
+
+	for _, pattern := range patterns {
+		s = pattern.ReplaceAllString(s, "${1}"+REDACTED)
+	}
+
+	return s
+}
+
+// Writer redacts the lines written to the underlying writer, such as the
+// log output
+type Writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write redacts p, each write is expected to hold whole log lines
+func (w *Writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
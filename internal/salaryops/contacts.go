@@ -0,0 +1,58 @@
+package salaryops
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/vgeshiktor/bhops/internal/attendanceops"
+	"github.com/vgeshiktor/bhops/internal/salaryops/publish"
+)
+
+// Contact holds the addresses payslips of a worker are published to, and
+// the language of the messages when it differs from the report language
+type Contact struct {
+	Email    string `json:"email,omitempty"`
+	WhatsApp string `json:"whatsapp,omitempty"`
+	Language string `json:"language,omitempty"`
+}
+
+// Address returns the contact address of a publish channel
+func (c Contact) Address(channel string) string {
+	switch channel {
+	case publish.ChannelEmail:
+		return c.Email
+	case publish.ChannelWhatsApp:
+		return c.WhatsApp
+	}
+	return ""
+}
+
+// LoadContacts loads the worker contacts file, keyed by worker id
+func LoadContacts(contactsPath string) (map[string]Contact, error) {
+	content, err := os.ReadFile(contactsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contacts file: %s, error: %w", contactsPath, err)
+	}
+
+	var contacts map[string]Contact
+	if err := json.Unmarshal(content, &contacts); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal contacts file: %s, error: %w", contactsPath, err)
+	}
+
+	return contacts, nil
+}
+
+// contactLanguage returns the message language of a worker, the contact
+// language overrides the payslip language
+func contactLanguage(payslip Payslip, contact Contact) attendanceops.Language {
+	for _, code := range []string{contact.Language, payslip.Language} {
+		if code == "" {
+			continue
+		}
+		if lang, ok := attendanceops.ParseLanguage(code); ok {
+			return lang
+		}
+	}
+	return attendanceops.DefaultLanguage
+}
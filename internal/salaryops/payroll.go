@@ -0,0 +1,152 @@
+package salaryops
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/vgeshiktor/bhops/internal/attendanceops"
+)
+
+// Files of the payroll of a workspace period, kept next to the period
+// outputs
+const (
+	PayrollFile = "payroll.json"
+	PayslipsDir = "payslips"
+)
+
+// Pay line kinds
+const (
+	PayRegularHours   = "regular_hours"
+	PayHours125       = "hours_125"
+	PayMonthlySal     = "monthly_sal"
+	PayTransport      = "transport"
+	PayHolidayPresent = "holiday_present"
+)
+
+// lineName returns the display name of a pay line kind, pay lines share the
+// labels of the attendance report rows
+func lineName(labels attendanceops.Labels, kind string) string {
+	switch kind {
+	case PayRegularHours:
+		return labels.RegularHours
+	case PayHours125:
+		return labels.Hours125
+	case PayMonthlySal:
+		return labels.MonthlySal
+	case PayTransport:
+		return labels.Transport
+	case PayHolidayPresent:
+		return labels.Gift
+	}
+	return kind
+}
+
+// PayLine is a single gross pay item of a payslip
+type PayLine struct {
+	Kind     string  `json:"kind"`
+	Quantity float64 `json:"quantity,omitempty"`
+	Rate     float64 `json:"rate,omitempty"`
+	Amount   float64 `json:"amount"`
+}
+
+// Payslip holds the gross pay lines of a worker for a period
+type Payslip struct {
+	WorkerID   string               `json:"worker_id"`
+	Name       string               `json:"name"`
+	WorkerType string               `json:"worker_type"`
+	Language   string               `json:"language,omitempty"`
+	Period     string               `json:"period"`
+	Lines      []PayLine            `json:"lines"`
+	Gross      float64              `json:"gross"`
+	Worker     attendanceops.Worker `json:"worker"`
+}
+
+// Payroll holds the payslips of all workers for a period
+type Payroll struct {
+	Period   string    `json:"period"`
+	Payslips []Payslip `json:"payslips"`
+	Gross    float64   `json:"gross"`
+}
+
+// Compute calculates the gross pay lines of the attendance report workers.
+// Hours and rates are rounded the same way as in the attendance report, so
+// the salary lines match the report totals, the holiday present is added on
+// top of them.
+func Compute(period string, workers []attendanceops.Worker) *Payroll {
+	payroll := &Payroll{Period: period}
+
+	for _, worker := range workers {
+		payslip := Payslip{
+			WorkerID:   worker.WorkerID,
+			Name:       worker.Name,
+			WorkerType: worker.WorkerType,
+			Language:   worker.Language,
+			Period:     period,
+			Worker:     worker,
+		}
+
+		addLine := func(kind string, quantity, rate, amount float64) {
+			if amount == 0 {
+				return
+			}
+			payslip.Lines = append(payslip.Lines, PayLine{
+				Kind:     kind,
+				Quantity: quantity,
+				Rate:     rate,
+				Amount:   amount,
+			})
+			payslip.Gross += amount
+		}
+
+		hours := math.Round(worker.Hours)
+		hours125 := math.Round(worker.Hours125)
+
+		switch worker.WorkerType {
+		case "hourly":
+			addLine(PayRegularHours, hours, math.Round(worker.PerHour), hours*math.Round(worker.PerHour))
+			addLine(PayHours125, hours125, math.Round(worker.PerHour125), hours125*math.Round(worker.PerHour125))
+		case "daily":
+			addLine(PayRegularHours, hours, math.Round(worker.PerHour), hours*math.Round(worker.PerHour))
+		default:
+			addLine(PayMonthlySal, 0, 0, math.Round(worker.MonthlySal))
+		}
+		addLine(PayTransport, 0, 0, math.Round(worker.TransExpanses))
+		addLine(PayHolidayPresent, 0, 0, math.Round(worker.HolidayPresent))
+
+		payroll.Payslips = append(payroll.Payslips, payslip)
+		payroll.Gross += payslip.Gross
+	}
+
+	return payroll
+}
+
+// SavePayroll saves the payroll as JSON
+func SavePayroll(payroll *Payroll, payrollPath string) error {
+	content, err := json.MarshalIndent(payroll, "", "   ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal payroll, error: %w", err)
+	}
+
+	if err := os.WriteFile(payrollPath, content, 0o644); err != nil {
+		return fmt.Errorf("failed to save payroll file: %s, error: %w", payrollPath, err)
+	}
+
+	return nil
+}
+
+// LoadPayroll loads a payroll saved by SavePayroll
+func LoadPayroll(payrollPath string) (*Payroll, error) {
+	content, err := os.ReadFile(payrollPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payroll file: %s, error: %w", payrollPath, err)
+	}
+
+	var payroll Payroll
+	if err := json.Unmarshal(content, &payroll); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payroll file: %s, error: %w", payrollPath, err)
+	}
+
+	return &payroll, nil
+}
@@ -0,0 +1,158 @@
+package salaryops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/attendanceops"
+	"github.com/xuri/excelize/v2"
+)
+
+// Constants for payslip sheet configuration
+const (
+	PayslipSheetName   = "Payslip"
+	PayslipColumnWidth = 20
+)
+
+// PayslipPath returns the path of a worker payslip in the payslips dir
+func PayslipPath(payslipsDir, period, workerID string) string {
+	return filepath.Join(payslipsDir, fmt.Sprintf("payslip_%s_%s.xlsx", period, workerID))
+}
+
+// SavePayslips saves a payslip workbook per worker, written in the
+// worker's language, and returns the saved paths by worker id. Saving stops
+// when the context is done.
+func SavePayslips(ctx context.Context, payroll *Payroll, payslipsDir string) (map[string]string, error) {
+	if err := os.MkdirAll(payslipsDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create payslips dir: %s, error: %w", payslipsDir, err)
+	}
+
+	paths := make(map[string]string, len(payroll.Payslips))
+	for _, payslip := range payroll.Payslips {
+		if err := ctx.Err(); err != nil {
+			return paths, err
+		}
+		path := PayslipPath(payslipsDir, payroll.Period, payslip.WorkerID)
+		if err := SavePayslip(payslip, path); err != nil {
+			return paths, err
+		}
+		paths[payslip.WorkerID] = path
+	}
+
+	return paths, nil
+}
+
+// SavePayslip saves the payslip of a worker as a workbook
+func SavePayslip(payslip Payslip, payslipPath string) error {
+	lang, ok := attendanceops.ParseLanguage(payslip.Language)
+	if !ok {
+		lang = attendanceops.DefaultLanguage
+	}
+
+	f, err := newPayslipFile(payslip, lang)
+	if err != nil {
+		return fmt.Errorf("failed to create payslip for worker: %s, error: %w", payslip.WorkerID, err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Error().Err(err)
+		}
+	}()
+
+	if err := f.SaveAs(payslipPath); err != nil {
+		return fmt.Errorf("failed to save excel file: %s, error: %w", payslipPath, err)
+	}
+
+	return nil
+}
+
+func newPayslipFile(payslip Payslip, lang attendanceops.Language) (*excelize.File, error) {
+	f := excelize.NewFile()
+
+	// replace the default sheet with the payslip sheet
+	sheetName := PayslipSheetName
+	if err := f.SetSheetName(f.GetSheetName(0), sheetName); err != nil {
+		return nil, fmt.Errorf("failed to rename payslip sheet, error: %w", err)
+	}
+
+	RightToLeft := lang.RightToLeft()
+	err := f.SetSheetView(sheetName, 0, &excelize.ViewOptions{
+		RightToLeft: &RightToLeft,
+	})
+	if err != nil {
+		log.Error().Msgf("failed to set payslip sheet view direction, error: %v", err)
+	}
+
+	if err := f.SetColWidth(sheetName, "A", "D", PayslipColumnWidth); err != nil {
+		log.Error().Err(err)
+	}
+
+	// labels are aligned to the start of the reading direction
+	titleStyle, headerStyle := attendanceops.TitleCellStyle(), attendanceops.HeaderCellStyle()
+	if !RightToLeft {
+		titleStyle.Alignment = &attendanceops.ALIGN_LEFT
+		headerStyle.Alignment = &attendanceops.ALIGN_LEFT
+	}
+	titleStyleID, err := f.NewStyle(titleStyle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create title cell style, error: %w", err)
+	}
+	headerStyleID, err := f.NewStyle(headerStyle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create header cell style, error: %w", err)
+	}
+	numericStyleID, err := f.NewStyle(attendanceops.NumericCellStyle())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create numeric cell style, error: %w", err)
+	}
+
+	row := 1
+	setRow := func(styleID int, values ...interface{}) error {
+		cell := fmt.Sprintf("A%d", row)
+		if err := f.SetSheetRow(sheetName, cell, &values); err != nil {
+			return fmt.Errorf("failed to set payslip row: %s, error: %w", cell, err)
+		}
+		end, _ := excelize.CoordinatesToCellName(len(values), row)
+		if err := f.SetCellStyle(sheetName, cell, end, styleID); err != nil {
+			return fmt.Errorf("failed to set payslip row style: %s, error: %w", cell, err)
+		}
+		row++
+		return nil
+	}
+
+	labels := lang.Labels()
+	rows := []struct {
+		styleID int
+		values  []interface{}
+	}{
+		{titleStyleID, []interface{}{labels.Payslip, payslip.Name}},
+		{titleStyleID, []interface{}{labels.WorkerID, payslip.WorkerID}},
+		{titleStyleID, []interface{}{labels.Period, payslip.Period}},
+		{headerStyleID, []interface{}{labels.Item, labels.Quantity, labels.Rate, labels.Amount}},
+	}
+	for _, r := range rows {
+		if err := setRow(r.styleID, r.values...); err != nil {
+			return nil, err
+		}
+	}
+
+	// pay lines, quantity and rate are left empty for fixed amounts
+	for _, line := range payslip.Lines {
+		var quantity, rate interface{}
+		if line.Rate != 0 {
+			quantity, rate = line.Quantity, line.Rate
+		}
+		if err := setRow(numericStyleID, lineName(labels, line.Kind), quantity, rate, line.Amount); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := setRow(headerStyleID, labels.Gross, nil, nil, payslip.Gross); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
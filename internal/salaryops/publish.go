@@ -0,0 +1,172 @@
+package salaryops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/salaryops/publish"
+)
+
+// SendReportFile is the send report saved next to the payslips
+const SendReportFile = "send_report.json"
+
+// Publish result statuses
+const (
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// PublishResult is the outcome of publishing a payslip on one channel
+type PublishResult struct {
+	WorkerID    string   `json:"worker_id"`
+	Name        string   `json:"name"`
+	Channel     string   `json:"channel"`
+	Status      string   `json:"status"`
+	To          string   `json:"to,omitempty"`
+	Subject     string   `json:"subject,omitempty"`
+	Attachments []string `json:"attachments,omitempty"`
+	Error       string   `json:"error,omitempty"`
+
+	// Err is the publish error of a failed result
+	Err error `json:"-"`
+}
+
+// SendReport lists the published payslips of a period with their status
+type SendReport struct {
+	Period    string          `json:"period"`
+	Preview   bool            `json:"preview"`
+	CreatedAt time.Time       `json:"created_at"`
+	Sent      int             `json:"sent"`
+	Failed    int             `json:"failed"`
+	Skipped   int             `json:"skipped"`
+	Results   []PublishResult `json:"results"`
+}
+
+// PayslipAttachments returns the payslip files of a worker, the saved
+// payslip workbook and its PDF export when there is one
+func PayslipAttachments(payslipPath string) []string {
+	if payslipPath == "" {
+		return nil
+	}
+
+	attachments := []string{}
+	pdfPath := strings.TrimSuffix(payslipPath, ".xlsx") + ".pdf"
+	if _, err := os.Stat(pdfPath); err == nil {
+		attachments = append(attachments, pdfPath)
+	}
+	return append(attachments, payslipPath)
+}
+
+// PayslipMessage renders the message of a worker payslip, written in the
+// worker's contact language, with the payslip files attached
+func (t *Templates) PayslipMessage(payslip Payslip, contact Contact, channel, payslipPath string) (publish.Message, error) {
+	subject, text, html, err := t.Render(payslip, contactLanguage(payslip, contact))
+	if err != nil {
+		return publish.Message{}, err
+	}
+
+	return publish.Message{
+		WorkerID:    payslip.WorkerID,
+		To:          contact.Address(channel),
+		Subject:     subject,
+		Body:        text,
+		HTMLBody:    html,
+		Attachments: PayslipAttachments(payslipPath),
+	}, nil
+}
+
+// PublishPayslips publishes the payslip of every worker through each
+// publisher, to the worker contact address of the publisher channel.
+// Workers without an address on a channel are skipped on that channel.
+func PublishPayslips(
+	ctx context.Context,
+	payroll *Payroll,
+	payslipPaths map[string]string,
+	contacts map[string]Contact,
+	publishers []publish.Publisher,
+	templates *Templates,
+) []PublishResult {
+	var results []PublishResult
+
+	for _, payslip := range payroll.Payslips {
+		contact := contacts[payslip.WorkerID]
+
+		for _, publisher := range publishers {
+			result := PublishResult{
+				WorkerID: payslip.WorkerID,
+				Name:     payslip.Name,
+				Channel:  publisher.Channel(),
+				To:       contact.Address(publisher.Channel()),
+			}
+			if result.To == "" {
+				log.Warn().Msgf("no %s contact for worker: %s, skipping", publisher.Channel(), payslip.WorkerID)
+				result.Status = StatusSkipped
+				results = append(results, result)
+				continue
+			}
+
+			msg, err := templates.PayslipMessage(payslip, contact, publisher.Channel(), payslipPaths[payslip.WorkerID])
+			if err == nil {
+				result.Subject = msg.Subject
+				result.Attachments = msg.Attachments
+				err = publisher.Publish(ctx, msg)
+			}
+			if err != nil {
+				log.Error().Msgf("failed to publish payslip of worker: %s on: %s, error: %v",
+					payslip.WorkerID, publisher.Channel(), err)
+				result.Status = StatusFailed
+				result.Error = err.Error()
+				result.Err = err
+			} else {
+				log.Info().Msgf("Published payslip of worker: %s on: %s", payslip.WorkerID, publisher.Channel())
+				result.Status = StatusSent
+			}
+			results = append(results, result)
+		}
+	}
+
+	return results
+}
+
+// NewSendReport counts the publish results of a period
+func NewSendReport(period string, preview bool, results []PublishResult) *SendReport {
+	report := &SendReport{
+		Period:    period,
+		Preview:   preview,
+		CreatedAt: time.Now(),
+		Results:   results,
+	}
+
+	for _, result := range results {
+		switch result.Status {
+		case StatusSent:
+			report.Sent++
+		case StatusFailed:
+			report.Failed++
+		case StatusSkipped:
+			report.Skipped++
+		}
+	}
+
+	return report
+}
+
+// SaveSendReport saves the send report as JSON
+func SaveSendReport(report *SendReport, reportPath string) error {
+	content, err := json.MarshalIndent(report, "", "   ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal send report, error: %w", err)
+	}
+
+	if err := os.WriteFile(reportPath, content, 0o644); err != nil {
+		return fmt.Errorf("failed to save send report: %s, error: %w", reportPath, err)
+	}
+
+	return nil
+}
@@ -1,19 +0,0 @@
-module graphtutorial
-
-go 1.22.5
-
-require github.com/joho/godotenv v1.5.1
-
-require (
-	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.19 // indirect
-)
-
-require (
-	github.com/AzureAD/microsoft-authentication-library-for-go v1.2.2
-	github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/kylelemons/godebug v1.1.0 // indirect
-	github.com/rs/zerolog v1.33.0
-	golang.org/x/sys v0.25.0 // indirect
-)
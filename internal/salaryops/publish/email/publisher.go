@@ -0,0 +1,217 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/graph"
+	"github.com/vgeshiktor/bhops/internal/mail"
+	"github.com/vgeshiktor/bhops/internal/mail/smtp"
+	"github.com/vgeshiktor/bhops/internal/salaryops/publish"
+)
+
+// Mail backends
+const (
+	BackendGraph = "graph"
+	BackendSMTP  = "smtp"
+)
+
+// Environment variables of the secrets and the defaults of the options,
+// .env is loaded when present
+const (
+	ENV_TENANT_ID         = "GRAPH_TENANT_ID"
+	ENV_CLIENT_ID         = "GRAPH_CLIENT_ID"
+	ENV_CLIENT_SECRET     = "GRAPH_CLIENT_SECRET"
+	ENV_MAILBOX           = "GRAPH_MAILBOX"
+	ENV_TOKEN_CACHE_KEY   = "GRAPH_TOKEN_CACHE_KEY"
+	ENV_SMTP_ADDR         = "SMTP_ADDR"
+	ENV_SMTP_USERNAME     = "SMTP_USERNAME"
+	ENV_SMTP_PASSWORD     = "SMTP_PASSWORD"
+	ENV_SMTP_FROM         = "SMTP_FROM"
+	DEFAULT_BACKEND       = BackendGraph
+	DEFAULT_TOKEN_CACHE   = "config/graph_token_cache.bin"
+	DEFAULT_AUTH_FLOW     = graph.FlowClientCredentials
+	DEFAULT_SMTP_SECURITY = smtp.SecurityStartTLS
+	LEGACY_APPLICATION_ID = "APPLICATION_ID"
+	LEGACY_CLIENT_SECRET  = "CLIENT_SECRET"
+)
+
+// Options configures the mail backend sending the emails, the Graph
+// authentication and mailbox or the SMTP server. Empty options are taken
+// from the environment.
+type Options struct {
+	Backend string
+
+	AuthFlow       string
+	TenantID       string
+	ClientID       string
+	ClientSecret   string
+	Mailbox        string
+	TokenCachePath string
+	TokenCacheKey  string
+
+	// Scopes are the Graph scopes of the device code flow, Mail.Send by
+	// default
+	Scopes []string
+
+	SMTP smtp.Config
+}
+
+// MailSender sends mails, the Graph and SMTP clients are mail senders
+type MailSender interface {
+	SendMail(ctx context.Context, m mail.Mail) error
+}
+
+// Publisher sends messages as emails through a mail sender
+type Publisher struct {
+	sender MailSender
+}
+
+// NewPublisher creates an email publisher sending through the options
+// backend
+func NewPublisher(opts Options) (*Publisher, error) {
+	if err := godotenv.Load(); err != nil {
+		log.Debug().Msgf("no .env file loaded, error: %v", err)
+	}
+	opts.fromEnv()
+
+	var sender MailSender
+	var err error
+	switch opts.Backend {
+	case BackendGraph:
+		sender, err = newGraphClient(opts)
+	case BackendSMTP:
+		sender, err = smtp.NewClient(opts.SMTP)
+	default:
+		return nil, fmt.Errorf("invalid mail backend: %s, expected: %s or %s", opts.Backend, BackendGraph, BackendSMTP)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMailPublisher(sender), nil
+}
+
+// NewGraphClient creates a Graph client of the options mailbox, empty
+// options are taken from the environment
+func NewGraphClient(opts Options) (*graph.Client, error) {
+	if err := godotenv.Load(); err != nil {
+		log.Debug().Msgf("no .env file loaded, error: %v", err)
+	}
+	opts.fromEnv()
+
+	return newGraphClient(opts)
+}
+
+// newGraphClient creates a Graph client authenticated by the options flow.
+// The client credentials flow sends as the configured mailbox, the device
+// code flow as the signed in user. Tokens are kept in an encrypted cache
+// file when a cache key is set.
+func newGraphClient(opts Options) (*graph.Client, error) {
+	// app-only tokens have no signed in user
+	if opts.AuthFlow == graph.FlowClientCredentials && opts.Mailbox == "" {
+		return nil, fmt.Errorf("a mailbox must be set to use email with the %s flow", graph.FlowClientCredentials)
+	}
+
+	cfg := graph.AuthConfig{
+		Flow:         opts.AuthFlow,
+		TenantID:     opts.TenantID,
+		ClientID:     opts.ClientID,
+		ClientSecret: opts.ClientSecret,
+		Scopes:       opts.Scopes,
+	}
+	if opts.TokenCacheKey != "" {
+		tokenCache, err := graph.NewEncryptedFileCache(opts.TokenCachePath, opts.TokenCacheKey)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Cache = tokenCache
+	} else {
+		log.Warn().Msgf("%s is not set, tokens are not cached between runs", ENV_TOKEN_CACHE_KEY)
+	}
+
+	tokens, err := graph.NewTokenProvider(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create graph token provider, error: %w", err)
+	}
+
+	client := graph.NewClient(tokens)
+	client.Mailbox = opts.Mailbox
+
+	return client, nil
+}
+
+// fromEnv sets the empty options from the environment
+func (o *Options) fromEnv() {
+	setDefault := func(value *string, keys ...string) {
+		for _, key := range keys {
+			if *value == "" {
+				*value = os.Getenv(key)
+			}
+		}
+	}
+
+	setDefault(&o.TenantID, ENV_TENANT_ID)
+	setDefault(&o.ClientID, ENV_CLIENT_ID, LEGACY_APPLICATION_ID)
+	setDefault(&o.ClientSecret, ENV_CLIENT_SECRET, LEGACY_CLIENT_SECRET)
+	setDefault(&o.Mailbox, ENV_MAILBOX)
+	setDefault(&o.TokenCacheKey, ENV_TOKEN_CACHE_KEY)
+	setDefault(&o.SMTP.Addr, ENV_SMTP_ADDR)
+	setDefault(&o.SMTP.Username, ENV_SMTP_USERNAME)
+	setDefault(&o.SMTP.Password, ENV_SMTP_PASSWORD)
+	setDefault(&o.SMTP.From, ENV_SMTP_FROM)
+
+	if o.Backend == "" {
+		o.Backend = DEFAULT_BACKEND
+	}
+	if o.AuthFlow == "" {
+		o.AuthFlow = DEFAULT_AUTH_FLOW
+	}
+	if o.TokenCachePath == "" {
+		o.TokenCachePath = DEFAULT_TOKEN_CACHE
+	}
+	if o.SMTP.Security == "" {
+		o.SMTP.Security = DEFAULT_SMTP_SECURITY
+	}
+}
+
+// NewMailPublisher creates an email publisher sending through the mail
+// sender
+func NewMailPublisher(sender MailSender) *Publisher {
+	return &Publisher{sender: sender}
+}
+
+func (p *Publisher) Channel() string {
+	return publish.ChannelEmail
+}
+
+func (p *Publisher) Close() error {
+	return nil
+}
+
+// Publish sends the message, with its attachments, to the message address
+func (p *Publisher) Publish(ctx context.Context, msg publish.Message) error {
+	m := mail.Mail{
+		Subject:  msg.Subject,
+		TextBody: msg.Body,
+		HTMLBody: msg.HTMLBody,
+		To:       []string{msg.To},
+	}
+
+	for _, path := range msg.Attachments {
+		attachment, err := mail.AttachFile(path)
+		if err != nil {
+			return err
+		}
+		m.Attachments = append(m.Attachments, attachment)
+	}
+
+	if err := p.sender.SendMail(ctx, m); err != nil {
+		return fmt.Errorf("failed to send mail to: %s, error: %w", msg.To, err)
+	}
+
+	return nil
+}
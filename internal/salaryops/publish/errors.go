@@ -0,0 +1,45 @@
+package publish
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/vgeshiktor/bhops/internal/graph"
+	"github.com/vgeshiktor/bhops/internal/mail/smtp"
+)
+
+// retryDelayer is implemented by the errors of backends requesting a delay
+// before a retry, such as throttled Graph requests
+type retryDelayer interface {
+	RetryDelay() time.Duration
+}
+
+// IsTemporary reports whether a publish error may succeed when published
+// again later. Only the known transient failures are temporary: Graph and
+// SMTP errors reported as temporary by the server, and network timeouts.
+// Other errors are permanent.
+func IsTemporary(err error) bool {
+	var graphErr *graph.Error
+	if errors.As(err, &graphErr) {
+		return graphErr.Temporary()
+	}
+
+	var smtpErr *smtp.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Temporary()
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// RetryDelay returns the delay requested by the backend of a publish error
+// before a retry, zero when there is none
+func RetryDelay(err error) time.Duration {
+	var d retryDelayer
+	if errors.As(err, &d) {
+		return d.RetryDelay()
+	}
+	return 0
+}
@@ -0,0 +1,99 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/bhops/internal/graph"
+	"github.com/vgeshiktor/bhops/internal/mail/smtp"
+)
+
+// timeoutError is a network error of a timed out dial or read
+type timeoutError struct {
+	timeout bool
+}
+
+func (e *timeoutError) Error() string   { return "i/o timeout" }
+func (e *timeoutError) Timeout() bool   { return e.timeout }
+func (e *timeoutError) Temporary() bool { return true }
+
+func TestIsTemporary(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "throttled graph request",
+			err:  fmt.Errorf("failed to send mail, error: %w", &graph.Error{StatusCode: http.StatusTooManyRequests}),
+			want: true,
+		},
+		{
+			name: "graph server error",
+			err:  &graph.Error{StatusCode: http.StatusServiceUnavailable},
+			want: true,
+		},
+		{
+			name: "invalid graph recipient",
+			err:  &graph.Error{StatusCode: http.StatusBadRequest, Code: "ErrorInvalidRecipients"},
+		},
+		{
+			name: "smtp mailbox busy",
+			err:  fmt.Errorf("failed to send mail, error: %w", &smtp.Error{Code: 450, Message: "mailbox busy"}),
+			want: true,
+		},
+		{
+			name: "smtp mailbox unavailable",
+			err:  &smtp.Error{Code: 550, Message: "mailbox unavailable"},
+		},
+		{
+			name: "network timeout",
+			err:  &net.OpError{Op: "dial", Net: "tcp", Err: &timeoutError{timeout: true}},
+			want: true,
+		},
+		{
+			name: "network error without timeout",
+			err:  &net.OpError{Op: "dial", Net: "tcp", Err: &timeoutError{}},
+		},
+		{
+			name: "missing attachment",
+			err:  fmt.Errorf("failed to read payslip, error: %w", os.ErrNotExist),
+		},
+		{
+			name: "canceled",
+			err:  context.Canceled,
+		},
+		{
+			name: "unknown error",
+			err:  errors.New("unknown"),
+		},
+		{
+			name: "no error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTemporary(tt.err); got != tt.want {
+				t.Errorf("IsTemporary(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	err := fmt.Errorf("failed to send mail, error: %w",
+		&graph.Error{StatusCode: http.StatusTooManyRequests, RetryAfter: 30 * time.Second})
+	if got := RetryDelay(err); got != 30*time.Second {
+		t.Errorf("RetryDelay() = %s, want %s", got, 30*time.Second)
+	}
+	if got := RetryDelay(errors.New("unknown")); got != 0 {
+		t.Errorf("RetryDelay() of error without delay = %s, want 0", got)
+	}
+}
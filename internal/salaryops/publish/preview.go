@@ -0,0 +1,73 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PreviewPublisher renders the messages of a channel to files instead of
+// sending them, a message is saved as <dir>/<channel>/<worker id>.txt and,
+// when it has an HTML body, <worker id>.html
+type PreviewPublisher struct {
+	channel string
+	dir     string
+}
+
+func NewPreviewPublisher(channel, dir string) (*PreviewPublisher, error) {
+	channelDir := filepath.Join(dir, channel)
+	if err := os.MkdirAll(channelDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create preview dir: %s, error: %w", channelDir, err)
+	}
+	return &PreviewPublisher{channel: channel, dir: channelDir}, nil
+}
+
+func (p *PreviewPublisher) Channel() string {
+	return p.channel
+}
+
+func (p *PreviewPublisher) Close() error {
+	return nil
+}
+
+// Publish saves the message with its headers
+func (p *PreviewPublisher) Publish(ctx context.Context, msg Message) error {
+	var attachments []string
+	for _, path := range msg.Attachments {
+		attachments = append(attachments, filepath.Base(path))
+	}
+
+	text := fmt.Sprintf("To: %s\nSubject: %s\nAttachments: %s\n\n%s",
+		msg.To, msg.Subject, strings.Join(attachments, ", "), msg.Body)
+	textPath := filepath.Join(p.dir, msg.WorkerID+".txt")
+	if err := os.WriteFile(textPath, []byte(text), 0o644); err != nil {
+		return fmt.Errorf("failed to save preview: %s, error: %w", textPath, err)
+	}
+
+	if msg.HTMLBody == "" {
+		return nil
+	}
+
+	// headers are shown above the HTML body
+	headers := fmt.Sprintf("<pre>To: %s\nSubject: %s\nAttachments: %s</pre><hr>\n",
+		html.EscapeString(msg.To), html.EscapeString(msg.Subject), html.EscapeString(strings.Join(attachments, ", ")))
+	body := msg.HTMLBody
+	if i := strings.Index(strings.ToLower(body), "<body"); i >= 0 {
+		if end := strings.Index(body[i:], ">"); end >= 0 {
+			i += end + 1
+			body = body[:i] + "\n" + headers + body[i:]
+		}
+	} else {
+		body = headers + body
+	}
+
+	htmlPath := filepath.Join(p.dir, msg.WorkerID+".html")
+	if err := os.WriteFile(htmlPath, []byte(body), 0o644); err != nil {
+		return fmt.Errorf("failed to save preview: %s, error: %w", htmlPath, err)
+	}
+
+	return nil
+}
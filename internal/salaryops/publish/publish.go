@@ -0,0 +1,29 @@
+package publish
+
+import (
+	"context"
+)
+
+// Channels payslips are published on
+const (
+	ChannelEmail    = "email"
+	ChannelWhatsApp = "whatsapp"
+)
+
+// Message is a message published to a worker, channels supporting HTML
+// send the HTML body when set, instead of the text body
+type Message struct {
+	WorkerID    string
+	To          string
+	Subject     string
+	Body        string
+	HTMLBody    string
+	Attachments []string
+}
+
+// Publisher delivers messages on one channel
+type Publisher interface {
+	Channel() string
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
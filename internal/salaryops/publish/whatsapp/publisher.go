@@ -0,0 +1,164 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tebeka/selenium"
+	"github.com/tebeka/selenium/chrome"
+	"github.com/vgeshiktor/bhops/internal/salaryops/publish"
+)
+
+const (
+	ENV_CHROMEDRIVER = "CHROMEDRIVER_PATH"
+	DefaultPort      = 4444
+	DefaultLoginWait = 15 * time.Second
+
+	whatsAppURL    = "https://web.whatsapp.com"
+	elementTimeout = 30 * time.Second
+)
+
+// ErrNoChromeDriver is returned when no ChromeDriver path is configured
+var ErrNoChromeDriver = errors.New("ChromeDriver path is not set")
+
+// Publisher sends messages through WhatsApp Web, driven by ChromeDriver
+type Publisher struct {
+	service *selenium.Service
+	wd      selenium.WebDriver
+}
+
+// NewPublisher starts ChromeDriver, opens WhatsApp Web and waits for the
+// QR code to be scanned. An empty ChromeDriver path is read from
+// CHROMEDRIVER_PATH.
+func NewPublisher(chromeDriverPath string, port int, loginWait time.Duration) (*Publisher, error) {
+	if chromeDriverPath == "" {
+		chromeDriverPath = os.Getenv(ENV_CHROMEDRIVER)
+	}
+	if chromeDriverPath == "" {
+		return nil, fmt.Errorf("%w, set %s to the chromedriver executable", ErrNoChromeDriver, ENV_CHROMEDRIVER)
+	}
+
+	// Set up ChromeDriver
+	service, err := selenium.NewChromeDriverService(chromeDriverPath, port)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start ChromeDriver service: %s, error: %w", chromeDriverPath, err)
+	}
+
+	// configure the browser options
+	caps := selenium.Capabilities{}
+	caps.AddChrome(chrome.Capabilities{Args: []string{
+		//"--headless-new", // comment out this line for testing
+	}})
+
+	wd, err := selenium.NewRemote(caps, fmt.Sprintf("http://localhost:%d/wd/hub", port))
+	if err != nil {
+		service.Stop()
+		return nil, fmt.Errorf("failed to connect to WebDriver, error: %w", err)
+	}
+
+	p := &Publisher{service: service, wd: wd}
+
+	// Open WhatsApp Web
+	if err := wd.Get(whatsAppURL); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("failed to open WhatsApp Web, error: %w", err)
+	}
+
+	// Wait for manual QR code scan
+	log.Info().Msgf("Please scan the WhatsApp QR code within %s...", loginWait)
+	time.Sleep(loginWait)
+
+	return p, nil
+}
+
+func (p *Publisher) Channel() string {
+	return publish.ChannelWhatsApp
+}
+
+// Publish sends the message body and attachments to the message phone
+// number, in international format without the leading +
+func (p *Publisher) Publish(ctx context.Context, msg publish.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// open the chat of the phone number
+	phone := strings.TrimPrefix(msg.To, "+")
+	chatURL := whatsAppURL + "/send?phone=" + url.QueryEscape(phone) + "&text=" + url.QueryEscape(msg.Body)
+	if err := p.wd.Get(chatURL); err != nil {
+		return fmt.Errorf("failed to open chat: %s, error: %w", msg.To, err)
+	}
+
+	// send the prefilled message
+	sendButton, err := p.waitElement("span[data-icon='send']")
+	if err != nil {
+		return fmt.Errorf("failed to find send button of chat: %s, error: %w", msg.To, err)
+	}
+	if err := sendButton.Click(); err != nil {
+		return fmt.Errorf("failed to send message to: %s, error: %w", msg.To, err)
+	}
+
+	// send attachments as documents
+	for _, path := range msg.Attachments {
+		attachButton, err := p.waitElement("span[data-icon='plus']")
+		if err != nil {
+			return fmt.Errorf("failed to find attach button of chat: %s, error: %w", msg.To, err)
+		}
+		if err := attachButton.Click(); err != nil {
+			return fmt.Errorf("failed to open attach menu of chat: %s, error: %w", msg.To, err)
+		}
+
+		fileInput, err := p.waitElement("input[type='file']")
+		if err != nil {
+			return fmt.Errorf("failed to find file input of chat: %s, error: %w", msg.To, err)
+		}
+		if err := fileInput.SendKeys(path); err != nil {
+			return fmt.Errorf("failed to attach file: %s, error: %w", path, err)
+		}
+
+		sendButton, err := p.waitElement("span[data-icon='send']")
+		if err != nil {
+			return fmt.Errorf("failed to find send button of attachment: %s, error: %w", path, err)
+		}
+		if err := sendButton.Click(); err != nil {
+			return fmt.Errorf("failed to send attachment: %s, error: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// waitElement waits for an element of the page to be displayed
+func (p *Publisher) waitElement(selector string) (selenium.WebElement, error) {
+	var element selenium.WebElement
+	err := p.wd.WaitWithTimeout(func(wd selenium.WebDriver) (bool, error) {
+		elem, err := wd.FindElement(selenium.ByCSSSelector, selector)
+		if err != nil {
+			return false, nil
+		}
+		element = elem
+		return elem.IsDisplayed()
+	}, elementTimeout)
+
+	return element, err
+}
+
+// Close quits the browser and stops ChromeDriver
+func (p *Publisher) Close() error {
+	var err error
+	if p.wd != nil {
+		err = p.wd.Quit()
+	}
+	if p.service != nil {
+		if stopErr := p.service.Stop(); err == nil {
+			err = stopErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,204 @@
+package salaryops
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/vgeshiktor/bhops/internal/attendanceops"
+)
+
+// Template files of the payslip messages
+const (
+	SubjectTemplate = "subject.txt"
+	TextTemplate    = "body.txt"
+	HTMLTemplate    = "body.html"
+)
+
+//go:embed templates
+var defaultTemplates embed.FS
+
+// TemplateData is the data of the payslip message templates, amounts are
+// formatted as in the payslip
+type TemplateData struct {
+	Name     string
+	Period   string
+	Gross    string
+	Language string
+	Dir      string
+	Lines    []TemplateLine
+	Labels   attendanceops.Labels
+	Payslip  Payslip
+	Worker   attendanceops.Worker
+}
+
+// TemplateLine is a pay line of the templates
+type TemplateLine struct {
+	Name     string
+	Quantity string
+	Rate     string
+	Amount   string
+}
+
+// Templates renders the payslip messages of a worker in the worker's
+// language. A template is looked up in the templates dir as
+// <lang>/<file>, then <file>, falling back to the built-in template.
+type Templates struct {
+	dir string
+
+	mu        sync.Mutex
+	languages map[attendanceops.Language]*languageTemplates
+}
+
+type languageTemplates struct {
+	subject *template.Template
+	text    *template.Template
+	html    *htmltemplate.Template
+}
+
+var templateFuncs = map[string]any{
+	"splitLines": func(s string) []string {
+		return strings.Split(s, "\n")
+	},
+}
+
+// LoadTemplates loads the templates of the dir, without a dir the built-in
+// templates are used. The templates of all languages are parsed, so errors
+// are reported before any message is sent.
+func LoadTemplates(dir string) (*Templates, error) {
+	t := &Templates{dir: dir, languages: map[attendanceops.Language]*languageTemplates{}}
+
+	for _, lang := range attendanceops.Languages() {
+		if _, err := t.language(lang); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// Render renders the subject, text and HTML bodies of a payslip message
+// in the language
+func (t *Templates) Render(payslip Payslip, lang attendanceops.Language) (string, string, string, error) {
+	templates, err := t.language(lang)
+	if err != nil {
+		return "", "", "", err
+	}
+	data := newTemplateData(payslip, lang)
+
+	var subject, text, html bytes.Buffer
+	if err := templates.subject.Execute(&subject, data); err != nil {
+		return "", "", "", fmt.Errorf("failed to render subject of worker: %s, error: %w", payslip.WorkerID, err)
+	}
+	if err := templates.text.Execute(&text, data); err != nil {
+		return "", "", "", fmt.Errorf("failed to render text body of worker: %s, error: %w", payslip.WorkerID, err)
+	}
+	if err := templates.html.Execute(&html, data); err != nil {
+		return "", "", "", fmt.Errorf("failed to render HTML body of worker: %s, error: %w", payslip.WorkerID, err)
+	}
+
+	// subjects are a single line
+	return strings.Join(strings.Fields(subject.String()), " "), text.String(), html.String(), nil
+}
+
+// language returns the parsed templates of a language
+func (t *Templates) language(lang attendanceops.Language) (*languageTemplates, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if templates, ok := t.languages[lang]; ok {
+		return templates, nil
+	}
+
+	templates := &languageTemplates{}
+	for _, name := range []string{SubjectTemplate, TextTemplate, HTMLTemplate} {
+		content, path, err := t.readTemplate(lang, name)
+		if err != nil {
+			return nil, err
+		}
+
+		switch name {
+		case SubjectTemplate:
+			templates.subject, err = template.New(path).Funcs(templateFuncs).Parse(content)
+		case TextTemplate:
+			templates.text, err = template.New(path).Funcs(templateFuncs).Parse(content)
+		case HTMLTemplate:
+			templates.html, err = htmltemplate.New(path).Funcs(templateFuncs).Parse(content)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template: %s, error: %w", path, err)
+		}
+	}
+	t.languages[lang] = templates
+
+	return templates, nil
+}
+
+// readTemplate reads the template file of the language, and returns its
+// content and path
+func (t *Templates) readTemplate(lang attendanceops.Language, name string) (string, string, error) {
+	if t.dir != "" {
+		for _, path := range []string{filepath.Join(t.dir, string(lang), name), filepath.Join(t.dir, name)} {
+			content, err := os.ReadFile(path)
+			if err == nil {
+				return string(content), path, nil
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return "", "", fmt.Errorf("failed to read template: %s, error: %w", path, err)
+			}
+		}
+	}
+
+	path := "templates/" + name
+	content, err := defaultTemplates.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read built-in template: %s, error: %w", path, err)
+	}
+
+	return string(content), path, nil
+}
+
+func newTemplateData(payslip Payslip, lang attendanceops.Language) TemplateData {
+	labels := lang.Labels()
+
+	data := TemplateData{
+		Name:     payslip.Name,
+		Period:   payslip.Period,
+		Gross:    formatAmount(payslip.Gross),
+		Language: string(lang),
+		Dir:      "ltr",
+		Labels:   labels,
+		Payslip:  payslip,
+		Worker:   payslip.Worker,
+	}
+	if lang.RightToLeft() {
+		data.Dir = "rtl"
+	}
+
+	for _, line := range payslip.Lines {
+		templateLine := TemplateLine{
+			Name:   lineName(labels, line.Kind),
+			Amount: formatAmount(line.Amount),
+		}
+		if line.Quantity != 0 {
+			templateLine.Quantity = formatAmount(line.Quantity)
+			templateLine.Rate = formatAmount(line.Rate)
+		}
+		data.Lines = append(data.Lines, templateLine)
+	}
+
+	return data
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 0, 64)
+}
@@ -0,0 +1,122 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	// embed the time zone database, the scheduler runs on hosts without one
+	_ "time/tzdata"
+)
+
+const (
+	DefaultLocation = "Asia/Jerusalem"
+	DATE_LAYOUT     = "2006-01-02"
+)
+
+// Calendar holds the business days of the scheduler, days are evaluated in
+// the calendar location
+type Calendar struct {
+	Location *time.Location
+	Weekend  map[time.Weekday]bool
+	Holidays map[string]string
+}
+
+// calendarFile is the JSON layout of a calendar file
+type calendarFile struct {
+	Location string            `json:"location"`
+	Weekend  []string          `json:"weekend"`
+	Holidays map[string]string `json:"holidays"`
+}
+
+// DefaultCalendar returns the Israeli calendar without holidays, Shabbat
+// is the only weekend day
+func DefaultCalendar() *Calendar {
+	location, err := time.LoadLocation(DefaultLocation)
+	if err != nil {
+		panic(err)
+	}
+
+	return &Calendar{
+		Location: location,
+		Weekend:  map[time.Weekday]bool{time.Saturday: true},
+		Holidays: map[string]string{},
+	}
+}
+
+// LoadCalendar reads a calendar file, holidays are keyed by their
+// YYYY-MM-DD date
+func LoadCalendar(calendarPath string) (*Calendar, error) {
+	calendar := DefaultCalendar()
+
+	content, err := os.ReadFile(calendarPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read calendar file: %s, error: %w", calendarPath, err)
+	}
+
+	var file calendarFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal calendar file: %s, error: %w", calendarPath, err)
+	}
+
+	if file.Location != "" {
+		location, err := time.LoadLocation(file.Location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid calendar location: %s, error: %w", file.Location, err)
+		}
+		calendar.Location = location
+	}
+
+	if len(file.Weekend) > 0 {
+		calendar.Weekend = map[time.Weekday]bool{}
+		for _, name := range file.Weekend {
+			day, ok := parseWeekday(name)
+			if !ok {
+				return nil, fmt.Errorf("invalid weekend day: %s in calendar file: %s", name, calendarPath)
+			}
+			calendar.Weekend[day] = true
+		}
+	}
+
+	for date, name := range file.Holidays {
+		if _, err := time.Parse(DATE_LAYOUT, date); err != nil {
+			return nil, fmt.Errorf("invalid date: %s of holiday: %s, expected YYYY-MM-DD", date, name)
+		}
+		calendar.Holidays[date] = name
+	}
+
+	return calendar, nil
+}
+
+// IsBusinessDay reports whether the day of t is neither a weekend day nor
+// a holiday
+func (c *Calendar) IsBusinessDay(t time.Time) bool {
+	t = t.In(c.Location)
+	if c.Weekend[t.Weekday()] {
+		return false
+	}
+	_, holiday := c.Holidays[t.Format(DATE_LAYOUT)]
+	return !holiday
+}
+
+// LastBusinessDay returns the midnight of the last business day of the
+// month
+func (c *Calendar) LastBusinessDay(year int, month time.Month) time.Time {
+	// start from the last day of the month and walk back
+	day := time.Date(year, month+1, 0, 0, 0, 0, 0, c.Location)
+	for !c.IsBusinessDay(day) && day.Month() == month {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) || strings.EqualFold(day.String()[:3], name) {
+			return day, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,197 @@
+package schedule
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadTestCalendar loads the Israeli holiday table of the config dir
+func loadTestCalendar(t *testing.T) *Calendar {
+	t.Helper()
+
+	calendar, err := LoadCalendar(filepath.Join("config", "calendar.json"))
+	if err != nil {
+		t.Fatalf("LoadCalendar() error = %v", err)
+	}
+	return calendar
+}
+
+func writeCalendar(t *testing.T, content string) string {
+	t.Helper()
+
+	calendarPath := filepath.Join(t.TempDir(), "calendar.json")
+	if err := os.WriteFile(calendarPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return calendarPath
+}
+
+func TestIsBusinessDay(t *testing.T) {
+	calendar := loadTestCalendar(t)
+
+	tests := []struct {
+		date string
+		want bool
+	}{
+		{"2026-04-01", true},  // erev Pesach
+		{"2026-04-02", false}, // Pesach
+		{"2026-04-03", true},  // chol hamoed, friday
+		{"2026-04-04", false}, // shabbat
+		{"2026-04-08", false}, // Pesach VII
+		{"2026-04-22", false}, // Yom HaAtzmaut
+		{"2026-05-22", false}, // Shavuot
+		{"2026-09-12", false}, // Rosh Hashana I, shabbat
+		{"2026-09-13", false}, // Rosh Hashana II
+		{"2026-09-21", false}, // Yom Kippur
+		{"2026-10-03", false}, // Shemini Atzeret
+		{"2026-10-04", true},
+		{"2025-04-13", false}, // Pesach
+		{"2027-06-11", false}, // Shavuot
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.date, func(t *testing.T) {
+			day, err := time.ParseInLocation(DATE_LAYOUT, tt.date, calendar.Location)
+			if err != nil {
+				t.Fatalf("ParseInLocation() error = %v", err)
+			}
+			if got := calendar.IsBusinessDay(day); got != tt.want {
+				t.Errorf("IsBusinessDay(%s) = %v, want %v", tt.date, got, tt.want)
+			}
+
+			// days are evaluated in the calendar location, the previous day
+			// in UTC is already the day in Jerusalem
+			utc := day.Add(30 * time.Minute).UTC()
+			if got := calendar.IsBusinessDay(utc); got != tt.want {
+				t.Errorf("IsBusinessDay(%s) = %v, want %v", utc, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLastBusinessDay(t *testing.T) {
+	tests := []struct {
+		name     string
+		holidays string
+		year     int
+		month    time.Month
+		want     string
+	}{
+		{
+			name:  "last day is a business day",
+			year:  2026,
+			month: time.April,
+			want:  "2026-04-30",
+		},
+		{
+			name:  "last day on shabbat",
+			year:  2026,
+			month: time.January,
+			want:  "2026-01-30",
+		},
+		{
+			name:  "last day on shabbat after the clock change",
+			year:  2026,
+			month: time.October,
+			want:  "2026-10-30",
+		},
+		{
+			name:     "last day on a holiday",
+			holidays: `{"2026-03-31": "holiday"}`,
+			year:     2026,
+			month:    time.March,
+			want:     "2026-03-30",
+		},
+		{
+			name:     "last day on a holiday after shabbat",
+			holidays: `{"2026-05-31": "holiday"}`,
+			year:     2026,
+			month:    time.May,
+			want:     "2026-05-29",
+		},
+		{
+			name:     "last days on holidays before and after shabbat",
+			holidays: `{"2026-05-29": "holiday", "2026-05-31": "holiday"}`,
+			year:     2026,
+			month:    time.May,
+			want:     "2026-05-28",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calendar := loadTestCalendar(t)
+			if tt.holidays != "" {
+				var err error
+				calendarPath := writeCalendar(t, `{"holidays": `+tt.holidays+`}`)
+				if calendar, err = LoadCalendar(calendarPath); err != nil {
+					t.Fatalf("LoadCalendar() error = %v", err)
+				}
+			}
+
+			got := calendar.LastBusinessDay(tt.year, tt.month)
+			if got.Format(DATE_LAYOUT) != tt.want || got.Hour() != 0 || got.Location() != calendar.Location {
+				t.Errorf("LastBusinessDay(%d, %s) = %s, want midnight of %s in %s",
+					tt.year, tt.month, got, tt.want, calendar.Location)
+			}
+		})
+	}
+}
+
+func TestLoadCalendar(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "friday and saturday weekend",
+			content: `{"location": "UTC", "weekend": ["fri", "Saturday"]}`,
+		},
+		{
+			name:    "invalid location",
+			content: `{"location": "Middle/Earth"}`,
+			wantErr: "invalid calendar location",
+		},
+		{
+			name:    "invalid weekend day",
+			content: `{"weekend": ["shabbat"]}`,
+			wantErr: "invalid weekend day",
+		},
+		{
+			name:    "invalid holiday date",
+			content: `{"holidays": {"02/04/2026": "Pesach"}}`,
+			wantErr: "invalid date",
+		},
+		{
+			name:    "invalid json",
+			content: `{"holidays": [`,
+			wantErr: "failed to unmarshal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calendar, err := LoadCalendar(writeCalendar(t, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadCalendar() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadCalendar() error = %v", err)
+			}
+
+			friday := time.Date(2026, time.January, 2, 12, 0, 0, 0, time.UTC)
+			if calendar.Location != time.UTC || calendar.IsBusinessDay(friday) ||
+				calendar.IsBusinessDay(friday.AddDate(0, 0, 1)) || !calendar.IsBusinessDay(friday.AddDate(0, 0, 2)) {
+				t.Errorf("calendar = %s weekend: %v, want UTC with friday and saturday weekend",
+					calendar.Location, calendar.Weekend)
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/jobs"
+	"github.com/vgeshiktor/bhops/internal/queue"
+)
+
+// DefaultCheckInterval is how often the scheduler checks for due rules
+const DefaultCheckInterval = 30 * time.Second
+
+const PERIOD_LAYOUT = "2006-01"
+
+// Rule enqueues a typed job on a calendar spec. The payload is a template
+// of the job JSON payload, rendered with the run PayloadData.
+type Rule struct {
+	Name    string          `json:"name"`
+	When    string          `json:"when"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// PayloadData is the data of the payload templates, periods are YYYY-MM
+type PayloadData struct {
+	Date           string
+	Period         string
+	PreviousPeriod string
+}
+
+// RuleState is the persisted state of a rule. CheckedAt is the time the
+// rule was last evaluated, runs missed while the scheduler was down are
+// caught up once on the next start.
+type RuleState struct {
+	CheckedAt   time.Time `json:"checked_at"`
+	LastRunAt   time.Time `json:"last_run_at"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	LastJobID   string    `json:"last_job_id,omitempty"`
+}
+
+// RuleStatus is a rule with its last and next runs
+type RuleStatus struct {
+	Rule
+	RuleState
+	NextRunAt time.Time
+}
+
+// LoadRules reads a JSON array of rules
+func LoadRules(rulesPath string) ([]Rule, error) {
+	content, err := os.ReadFile(rulesPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schedule file: %s, error: %w", rulesPath, err)
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(content, &rules); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal schedule file: %s, error: %w", rulesPath, err)
+	}
+
+	return rules, nil
+}
+
+// Scheduler enqueues the jobs of its rules into the queue when they are due
+type Scheduler struct {
+	// CheckInterval is how often due rules are checked
+	CheckInterval time.Duration
+
+	calendar  *Calendar
+	queue     queue.Queue
+	statePath string
+	rules     []Rule
+	specs     map[string]Spec
+
+	mu    sync.Mutex
+	state map[string]RuleState
+}
+
+// NewScheduler parses the rules and loads their state, rules seen for the
+// first time start from now
+func NewScheduler(rules []Rule, calendar *Calendar, q queue.Queue, statePath string) (*Scheduler, error) {
+	s := &Scheduler{
+		CheckInterval: DefaultCheckInterval,
+		calendar:      calendar,
+		queue:         q,
+		statePath:     statePath,
+		rules:         rules,
+		specs:         map[string]Spec{},
+		state:         map[string]RuleState{},
+	}
+
+	for _, rule := range rules {
+		if rule.Name == "" || rule.Type == "" {
+			return nil, fmt.Errorf("schedule rule: %q has no name or job type", rule.Name)
+		}
+		if _, ok := s.specs[rule.Name]; ok {
+			return nil, fmt.Errorf("duplicate schedule rule: %s", rule.Name)
+		}
+		if _, err := template.New(rule.Name).Parse(string(rule.Payload)); err != nil {
+			return nil, fmt.Errorf("invalid payload of schedule rule: %s, error: %w", rule.Name, err)
+		}
+		spec, err := ParseSpec(rule.When, calendar)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule rule: %s, error: %w", rule.Name, err)
+		}
+		s.specs[rule.Name] = spec
+	}
+
+	if err := s.loadState(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Run checks the rules every CheckInterval until the context is canceled
+func (s *Scheduler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RunDue(ctx, time.Now()); err != nil {
+			log.Error().Msgf("failed to run schedule, error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue enqueues the jobs of the rules due at the given time and saves
+// the rules state
+func (s *Scheduler) RunDue(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, rule := range s.rules {
+		state := s.ruleState(rule.Name, now)
+
+		scheduledAt := s.specs[rule.Name].Next(state.CheckedAt)
+		if scheduledAt.IsZero() || scheduledAt.After(now) {
+			s.state[rule.Name] = state
+			continue
+		}
+
+		jobID, err := s.enqueue(ctx, rule, scheduledAt)
+		if err != nil {
+			// keep the rule due, it is retried on the next check
+			errs = append(errs, err)
+			continue
+		}
+
+		log.Info().Msgf("Scheduled job: %s of type: %s by rule: %s, scheduled at: %s",
+			jobID, rule.Type, rule.Name, scheduledAt.Format(time.RFC3339))
+
+		s.state[rule.Name] = RuleState{
+			CheckedAt:   now,
+			LastRunAt:   now,
+			ScheduledAt: scheduledAt,
+			LastJobID:   jobID,
+		}
+	}
+
+	if err := s.saveState(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Status returns the rules with their last and next runs, in the calendar
+// location
+func (s *Scheduler) Status(now time.Time) []RuleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]RuleStatus, 0, len(s.rules))
+	for _, rule := range s.rules {
+		state := s.ruleState(rule.Name, now)
+		state.LastRunAt = state.LastRunAt.In(s.calendar.Location)
+		statuses = append(statuses, RuleStatus{
+			Rule:      rule,
+			RuleState: state,
+			NextRunAt: s.specs[rule.Name].Next(state.CheckedAt),
+		})
+	}
+
+	return statuses
+}
+
+// ruleState returns the state of a rule, rules without state start now
+func (s *Scheduler) ruleState(name string, now time.Time) RuleState {
+	state, ok := s.state[name]
+	if !ok {
+		state.CheckedAt = now
+	}
+	return state
+}
+
+// enqueue adds the job of the rule for the scheduled time. The idempotency
+// key names the run of the schedule time: a job enqueued again after a
+// failed state save is skipped only for effectively-once job types, such
+// as send_payslip. At-least-once types, such as build_attendance_report
+// and generate_payslips, run again and save the same outputs.
+func (s *Scheduler) enqueue(ctx context.Context, rule Rule, scheduledAt time.Time) (string, error) {
+	payload, err := s.renderPayload(rule, scheduledAt)
+	if err != nil {
+		return "", err
+	}
+
+	env, err := jobs.NewEnvelope(rule.Type, nil, "schedule:"+rule.Name)
+	if err != nil {
+		return "", err
+	}
+	env.Payload = payload
+	env.IdempotencyKey = fmt.Sprintf("schedule:%s:%s", rule.Name, scheduledAt.UTC().Format(time.RFC3339))
+
+	if _, err := jobs.Enqueue(ctx, s.queue, env); err != nil {
+		return "", fmt.Errorf("failed to enqueue job of schedule rule: %s, error: %w", rule.Name, err)
+	}
+
+	return env.ID, nil
+}
+
+// renderPayload renders the payload template of the rule with the periods
+// of the scheduled time
+func (s *Scheduler) renderPayload(rule Rule, scheduledAt time.Time) (json.RawMessage, error) {
+	if len(rule.Payload) == 0 {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(rule.Name).Parse(string(rule.Payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse payload of schedule rule: %s, error: %w", rule.Name, err)
+	}
+
+	scheduledAt = scheduledAt.In(s.calendar.Location)
+	month := time.Date(scheduledAt.Year(), scheduledAt.Month(), 1, 0, 0, 0, 0, s.calendar.Location)
+	data := PayloadData{
+		Date:           scheduledAt.Format(DATE_LAYOUT),
+		Period:         month.Format(PERIOD_LAYOUT),
+		PreviousPeriod: month.AddDate(0, -1, 0).Format(PERIOD_LAYOUT),
+	}
+
+	var payload bytes.Buffer
+	if err := tmpl.Execute(&payload, data); err != nil {
+		return nil, fmt.Errorf("failed to render payload of schedule rule: %s, error: %w", rule.Name, err)
+	}
+	if !json.Valid(payload.Bytes()) {
+		return nil, fmt.Errorf("invalid payload JSON of schedule rule: %s: %s", rule.Name, payload.String())
+	}
+
+	return payload.Bytes(), nil
+}
+
+func (s *Scheduler) loadState() error {
+	content, err := os.ReadFile(s.statePath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if err := os.MkdirAll(filepath.Dir(s.statePath), 0o755); err != nil {
+			return fmt.Errorf("failed to create schedule state dir: %s, error: %w", filepath.Dir(s.statePath), err)
+		}
+	case err != nil:
+		return fmt.Errorf("failed to read schedule state file: %s, error: %w", s.statePath, err)
+	default:
+		if err := json.Unmarshal(content, &s.state); err != nil {
+			return fmt.Errorf("failed to unmarshal schedule state file: %s, error: %w", s.statePath, err)
+		}
+	}
+
+	return nil
+}
+
+// saveState writes the rules state, called with the scheduler lock held
+func (s *Scheduler) saveState() error {
+	content, err := json.MarshalIndent(s.state, "", "   ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal schedule state, error: %w", err)
+	}
+
+	tmpPath := s.statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write schedule state file: %s, error: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, s.statePath); err != nil {
+		return fmt.Errorf("failed to rename schedule state file: %s, error: %w", tmpPath, err)
+	}
+
+	return nil
+}
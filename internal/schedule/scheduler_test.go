@@ -0,0 +1,173 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/bhops/internal/jobs"
+	"github.com/vgeshiktor/bhops/internal/queue"
+)
+
+// newTestScheduler creates a scheduler of the rules on the Israeli
+// calendar, keeping its state in the state file
+func newTestScheduler(t *testing.T, rules []Rule, q queue.Queue, statePath string) *Scheduler {
+	t.Helper()
+
+	scheduler, err := NewScheduler(rules, loadTestCalendar(t), q, statePath)
+	if err != nil {
+		t.Fatalf("NewScheduler() error = %v", err)
+	}
+	return scheduler
+}
+
+// runDue runs the scheduler at the time and returns the enqueued jobs
+func runDue(t *testing.T, scheduler *Scheduler, q *queue.MemoryQueue, now time.Time) []jobs.Envelope {
+	t.Helper()
+
+	if err := scheduler.RunDue(context.Background(), now); err != nil {
+		t.Fatalf("RunDue() error = %v", err)
+	}
+
+	msgs, err := q.Fetch(context.Background(), 100, time.Minute)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	var envs []jobs.Envelope
+	for _, msg := range msgs {
+		env, err := jobs.DecodeEnvelope(msg.Body)
+		if err != nil {
+			t.Fatalf("DecodeEnvelope() error = %v", err)
+		}
+		if err := q.Ack(context.Background(), msg); err != nil {
+			t.Fatalf("Ack() error = %v", err)
+		}
+		envs = append(envs, env)
+	}
+	return envs
+}
+
+func TestSchedulerRunDue(t *testing.T) {
+	calendar := loadTestCalendar(t)
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, calendar.Location)
+	}
+	rules := []Rule{
+		{
+			Name:    "close_month",
+			When:    "@last_business_day 18:00",
+			Type:    jobs.TypeBuildReport,
+			Payload: json.RawMessage(`{"period": "{{.Period}}"}`),
+		},
+		{
+			Name:    "nightly",
+			When:    "30 2 * * *",
+			Type:    jobs.TypeGeneratePayslips,
+			Payload: json.RawMessage(`{"period": "{{.PreviousPeriod}}"}`),
+		},
+	}
+
+	type run struct {
+		rule   string
+		period string
+		key    string
+	}
+	tests := []struct {
+		name string
+
+		// now is the time of the check, restart creates the scheduler again
+		// from its state file before the check
+		now     time.Time
+		restart bool
+		want    []run
+	}{
+		{
+			name: "first start",
+			now:  at(2026, time.January, 29, 12, 0),
+		},
+		{
+			name: "nightly",
+			now:  at(2026, time.January, 30, 2, 30),
+			want: []run{
+				{"nightly", "2025-12", "schedule:nightly:2026-01-30T00:30:00Z"},
+			},
+		},
+		{
+			name: "last business day before shabbat",
+			now:  at(2026, time.January, 30, 18, 0),
+			want: []run{
+				{"close_month", "2026-01", "schedule:close_month:2026-01-30T16:00:00Z"},
+			},
+		},
+		{
+			name: "checked again",
+			now:  at(2026, time.January, 30, 18, 1),
+		},
+		{
+			// down for two months, each rule catches up its first missed
+			// run once
+			name:    "restart after missed runs",
+			now:     at(2026, time.March, 26, 12, 0),
+			restart: true,
+			want: []run{
+				{"close_month", "2026-02", "schedule:close_month:2026-02-27T16:00:00Z"},
+				{"nightly", "2025-12", "schedule:nightly:2026-01-31T00:30:00Z"},
+			},
+		},
+		{
+			name:    "restart after catching up",
+			now:     at(2026, time.March, 26, 12, 1),
+			restart: true,
+		},
+		{
+			// the clock moves from 02:00 to 03:00, the 02:30 run is at 03:30
+			name: "daylight saving time starts",
+			now:  at(2026, time.March, 27, 3, 30),
+			want: []run{
+				{"nightly", "2026-02", "schedule:nightly:2026-03-27T00:30:00Z"},
+			},
+		},
+		{
+			name: "last business day in daylight saving time",
+			now:  at(2026, time.March, 31, 18, 0),
+			want: []run{
+				{"close_month", "2026-03", "schedule:close_month:2026-03-31T15:00:00Z"},
+				{"nightly", "2026-02", "schedule:nightly:2026-03-27T23:30:00Z"},
+			},
+		},
+	}
+
+	q := queue.NewMemoryQueue()
+	t.Cleanup(func() { q.Close() })
+	statePath := filepath.Join(t.TempDir(), "queue", "schedule_state.json")
+	scheduler := newTestScheduler(t, rules, q, statePath)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.restart {
+				scheduler = newTestScheduler(t, rules, q, statePath)
+			}
+
+			envs := runDue(t, scheduler, q, tt.now)
+			if len(envs) != len(tt.want) {
+				t.Fatalf("RunDue(%s) enqueued %d jobs, want %d", tt.now, len(envs), len(tt.want))
+			}
+			for i, env := range envs {
+				var payload jobs.BuildReportPayload
+				if err := env.DecodePayload(&payload); err != nil {
+					t.Fatalf("DecodePayload() error = %v", err)
+				}
+				want := tt.want[i]
+				if env.CorrelationID != "schedule:"+want.rule || payload.Period != want.period ||
+					env.IdempotencyKey != want.key {
+					t.Errorf("job = %s period: %s key: %s, want %s period: %s key: %s",
+						env.CorrelationID, payload.Period, env.IdempotencyKey,
+						"schedule:"+want.rule, want.period, want.key)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LAST_BUSINESS_DAY is the spec of rules running on the last business day
+// of every month, followed by the HH:MM time of day
+const LAST_BUSINESS_DAY = "@last_business_day"
+
+// maxSearchDays bounds the search of the next run of specs that never match,
+// such as the 31st of February
+const maxSearchDays = 5 * 366
+
+// Spec returns the next run time of a rule
+type Spec interface {
+	// Next returns the first run time after the given time, or the zero
+	// time when the spec never runs
+	Next(after time.Time) time.Time
+}
+
+// ParseSpec parses a rule spec, either a five fields cron expression,
+// "minute hour day-of-month month day-of-week", or "@last_business_day HH:MM".
+// Times are evaluated in the calendar location.
+func ParseSpec(spec string, calendar *Calendar) (Spec, error) {
+	fields := strings.Fields(spec)
+
+	if len(fields) > 0 && fields[0] == LAST_BUSINESS_DAY {
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid spec: %s, expected: %s HH:MM", spec, LAST_BUSINESS_DAY)
+		}
+		at, err := time.Parse("15:04", fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid time of day: %s in spec: %s, expected HH:MM", fields[1], spec)
+		}
+		return &lastBusinessDaySpec{calendar: calendar, hour: at.Hour(), minute: at.Minute()}, nil
+	}
+
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid spec: %s, expected 5 cron fields or %s HH:MM", spec, LAST_BUSINESS_DAY)
+	}
+
+	ranges := []struct {
+		name     string
+		min, max int
+	}{
+		{"minute", 0, 59},
+		{"hour", 0, 23},
+		{"day of month", 1, 31},
+		{"month", 1, 12},
+		{"day of week", 0, 7},
+	}
+
+	cron := &cronSpec{location: calendar.Location}
+	sets := []*uint64{&cron.minutes, &cron.hours, &cron.days, &cron.months, &cron.weekdays}
+	for i, field := range fields {
+		set, err := parseField(field, ranges[i].min, ranges[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s in spec: %s, error: %w", ranges[i].name, field, spec, err)
+		}
+		*sets[i] = set
+	}
+
+	// 7 is sunday as well
+	if cron.weekdays&(1<<7) != 0 {
+		cron.weekdays |= 1
+	}
+	cron.anyDay = fields[2] == "*"
+	cron.anyWeekday = fields[4] == "*"
+
+	return cron, nil
+}
+
+// parseField parses a comma separated list of values, ranges and steps,
+// such as "*", "*/15", "1-5" or "1,15", into a bit set
+func parseField(field string, min, max int) (uint64, error) {
+	var set uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rng = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step: %s", part[i+1:])
+			}
+		}
+
+		low, high := min, max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value: %s", bounds[0])
+			}
+			if high, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, fmt.Errorf("invalid value: %s", bounds[1])
+			}
+		default:
+			value, err := strconv.Atoi(rng)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value: %s", rng)
+			}
+			low, high = value, value
+			if strings.Contains(part, "/") {
+				high = max
+			}
+		}
+
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("value out of range: %s, expected %d-%d", rng, min, max)
+		}
+		for value := low; value <= high; value += step {
+			set |= 1 << value
+		}
+	}
+
+	return set, nil
+}
+
+// cronSpec runs on the minutes matching all of its fields. As in cron, when
+// both the day of month and the day of week are restricted, a day matching
+// either of them runs.
+type cronSpec struct {
+	location *time.Location
+
+	minutes, hours, days, months, weekdays uint64
+	anyDay, anyWeekday                     bool
+}
+
+func (s *cronSpec) Next(after time.Time) time.Time {
+	after = after.In(s.location)
+	day := time.Date(after.Year(), after.Month(), after.Day(), 0, 0, 0, 0, s.location)
+
+	for i := 0; i < maxSearchDays; i, day = i+1, day.AddDate(0, 0, 1) {
+		if !s.matchDay(day) {
+			continue
+		}
+		for hour := 0; hour < 24; hour++ {
+			if s.hours&(1<<hour) == 0 {
+				continue
+			}
+			for minute := 0; minute < 60; minute++ {
+				if s.minutes&(1<<minute) == 0 {
+					continue
+				}
+				t := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, s.location)
+				if t.After(after) {
+					return t
+				}
+			}
+		}
+	}
+
+	return time.Time{}
+}
+
+func (s *cronSpec) matchDay(day time.Time) bool {
+	if s.months&(1<<int(day.Month())) == 0 {
+		return false
+	}
+
+	dayMatch := s.days&(1<<day.Day()) != 0
+	weekdayMatch := s.weekdays&(1<<int(day.Weekday())) != 0
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekdayMatch
+	case s.anyWeekday:
+		return dayMatch
+	default:
+		return dayMatch || weekdayMatch
+	}
+}
+
+// lastBusinessDaySpec runs at a time of day on the last business day of
+// every month
+type lastBusinessDaySpec struct {
+	calendar     *Calendar
+	hour, minute int
+}
+
+func (s *lastBusinessDaySpec) Next(after time.Time) time.Time {
+	after = after.In(s.calendar.Location)
+
+	for i := 0; i < 2; i++ {
+		month := time.Date(after.Year(), after.Month()+time.Month(i), 1, 0, 0, 0, 0, s.calendar.Location)
+		day := s.calendar.LastBusinessDay(month.Year(), month.Month())
+		t := time.Date(day.Year(), day.Month(), day.Day(), s.hour, s.minute, 0, 0, s.calendar.Location)
+		if t.After(after) {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
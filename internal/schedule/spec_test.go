@@ -0,0 +1,166 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSpec(t *testing.T) {
+	calendar := loadTestCalendar(t)
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, calendar.Location)
+	}
+
+	tests := []struct {
+		name  string
+		spec  string
+		after time.Time
+
+		// want are the next runs after the time, the zero time when the
+		// spec never runs
+		want    []time.Time
+		wantErr string
+	}{
+		{
+			name:  "every 15 minutes",
+			spec:  "*/15 * * * *",
+			after: at(2026, time.January, 5, 8, 7),
+			want:  []time.Time{at(2026, time.January, 5, 8, 15), at(2026, time.January, 5, 8, 30)},
+		},
+		{
+			name:  "monthly on the 9th",
+			spec:  "0 9 9 * *",
+			after: at(2026, time.January, 9, 9, 0),
+			want:  []time.Time{at(2026, time.February, 9, 9, 0), at(2026, time.March, 9, 9, 0)},
+		},
+		{
+			name:  "list of hours",
+			spec:  "30 6,18 * * *",
+			after: at(2026, time.January, 5, 7, 0),
+			want:  []time.Time{at(2026, time.January, 5, 18, 30), at(2026, time.January, 6, 6, 30)},
+		},
+		{
+			name:  "weekdays range",
+			spec:  "0 8 * * 0-4",
+			after: at(2026, time.January, 1, 9, 0), // thursday
+			want:  []time.Time{at(2026, time.January, 4, 8, 0), at(2026, time.January, 5, 8, 0)},
+		},
+		{
+			name:  "sunday as 7",
+			spec:  "0 0 * * 7",
+			after: at(2026, time.January, 5, 0, 0),
+			want:  []time.Time{at(2026, time.January, 11, 0, 0), at(2026, time.January, 18, 0, 0)},
+		},
+		{
+			name:  "day of month or day of week",
+			spec:  "0 0 15 * 5",
+			after: at(2026, time.January, 10, 0, 0),
+			want:  []time.Time{at(2026, time.January, 15, 0, 0), at(2026, time.January, 16, 0, 0)},
+		},
+		{
+			name:  "step from a value",
+			spec:  "0 20/2 * * *",
+			after: at(2026, time.January, 5, 21, 0),
+			want:  []time.Time{at(2026, time.January, 5, 22, 0), at(2026, time.January, 6, 20, 0)},
+		},
+		{
+			name:  "leap day",
+			spec:  "0 12 29 2 *",
+			after: at(2026, time.January, 1, 0, 0),
+			want:  []time.Time{at(2028, time.February, 29, 12, 0)},
+		},
+		{
+			name:  "never",
+			spec:  "0 0 31 2 *",
+			after: at(2026, time.January, 1, 0, 0),
+			want:  []time.Time{{}},
+		},
+		{
+			// the clock moves from 02:00 to 03:00, the missing 02:30 runs
+			// once at 03:30
+			name:  "daylight saving time starts",
+			spec:  "30 2 * * *",
+			after: at(2026, time.March, 26, 12, 0),
+			want: []time.Time{
+				at(2026, time.March, 27, 2, 30),
+				at(2026, time.March, 28, 2, 30),
+			},
+		},
+		{
+			name:  "hourly when daylight saving time starts",
+			spec:  "0 * * * *",
+			after: at(2026, time.March, 27, 1, 30),
+			want:  []time.Time{at(2026, time.March, 27, 3, 0), at(2026, time.March, 27, 4, 0)},
+		},
+		{
+			// the clock moves from 02:00 back to 01:00, the repeated 01:30
+			// runs once, after the first 01:30 passed
+			name:  "daylight saving time ends",
+			spec:  "30 1 * * *",
+			after: at(2026, time.October, 25, 0, 0).Add(90 * time.Minute),
+			want: []time.Time{
+				at(2026, time.October, 25, 1, 30),
+				at(2026, time.October, 26, 1, 30),
+			},
+		},
+		{
+			name:  "last business day",
+			spec:  "@last_business_day 18:00",
+			after: at(2025, time.December, 31, 18, 0),
+			want: []time.Time{
+				at(2026, time.January, 30, 18, 0), // the 31st is shabbat
+				at(2026, time.February, 27, 18, 0),
+				at(2026, time.March, 31, 18, 0),
+			},
+		},
+		{
+			name:  "last business day later on the day",
+			spec:  "@last_business_day 18:00",
+			after: at(2026, time.January, 30, 17, 59),
+			want:  []time.Time{at(2026, time.January, 30, 18, 0), at(2026, time.February, 27, 18, 0)},
+		},
+		{
+			name:  "last business day across daylight saving time",
+			spec:  "@last_business_day 01:30",
+			after: at(2026, time.September, 30, 12, 0),
+			want:  []time.Time{at(2026, time.October, 30, 1, 30), at(2026, time.November, 30, 1, 30)},
+		},
+		{name: "empty", spec: "", wantErr: "expected 5 cron fields"},
+		{name: "four fields", spec: "* * * *", wantErr: "expected 5 cron fields"},
+		{name: "minute out of range", spec: "60 * * * *", wantErr: "invalid minute"},
+		{name: "hour out of range", spec: "0 24 * * *", wantErr: "invalid hour"},
+		{name: "day of month zero", spec: "0 0 0 * *", wantErr: "invalid day of month"},
+		{name: "month out of range", spec: "0 0 1 13 *", wantErr: "invalid month"},
+		{name: "day of week out of range", spec: "0 0 * * 8", wantErr: "invalid day of week"},
+		{name: "zero step", spec: "*/0 * * * *", wantErr: "invalid step"},
+		{name: "reversed range", spec: "0 5-1 * * *", wantErr: "value out of range"},
+		{name: "not a number", spec: "a * * * *", wantErr: "invalid value"},
+		{name: "last business day without time", spec: "@last_business_day", wantErr: "expected: @last_business_day HH:MM"},
+		{name: "last business day invalid time", spec: "@last_business_day 25:00", wantErr: "invalid time of day"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ParseSpec(tt.spec, calendar)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseSpec(%q) error = %v, want %q", tt.spec, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSpec(%q) error = %v", tt.spec, err)
+			}
+
+			after := tt.after
+			for _, want := range tt.want {
+				got := spec.Next(after)
+				if !got.Equal(want) {
+					t.Fatalf("Next(%s) = %s, want %s", after, got, want)
+				}
+				after = got
+			}
+		})
+	}
+}
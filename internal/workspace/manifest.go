@@ -0,0 +1,240 @@
+package workspace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vgeshiktor/bhops/internal/filelock"
+)
+
+// Manifest records which input files produced which outputs in each run
+// of a period
+type Manifest struct {
+	Period string `json:"period"`
+	Runs   []Run  `json:"runs"`
+}
+
+type Run struct {
+	Version   int          `json:"version"`
+	CreatedAt time.Time    `json:"created_at"`
+	Inputs    []FileRecord `json:"inputs"`
+	Outputs   []FileRecord `json:"outputs"`
+}
+
+type FileRecord struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// LoadManifest reads the period manifest, a missing manifest is empty
+func (p *Period) LoadManifest() (*Manifest, error) {
+	manifest := &Manifest{Period: p.Name}
+
+	content, err := os.ReadFile(p.ManifestPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %s, error: %w", p.ManifestPath(), err)
+	}
+
+	if err := json.Unmarshal(content, manifest); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest: %s, error: %w", p.ManifestPath(), err)
+	}
+
+	return manifest, nil
+}
+
+// ReserveVersion reserves the version of the next run, by creating its
+// empty outputs under the manifest lock, so concurrent runs of the period
+// never share a version or overwrite the outputs of an earlier run
+func (p *Period) ReserveVersion(outputNames ...string) (int, error) {
+	if err := os.MkdirAll(p.OutputDir(), os.ModePerm); err != nil {
+		return 0, fmt.Errorf("failed to create period directory: %s, error: %w", p.OutputDir(), err)
+	}
+
+	lock, err := p.lockManifest()
+	if err != nil {
+		return 0, err
+	}
+	defer p.unlockManifest(lock)
+
+	manifest, err := p.LoadManifest()
+	if err != nil {
+		return 0, err
+	}
+
+	version := 1
+	for _, run := range manifest.Runs {
+		if run.Version >= version {
+			version = run.Version + 1
+		}
+	}
+
+	// skip versions with outputs left by runs missing from the manifest,
+	// or reserved by runs in progress
+	for ; ; version++ {
+		reserved, err := p.reserveOutputs(version, outputNames)
+		if err != nil {
+			return 0, err
+		}
+		if reserved {
+			return version, nil
+		}
+	}
+}
+
+// reserveOutputs creates the empty outputs of a version, and reports false
+// when one of them already exists
+func (p *Period) reserveOutputs(version int, outputNames []string) (bool, error) {
+	var created []string
+	for _, name := range outputNames {
+		path := p.OutputPath(name, version)
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			err = file.Close()
+		}
+		if err == nil {
+			created = append(created, path)
+			continue
+		}
+
+		// release the outputs created for the version
+		for _, path := range created {
+			if err := os.Remove(path); err != nil {
+				log.Error().Msgf("failed to remove reserved output: %s, error: %v", path, err)
+			}
+		}
+		if errors.Is(err, os.ErrExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to reserve output: %s, error: %w", path, err)
+	}
+
+	return true, nil
+}
+
+// LatestOutput returns the path of the named output of the latest run
+func (p *Period) LatestOutput(name string) (string, error) {
+	manifest, err := p.LoadManifest()
+	if err != nil {
+		return "", err
+	}
+	if len(manifest.Runs) == 0 {
+		return "", fmt.Errorf("no runs recorded for period: %s", p.Name)
+	}
+
+	path := p.OutputPath(name, manifest.Runs[len(manifest.Runs)-1].Version)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("latest output not found: %s, error: %w", path, err)
+	}
+
+	return path, nil
+}
+
+// RecordRun appends a run with the checksums of its inputs and outputs to
+// the period manifest
+func (p *Period) RecordRun(version int, inputs, outputs []string) (*Run, error) {
+	lock, err := p.lockManifest()
+	if err != nil {
+		return nil, err
+	}
+	defer p.unlockManifest(lock)
+
+	manifest, err := p.LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	run := Run{
+		Version:   version,
+		CreatedAt: time.Now(),
+	}
+	for _, path := range inputs {
+		record, err := p.fileRecord(path)
+		if err != nil {
+			return nil, err
+		}
+		run.Inputs = append(run.Inputs, record)
+	}
+	for _, path := range outputs {
+		record, err := p.fileRecord(path)
+		if err != nil {
+			return nil, err
+		}
+		run.Outputs = append(run.Outputs, record)
+	}
+	// runs are kept in version order, concurrent runs may finish out of
+	// order
+	manifest.Runs = append(manifest.Runs, run)
+	sort.SliceStable(manifest.Runs, func(i, j int) bool {
+		return manifest.Runs[i].Version < manifest.Runs[j].Version
+	})
+
+	content, err := json.MarshalIndent(manifest, "", "   ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest, error: %w", err)
+	}
+
+	// replace manifest atomically
+	tmpPath := p.ManifestPath() + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %s, error: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, p.ManifestPath()); err != nil {
+		return nil, fmt.Errorf("failed to rename manifest: %s, error: %w", tmpPath, err)
+	}
+
+	return &run, nil
+}
+
+// lockManifest locks the period manifest against the runs of other
+// processes
+func (p *Period) lockManifest() (*filelock.Lock, error) {
+	return filelock.Acquire(p.ManifestPath() + ".lock")
+}
+
+func (p *Period) unlockManifest(lock *filelock.Lock) {
+	if err := lock.Release(); err != nil {
+		log.Error().Msgf("failed to unlock manifest: %s, error: %v", p.ManifestPath(), err)
+	}
+}
+
+// fileRecord returns the checksum record of a file, with its path relative
+// to the workspace root when possible
+func (p *Period) fileRecord(path string) (FileRecord, error) {
+	record := FileRecord{Path: path}
+	if rel, err := filepath.Rel(p.root, path); err == nil {
+		record.Path = rel
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return record, fmt.Errorf("failed to open file: %s, error: %w", path, err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Error().Msgf("failed to close file: %s, error: %v", path, err)
+		}
+	}()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return record, fmt.Errorf("failed to read file: %s, error: %w", path, err)
+	}
+	record.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	record.Size = size
+
+	return record, nil
+}
@@ -0,0 +1,248 @@
+package workspace
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Workspace directory layout:
+//
+//	<root>/config/id2worker.json       shared worker details
+//	<root>/<YYYY-MM>/input             period attendance export and inputs
+//	<root>/<YYYY-MM>/output            versioned outputs and manifest
+//	<root>/<YYYY-MM>/archive           processed or replaced inputs
+const (
+	PeriodLayout      = "2006-01"
+	InputDir          = "input"
+	OutputDir         = "output"
+	ArchiveDir        = "archive"
+	ConfigDir         = "config"
+	WorkerDetailsFile = "id2worker.json"
+	NonAttendanceFile = "workershours.json"
+	ManifestFile      = "manifest.json"
+
+	// attendance exports are named by the clock vendor as MM-YYYY.xlsx
+	AttendanceExportLayout = "01-2006"
+)
+
+type Workspace struct {
+	Root string
+}
+
+// Period is the directory of a single month in the workspace
+type Period struct {
+	Name string
+	Dir  string
+	root string
+}
+
+// Inputs are the files a period report is built from
+type Inputs struct {
+	AttendanceReport    string
+	NonAttendanceReport string
+	WorkerDetails       string
+}
+
+func New(root string) *Workspace {
+	return &Workspace{Root: root}
+}
+
+// ParsePeriod validates a YYYY-MM period name
+func ParsePeriod(period string) (time.Time, error) {
+	t, err := time.Parse(PeriodLayout, period)
+	if err != nil {
+		return t, fmt.Errorf("invalid period: %s, expected YYYY-MM", period)
+	}
+	return t, nil
+}
+
+// PeriodOfExport returns the period of an attendance export named MM-YYYY.xlsx
+func PeriodOfExport(path string) (string, bool) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	t, err := time.Parse(AttendanceExportLayout, name)
+	if err != nil {
+		return "", false
+	}
+	return t.Format(PeriodLayout), true
+}
+
+func (w *Workspace) Period(period string) (*Period, error) {
+	if _, err := ParsePeriod(period); err != nil {
+		return nil, err
+	}
+
+	return &Period{
+		Name: period,
+		Dir:  filepath.Join(w.Root, period),
+		root: w.Root,
+	}, nil
+}
+
+// Periods returns the names of the periods in the workspace, oldest first
+func (w *Workspace) Periods() ([]string, error) {
+	entries, err := os.ReadDir(w.Root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace: %s, error: %w", w.Root, err)
+	}
+
+	var periods []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := ParsePeriod(entry.Name()); err == nil {
+			periods = append(periods, entry.Name())
+		}
+	}
+	sort.Strings(periods)
+
+	return periods, nil
+}
+
+func (p *Period) InputDir() string {
+	return filepath.Join(p.Dir, InputDir)
+}
+
+// WorkerDetailsPath returns the worker details file of the period inputs,
+// which takes precedence over the shared workspace worker details
+func (p *Period) WorkerDetailsPath() string {
+	return filepath.Join(p.InputDir(), WorkerDetailsFile)
+}
+
+func (p *Period) OutputDir() string {
+	return filepath.Join(p.Dir, OutputDir)
+}
+
+func (p *Period) ArchiveDir() string {
+	return filepath.Join(p.Dir, ArchiveDir)
+}
+
+func (p *Period) ManifestPath() string {
+	return filepath.Join(p.OutputDir(), ManifestFile)
+}
+
+// Init creates the period directories
+func (p *Period) Init() error {
+	for _, dir := range []string{p.InputDir(), p.OutputDir(), p.ArchiveDir()} {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create period directory: %s, error: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// FindInputs finds the period input files. The attendance export is the
+// MM-YYYY.xlsx workbook of the period, or the only workbook in the input
+// directory. Worker details in the input directory take precedence over the
+// shared workspace worker details.
+func (p *Period) FindInputs() (Inputs, error) {
+	var inputs Inputs
+
+	// find attendance export
+	workbooks, err := filepath.Glob(filepath.Join(p.InputDir(), "*.xlsx"))
+	if err != nil {
+		return inputs, fmt.Errorf("failed to list input workbooks, error: %w", err)
+	}
+	for _, workbook := range workbooks {
+		if period, ok := PeriodOfExport(workbook); ok && period == p.Name {
+			inputs.AttendanceReport = workbook
+		}
+	}
+	if inputs.AttendanceReport == "" {
+		switch len(workbooks) {
+		case 0:
+			return inputs, fmt.Errorf("no attendance workbook found in: %s", p.InputDir())
+		case 1:
+			inputs.AttendanceReport = workbooks[0]
+		default:
+			return inputs, fmt.Errorf("found %d attendance workbooks in: %s, expected one named %s.xlsx",
+				len(workbooks), p.InputDir(), p.exportName())
+		}
+	}
+
+	// find non-attendance report
+	inputs.NonAttendanceReport = filepath.Join(p.InputDir(), NonAttendanceFile)
+	if _, err := os.Stat(inputs.NonAttendanceReport); err != nil {
+		return inputs, fmt.Errorf("non-attendance report not found: %s, error: %w",
+			inputs.NonAttendanceReport, err)
+	}
+
+	// find worker details
+	inputs.WorkerDetails = p.WorkerDetailsPath()
+	if _, err := os.Stat(inputs.WorkerDetails); errors.Is(err, os.ErrNotExist) {
+		inputs.WorkerDetails = filepath.Join(p.root, ConfigDir, WorkerDetailsFile)
+	}
+	if _, err := os.Stat(inputs.WorkerDetails); err != nil {
+		return inputs, fmt.Errorf("worker details not found: %s, error: %w",
+			inputs.WorkerDetails, err)
+	}
+
+	return inputs, nil
+}
+
+// SaveInput saves an input file of the period, an existing input with the
+// same name and another content is moved to the archive first. Returns the
+// archived path, empty when nothing was archived, and whether the input
+// was written, false when it already had the same content.
+func (p *Period) SaveInput(name string, content []byte) (string, bool, error) {
+	if err := p.Init(); err != nil {
+		return "", false, err
+	}
+
+	inputPath := filepath.Join(p.InputDir(), filepath.Base(name))
+	archivePath := ""
+	existing, err := os.ReadFile(inputPath)
+	switch {
+	case err == nil && bytes.Equal(existing, content):
+		return "", false, nil
+	case err == nil:
+		timestamp := time.Now().Format("2006-01-02T15-04-05")
+		archivePath = filepath.Join(p.ArchiveDir(), timestamp+"-"+filepath.Base(name))
+		if err := os.Rename(inputPath, archivePath); err != nil {
+			return "", false, fmt.Errorf("failed to archive input: %s, error: %w", inputPath, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return "", false, fmt.Errorf("failed to read input: %s, error: %w", inputPath, err)
+	}
+
+	// write to a temporary file, so readers never see a partial input
+	tmpPath := inputPath + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0o644); err != nil {
+		return "", false, fmt.Errorf("failed to write input: %s, error: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, inputPath); err != nil {
+		return "", false, fmt.Errorf("failed to rename input: %s, error: %w", tmpPath, err)
+	}
+
+	return archivePath, true, nil
+}
+
+func (p *Period) exportName() string {
+	t, _ := ParsePeriod(p.Name)
+	return t.Format(AttendanceExportLayout)
+}
+
+// Previous returns the period of the month before
+func (p *Period) Previous() *Period {
+	t, _ := ParsePeriod(p.Name)
+	name := t.AddDate(0, -1, 0).Format(PeriodLayout)
+	return &Period{
+		Name: name,
+		Dir:  filepath.Join(p.root, name),
+		root: p.root,
+	}
+}
+
+// OutputPath returns the path of a versioned output, e.g. for version 3 of
+// salary_details.xlsx: output/salary_details_v003.xlsx
+func (p *Period) OutputPath(name string, version int) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	return filepath.Join(p.OutputDir(), fmt.Sprintf("%s_v%03d%s", base, version, ext))
+}